@@ -0,0 +1,332 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"expvar"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// auditMetrics counts the audit records by connection.
+var auditMetrics = expvar.NewMap("audit_records")
+
+// The kinds of audit records.
+const (
+	// AuditConnect is a signed connect, successful or not.
+	AuditConnect = "connect"
+	// AuditOut is a frame written to the server, recorded before it's written.
+	AuditOut = "out"
+	// AuditIn is a frame read from the server, recorded before it's delivered.
+	AuditIn = "in"
+)
+
+// AuditRecord is a record of the audit log. Each record holds the hash of the one before it, and
+// its own hash of its other fields, so that any record removed, inserted or altered breaks the
+// chain from there on.
+type AuditRecord struct {
+	Seq  int64           `json:"seq"`
+	Time MicrosTimestamp `json:"ts"`
+	Prev string          `json:"prev"`
+	Conn string          `json:"conn"`
+	Kind string          `json:"kind"`
+	// Addr, APIKey and Signed are the address, the api key and the timestamp of the signature of a
+	// connect, and Error why it failed. The signature itself isn't recorded.
+	Addr   string           `json:"addr,omitempty"`
+	APIKey string           `json:"apikey,omitempty"`
+	Signed *MicrosTimestamp `json:"signed,omitempty"`
+	Error  string           `json:"error,omitempty"`
+	// Frame is a JSON frame, and Text any other frame.
+	Frame json.RawMessage `json:"frame,omitempty"`
+	Text  string          `json:"text,omitempty"`
+	// Hash is the SHA-256 of the record without it. It must stay the last field.
+	Hash string `json:"hash,omitempty"`
+}
+
+// hashSuffix is the end of a record from its hash on: `,"hash":"<64 hex digits>"}`.
+const hashSuffix = len(`,"hash":""}`) + sha256.Size*2
+
+// ClOrdIDs returns the ClOrdID and OrigClOrdID values anywhere in the frame.
+func (r *AuditRecord) ClOrdIDs() (ids []string) {
+	var frame interface{}
+	if len(r.Frame) == 0 || json.Unmarshal(r.Frame, &frame) != nil {
+		return
+	}
+	var walk func(value interface{})
+	walk = func(value interface{}) {
+		switch value := value.(type) {
+		case map[string]interface{}:
+			for key, field := range value {
+				if id, ok := field.(string); ok && (key == "ClOrdID" || key == "OrigClOrdID") {
+					ids = append(ids, id)
+				}
+				walk(field)
+			}
+		case []interface{}:
+			for _, element := range value {
+				walk(element)
+			}
+		}
+	}
+	walk(frame)
+	return
+}
+
+// Type returns the type of the frame, if any.
+func (r *AuditRecord) Type() string {
+	var frame struct {
+		Type string `json:"type"`
+	}
+	_ = json.Unmarshal(r.Frame, &frame)
+	return frame.Type
+}
+
+// AuditLog is an append-only JSON lines file of hash-chained records of the connects and of
+// the frames sent and received by the connections using it. It is safe for concurrent use, but
+// only one process may write to a file: it's locked until the log is closed.
+//
+// The sequence number and hash of the last record are also kept in the head file next to the
+// log, so that records dropped from the end of the log can be detected.
+type AuditLog struct {
+	mu   sync.Mutex
+	file *os.File
+	head *os.File
+	// seq and last are the sequence number and hash of the last record
+	seq  int64
+	last string
+}
+
+// AuditHead is the sequence number and hash of the last record of an audit log.
+type AuditHead struct {
+	Seq  int64  `json:"seq"`
+	Hash string `json:"hash"`
+}
+
+// AuditHeadPath returns the path of the head file of the audit log.
+func AuditHeadPath(path string) string {
+	return path + ".head"
+}
+
+// ReadAuditHead reads the head file of the audit log, or returns nil if there's none.
+func ReadAuditHead(path string) (head *AuditHead, err error) {
+	data, err := os.ReadFile(AuditHeadPath(path))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return
+	}
+	head = &AuditHead{}
+	if err = json.Unmarshal(data, head); err != nil {
+		return nil, errors.Wrapf(err, "invalid audit head %s", AuditHeadPath(path))
+	}
+	return
+}
+
+// OpenAuditLog opens the audit log for appending, creating it if needed, and locks it until
+// it's closed. It refuses a log whose last record is incomplete or invalid, as it can't be
+// chained to, and a log that ends before the record of its head file.
+func OpenAuditLog(path string) (result *AuditLog, err error) {
+	head, err := ReadAuditHead(path)
+	if err != nil {
+		return
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		err = errors.Wrapf(err, "unable to open audit log %s", path)
+		return
+	}
+	result = &AuditLog{file: file}
+	defer func() {
+		if err != nil {
+			_ = result.Close()
+			result = nil
+		}
+	}()
+	if err = lockFile(file); err != nil {
+		return result, errors.Wrapf(err, "unable to lock audit log %s", path)
+	}
+	if err = result.readLast(); err != nil {
+		return result, errors.Wrapf(err, "unable to open audit log %s", path)
+	}
+	// the head is written after the record, so it may be behind the log but never ahead of it
+	if head != nil && (head.Seq > result.seq || (head.Seq == result.seq && head.Hash != result.last)) {
+		return result, errors.Errorf("audit log %s ends at record %d, before record %d of its head",
+			path, result.seq, head.Seq)
+	}
+	if result.head, err = os.OpenFile(AuditHeadPath(path), os.O_CREATE|os.O_WRONLY, 0600); err != nil {
+		return result, errors.Wrapf(err, "unable to open audit head %s", AuditHeadPath(path))
+	}
+	if err = result.writeHead(); err != nil {
+		return result, errors.Wrapf(err, "unable to write audit head %s", AuditHeadPath(path))
+	}
+	return
+}
+
+// readLast reads the sequence number and hash of the last record.
+func (a *AuditLog) readLast() (err error) {
+	info, err := a.file.Stat()
+	if err != nil {
+		return
+	}
+	line, err := lastLine(a.file, info.Size())
+	if err != nil || line == nil {
+		return
+	}
+	record, err := parseAuditRecord(line)
+	if err != nil {
+		return errors.Wrap(err, "invalid last record")
+	}
+	a.seq, a.last = record.Seq, record.Hash
+	return
+}
+
+// lastLine returns the last line of the file, without its line feed, or nil if it's empty.
+func lastLine(file *os.File, size int64) (line []byte, err error) {
+	if size == 0 {
+		return
+	}
+	const chunk = 4096
+	var tail []byte
+	for offset := size; offset > 0; {
+		n := int64(chunk)
+		if offset < n {
+			n = offset
+		}
+		offset -= n
+		buf := make([]byte, n)
+		if _, err = file.ReadAt(buf, offset); err != nil {
+			return nil, err
+		}
+		tail = append(buf, tail...)
+		if tail[len(tail)-1] != '\n' {
+			return nil, errors.New("the last record is incomplete")
+		}
+		if i := bytes.LastIndexByte(tail[:len(tail)-1], '\n'); i >= 0 {
+			return tail[i+1 : len(tail)-1], nil
+		}
+	}
+	return tail[:len(tail)-1], nil
+}
+
+// writeHead writes the sequence number and hash of the last record to the head file. As they
+// only grow, the head is overwritten in place.
+func (a *AuditLog) writeHead() (err error) {
+	data, err := json.Marshal(AuditHead{Seq: a.seq, Hash: a.last})
+	if err != nil {
+		return
+	}
+	data = append(data, '\n')
+	if _, err = a.head.WriteAt(data, 0); err != nil {
+		return
+	}
+	return a.head.Truncate(int64(len(data)))
+}
+
+// Record appends a record, setting its sequence number, time and hashes.
+func (a *AuditLog) Record(record AuditRecord) (err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	record.Seq = a.seq + 1
+	record.Time = MicrosTimestamp(time.Now())
+	record.Prev = a.last
+	record.Hash = ""
+	if record.Frame != nil && !json.Valid(record.Frame) {
+		record.Text, record.Frame = string(record.Frame), nil
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return errors.Wrap(err, "unable to encode audit record")
+	}
+	sum := sha256.Sum256(data)
+	record.Hash = hex.EncodeToString(sum[:])
+	line := append(data[:len(data)-1], `,"hash":"`+record.Hash+`"}`+"\n"...)
+	if _, err = a.file.Write(line); err != nil {
+		// the record may be partly written, so read the last record again
+		if readErr := a.readLast(); readErr != nil {
+			log.Printf("unable to read audit log after a failed write: %s", readErr)
+		}
+		return errors.Wrap(err, "unable to write audit record")
+	}
+	a.seq, a.last = record.Seq, record.Hash
+	auditMetrics.Add(record.Conn, 1)
+	if err = a.writeHead(); err != nil {
+		return errors.Wrap(err, "unable to write audit head")
+	}
+	return
+}
+
+// Close closes the audit log, releasing its lock.
+func (a *AuditLog) Close() (err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.head != nil {
+		err = a.head.Close()
+	}
+	if closeErr := a.file.Close(); err == nil {
+		err = closeErr
+	}
+	return
+}
+
+// parseAuditRecord decodes a line of the audit log and checks its hash.
+func parseAuditRecord(line []byte) (record *AuditRecord, err error) {
+	record = &AuditRecord{}
+	if err = json.Unmarshal(line, record); err != nil {
+		return nil, errors.Wrap(err, "unable to decode record")
+	}
+	// the hash is of the line as written, without the hash
+	if len(line) < hashSuffix || !bytes.HasPrefix(line[len(line)-hashSuffix:], []byte(`,"hash":"`)) {
+		return nil, errors.Errorf("record %d has no hash", record.Seq)
+	}
+	sum := sha256.Sum256(append(line[:len(line)-hashSuffix:len(line)-hashSuffix], '}'))
+	if record.Hash != hex.EncodeToString(sum[:]) {
+		return nil, errors.Errorf("record %d doesn't match its hash", record.Seq)
+	}
+	return
+}
+
+// ReadAuditLog calls the function with each record of the audit log in order, after checking
+// that it follows the one before it and matches its hash. It returns at the first record that
+// doesn't, so that a log read to its end is the unaltered log since its first record.
+func ReadAuditLog(r io.Reader, f func(record *AuditRecord) error) (err error) {
+	reader := bufio.NewReader(r)
+	var previous *AuditRecord
+	for line := 1; ; line++ {
+		var data []byte
+		data, err = reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(data) > 0 {
+				return errors.Errorf("line %d: the last record is incomplete", line)
+			}
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, "line %d", line)
+		}
+		var record *AuditRecord
+		if record, err = parseAuditRecord(data[:len(data)-1]); err != nil {
+			return errors.Wrapf(err, "line %d", line)
+		}
+		switch {
+		case previous == nil && (record.Seq != 1 || record.Prev != ""):
+			return errors.Errorf("line %d: record %d isn't the first of a chain", line, record.Seq)
+		case previous != nil && record.Seq != previous.Seq+1:
+			return errors.Errorf("line %d: record %d follows record %d", line, record.Seq, previous.Seq)
+		case previous != nil && record.Prev != previous.Hash:
+			return errors.Errorf("line %d: record %d doesn't chain to record %d", line, record.Seq, previous.Seq)
+		}
+		if err = f(record); err != nil {
+			return
+		}
+		previous = record
+	}
+}
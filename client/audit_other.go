@@ -0,0 +1,10 @@
+//go:build !unix
+
+package client
+
+import "os"
+
+// lockFile does nothing, the file is only locked within the process.
+func lockFile(*os.File) error {
+	return nil
+}
@@ -0,0 +1,213 @@
+package client
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// readAll returns the records of the audit log file, or the error reading it.
+func readAll(path string) (records []*AuditRecord, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	err = ReadAuditLog(file, func(record *AuditRecord) error {
+		records = append(records, record)
+		return nil
+	})
+	return
+}
+
+func TestAuditLogChain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.audit.jsonl")
+	audit, err := OpenAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames := []string{
+		`{"reqid":1,"type":"NewOrderSingle","data":{"ClOrdID":"C1","Symbol":"DOGE-USDT"}}`,
+		`{"reqid":1,"type":"ExecutionReport","data":[{"ClOrdID":"C1"},{"ClOrdID":"C2","OrigClOrdID":"C1"}]}`,
+		`not json`,
+	}
+	for _, frame := range frames {
+		if err = audit.Record(AuditRecord{Conn: "test", Kind: AuditOut, Frame: []byte(frame)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = audit.Close()
+
+	// a reopened log continues the chain
+	if audit, err = OpenAuditLog(path); err != nil {
+		t.Fatal(err)
+	}
+	if err = audit.Record(AuditRecord{Conn: "test", Kind: AuditIn, Frame: []byte(`{"type":"hello"}`)}); err != nil {
+		t.Fatal(err)
+	}
+	_ = audit.Close()
+
+	records, err := readAll(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 || records[3].Seq != 4 || records[3].Prev != records[2].Hash {
+		t.Fatalf("got records %+v, expected a chain of 4", records)
+	}
+	if ids := records[1].ClOrdIDs(); len(ids) != 3 {
+		t.Errorf("got ClOrdIDs %v, expected C1, C2 and C1", ids)
+	}
+	if records[0].Type() != "NewOrderSingle" || records[2].Text != "not json" {
+		t.Errorf("got %s and %q", records[0].Type(), records[2].Text)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	tests := []struct {
+		name     string
+		modified string
+		expected string
+	}{
+		{"altered", strings.Replace(string(data), "DOGE-USDT", "BTC-USDT", 1), "record 1 doesn't match its hash"},
+		{"removed", lines[0] + lines[2] + lines[3], "record 3 follows record 1"},
+		{"reordered", lines[0] + lines[2] + lines[1] + lines[3], "record 3 follows record 1"},
+		{"truncated at the start", lines[1] + lines[2], "record 2 isn't the first"},
+		{"incomplete", string(data) + lines[0][:10], "the last record is incomplete"},
+	}
+	for _, test := range tests {
+		err = ReadAuditLog(strings.NewReader(test.modified), func(*AuditRecord) error { return nil })
+		if err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("%s: got %v, expected %s", test.name, err, test.expected)
+		}
+	}
+
+	// a log ending with an incomplete record can't be appended to
+	if err = os.WriteFile(path, []byte(string(data)+lines[0][:10]), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = OpenAuditLog(path); err == nil {
+		t.Error("opened a log ending with an incomplete record")
+	}
+}
+
+func TestAuditLogHead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.audit.jsonl")
+	audit, err := OpenAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err = audit.Record(AuditRecord{Conn: "test", Kind: AuditIn, Frame: []byte(`{"type":"heartbeat"}`)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = audit.Close()
+
+	records, err := readAll(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, err := ReadAuditHead(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head == nil || head.Seq != 3 || head.Hash != records[2].Hash {
+		t.Fatalf("got head %+v, expected record 3 %s", head, records[2].Hash)
+	}
+
+	// a log whose last records were dropped can't be appended to, as it would hide the gap
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	if err = os.WriteFile(path, []byte(lines[0]+lines[1]), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = OpenAuditLog(path); err == nil || !strings.Contains(err.Error(), "ends at record 2, before record 3") {
+		t.Errorf("got %v, expected the dropped record to be detected", err)
+	}
+
+	// a head behind the log, written before a crash, is caught up
+	if err = os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(AuditHeadPath(path), []byte(`{"seq":2,"hash":"`+records[1].Hash+`"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if audit, err = OpenAuditLog(path); err != nil {
+		t.Fatal(err)
+	}
+	_ = audit.Close()
+	if head, err = ReadAuditHead(path); err != nil || head.Seq != 3 {
+		t.Errorf("got head %+v and %v, expected record 3", head, err)
+	}
+}
+
+func TestAuditLogConnection(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_ = conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"hello","session_id":"S1"}`))
+		_, message, _ := conn.ReadMessage()
+		_ = conn.WriteMessage(websocket.TextMessage, bytes.ToUpper(message))
+		_, _, _ = conn.ReadMessage()
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "test.audit.jsonl")
+	audit, err := OpenAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer audit.Close()
+	options := DefaultConnectOptions()
+	options.Name = "test"
+	options.Audit = audit
+	addr := "ws" + strings.TrimPrefix(server.URL, "http")
+	if _, err = ConnectWithOptions("ws://127.0.0.1:1", "key", "secret", options); err == nil {
+		t.Fatal("connected to a closed port")
+	}
+	c, err := ConnectWithOptions(addr, "key", "secret", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-c.IncomingChannel()
+	c.OutgoingChannel() <- []byte(`{"type":"subscribe"}`)
+	select {
+	case <-c.IncomingChannel():
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reply")
+	}
+	c.Close()
+
+	records, err := readAll(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kinds []string
+	for _, record := range records {
+		kinds = append(kinds, record.Kind+" "+record.Type())
+	}
+	expected := []string{"connect ", "connect ", "in hello", "out subscribe", "in SUBSCRIBE"}
+	if strings.Join(kinds, ",") != strings.Join(expected, ",") {
+		t.Fatalf("got records %v, expected %v", kinds, expected)
+	}
+	if records[0].Error == "" || records[1].Error != "" || records[1].APIKey != "key" || records[1].Signed == nil {
+		t.Errorf("got connects %+v and %+v, expected a failed one then a signed one", records[0], records[1])
+	}
+}
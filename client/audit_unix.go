@@ -0,0 +1,19 @@
+//go:build unix
+
+package client
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// lockFile locks the file exclusively until it's closed, failing if another process holds it.
+func lockFile(file *os.File) error {
+	err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		return errors.New("it's in use by another process")
+	}
+	return err
+}
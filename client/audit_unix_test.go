@@ -0,0 +1,27 @@
+//go:build unix
+
+package client
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAuditLogLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.audit.jsonl")
+	audit, err := OpenAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the log has a single writer until it's closed
+	if _, err = OpenAuditLog(path); err == nil || !strings.Contains(err.Error(), "in use by another process") {
+		t.Errorf("got %v, expected the log to be locked", err)
+	}
+	_ = audit.Close()
+	if audit, err = OpenAuditLog(path); err != nil {
+		t.Fatal(err)
+	}
+	_ = audit.Close()
+}
@@ -1,9 +1,12 @@
 package client
 
 import (
+	"crypto/tls"
+	"expvar"
 	"log"
 	"net/http"
 	"net/url"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -23,8 +26,146 @@ const (
 
 	// Maximum message size allowed from peer.
 	maxMessageSize = 32768
+
+	// Time allowed to complete the websocket handshake.
+	handshakeTimeout = 5 * time.Second
+
+	// Capacity of the incoming and outgoing channels.
+	channelBufferSize = 1000
+
+	// Maximum number of messages held in the outgoing queue. Once it's full, messages are left
+	// on the outgoing channel, which blocks the sender when it fills up in turn.
+	maxQueuedMessages = 100
 )
 
+// ConnectOptions contains the connection tunables. Use DefaultConnectOptions to get a
+// set of options with sensible defaults.
+type ConnectOptions struct {
+	// WriteWait is the time allowed to write a message to the peer.
+	WriteWait time.Duration
+	// PongWait is the time allowed to read the next pong message from the peer.
+	PongWait time.Duration
+	// PingPeriod is the period to send pings to the peer. Must be less than PongWait.
+	PingPeriod time.Duration
+	// MaxMessageSize is the maximum message size allowed from the peer.
+	MaxMessageSize int64
+	// HandshakeTimeout is the time allowed to complete the websocket handshake.
+	HandshakeTimeout time.Duration
+	// RateLimits are the outgoing rate limits by message type.
+	RateLimits map[string]RateLimit
+	// ReadBufferSize and WriteBufferSize are the websocket I/O buffer sizes. If zero, 4096
+	// bytes are used. They don't limit the message size.
+	ReadBufferSize  int
+	WriteBufferSize int
+	// IncomingBufferSize and OutgoingBufferSize are the capacities of the incoming and outgoing
+	// channels, in messages.
+	IncomingBufferSize int
+	OutgoingBufferSize int
+	// Header holds extra headers sent with the websocket handshake.
+	Header http.Header
+	// TLSConfig is used for wss addresses. If nil, the default TLS config is used.
+	TLSConfig *tls.Config
+	// Proxy is the URL of an HTTP or SOCKS5 proxy, such as socks5://proxy:1080. If empty, the
+	// proxy is taken from the HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables.
+	Proxy string
+	// EnableCompression negotiates permessage-deflate compression with the server.
+	EnableCompression bool
+	// Name identifies the connection in the logs and metrics. If empty, the host is used.
+	Name string
+	// OverflowPolicy is what to do with incoming messages when the incoming channel is full.
+	// Defaults to OverflowBlock.
+	OverflowPolicy OverflowPolicy
+	// SpillDir is the directory of the spill files of the OverflowSpill policy, the temporary
+	// directory if empty, and SpillMaxBytes the size over which the connection is closed. Zero
+	// doesn't limit the size.
+	SpillDir      string
+	SpillMaxBytes int64
+	// Clock stamps the handshake signature, compensating for the skew of the local clock if
+	// enabled. If nil, the local time is used.
+	Clock *Clock
+	// Audit records the connects and every frame sent and received, if not nil. A frame that
+	// can't be recorded isn't sent or delivered, and closes the connection.
+	Audit *AuditLog
+}
+
+// DefaultConnectOptions returns the default connection options.
+func DefaultConnectOptions() ConnectOptions {
+	return ConnectOptions{
+		WriteWait:          writeWait,
+		PongWait:           pongWait,
+		PingPeriod:         pingPeriod,
+		MaxMessageSize:     maxMessageSize,
+		HandshakeTimeout:   handshakeTimeout,
+		IncomingBufferSize: channelBufferSize,
+		OutgoingBufferSize: channelBufferSize,
+	}
+}
+
+// Validate checks that the options are consistent.
+func (o ConnectOptions) Validate() error {
+	if o.WriteWait <= 0 || o.PongWait <= 0 || o.PingPeriod <= 0 || o.HandshakeTimeout <= 0 {
+		return errors.New("timeouts and periods must be positive")
+	}
+	if o.PingPeriod >= o.PongWait {
+		return errors.Errorf("ping period %s must be less than pong wait %s", o.PingPeriod, o.PongWait)
+	}
+	if o.MaxMessageSize <= 0 {
+		return errors.New("max message size must be positive")
+	}
+	if o.ReadBufferSize < 0 || o.WriteBufferSize < 0 {
+		return errors.New("read and write buffer sizes must not be negative")
+	}
+	if o.IncomingBufferSize <= 0 || o.OutgoingBufferSize <= 0 {
+		return errors.New("incoming and outgoing buffer sizes must be positive")
+	}
+	switch o.OverflowPolicy {
+	case OverflowBlock, OverflowSpill:
+	case OverflowDropOldest:
+		// room for a message and the overflow marker ahead of it
+		if o.IncomingBufferSize < 2 {
+			return errors.New("the dropOldest overflow policy needs an incoming buffer size of at least 2")
+		}
+	default:
+		return errors.Errorf("invalid overflow policy %s", o.OverflowPolicy)
+	}
+	if o.SpillMaxBytes < 0 {
+		return errors.New("spill max bytes must not be negative")
+	}
+	for name := range o.Header {
+		switch http.CanonicalHeaderKey(name) {
+		case "Apikey", "Apisign", "Apitimestamp":
+			return errors.Errorf("header %s is set by the client", name)
+		}
+	}
+	if _, err := o.proxy(); err != nil {
+		return err
+	}
+	for msgType, limit := range o.RateLimits {
+		if err := limit.Validate(); err != nil {
+			return errors.Wrapf(err, "invalid rate limit for %s", msgType)
+		}
+	}
+	return nil
+}
+
+// proxy returns the proxy function of the dialer.
+func (o ConnectOptions) proxy() (proxy func(*http.Request) (*url.URL, error), err error) {
+	if o.Proxy == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+	proxyURL, err := url.Parse(o.Proxy)
+	if err != nil {
+		err = errors.Wrapf(err, "invalid proxy %s", o.Proxy)
+		return
+	}
+	// these are the schemes supported by the websocket dialer
+	if proxyURL.Scheme != "http" && proxyURL.Scheme != "socks5" {
+		err = errors.Errorf("invalid proxy %s, the scheme must be http or socks5", o.Proxy)
+		return
+	}
+	return http.ProxyURL(proxyURL), nil
+}
+
 // IncomingChannel is used to receive a message from the websocket.
 type IncomingChannel <-chan []byte
 
@@ -38,8 +179,20 @@ type client struct {
 	conn               *websocket.Conn
 	incoming, outgoing chan []byte
 	errorC             chan error
+	options            ConnectOptions
+	name               string
+
+	limiter *rateLimiter
+	queue   *outgoingQueue
+
+	// the read pump state of the overflow policies
+	spill           *spillQueue
+	dropped         int64
+	overflowPending bool
+	lastSlowWarning time.Time
 
 	closeC         chan interface{}
+	closeOnce      sync.Once
 	closeRequested int32
 }
 
@@ -47,8 +200,19 @@ type client struct {
 // websocket address such as wss://partner.pintu.co.id/ws/v1. It dispatches incoming messages
 // to the incoming channel. To send a message, use the outgoing channel.
 func Connect(addr string, apikey string, apisecret string) (result *client, err error) {
+	return ConnectWithOptions(addr, apikey, apisecret, DefaultConnectOptions())
+}
+
+// ConnectWithOptions connects to the Pintu websocket API like Connect, using the given options.
+func ConnectWithOptions(addr string, apikey string, apisecret string,
+	options ConnectOptions) (result *client, err error) {
 	var conn *websocket.Conn
 
+	if err = options.Validate(); err != nil {
+		err = errors.Wrap(err, "invalid connect options")
+		return
+	}
+
 	uri, err := url.Parse(addr)
 	if err != nil {
 		err = errors.Wrapf(err, "invalid url %s", addr)
@@ -56,35 +220,95 @@ func Connect(addr string, apikey string, apisecret string) (result *client, err
 	}
 
 	// connect
-	ts := time.Now()
+	ts := options.Clock.Now()
 	hostAndPort := uri.Hostname()
 	if uri.Port() != "" {
 		hostAndPort += ":" + uri.Port()
 	}
-	signature := sign(apisecret, "GET", ts, hostAndPort, uri.Path)
+	signature := Sign(apisecret, "GET", ts, hostAndPort, uri.Path)
 
+	proxy, err := options.proxy()
+	if err != nil {
+		return
+	}
 	dialer := &websocket.Dialer{
-		HandshakeTimeout: 5 * time.Second,
+		Proxy:             proxy,
+		TLSClientConfig:   options.TLSConfig,
+		HandshakeTimeout:  options.HandshakeTimeout,
+		ReadBufferSize:    options.ReadBufferSize,
+		WriteBufferSize:   options.WriteBufferSize,
+		EnableCompression: options.EnableCompression,
+	}
+
+	header := options.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
 	}
+	header["ApiKey"] = []string{apikey}
+	header["ApiSign"] = []string{signature}
+	header["ApiTimestamp"] = []string{MicrosTimestamp(ts).String()}
 
-	header := http.Header{
-		"ApiKey":       []string{apikey},
-		"ApiSign":      []string{signature},
-		"ApiTimestamp": []string{MicrosTimestamp(ts).String()},
+	name := options.Name
+	if name == "" {
+		name = uri.Host
 	}
 
 	log.Printf("connecting to %s", addr)
-	if conn, _, err = dialer.Dial(addr, header); err != nil {
+	conn, response, err := dialer.Dial(addr, header)
+	if response != nil {
+		// the Date header is only precise to the second, but it's available even when the
+		// handshake is rejected, such as for a signature stamped by a skewed clock
+		if date, dateErr := http.ParseTime(response.Header.Get("Date")); dateErr == nil {
+			options.Clock.Observe(MicrosTimestamp(date), time.Now())
+		}
+	}
+	if options.Audit != nil {
+		signed := MicrosTimestamp(ts)
+		record := AuditRecord{Conn: name, Kind: AuditConnect, Addr: addr, APIKey: apikey, Signed: &signed}
+		if err != nil {
+			record.Error = err.Error()
+		}
+		if auditErr := options.Audit.Record(record); auditErr != nil {
+			if err == nil {
+				_ = conn.Close()
+				err = errors.Wrapf(auditErr, "unable to record the connect to %s", addr)
+				return
+			}
+			log.Printf("error: unable to record the failed connect to %s: %s", addr, auditErr)
+		}
+	}
+	if err != nil {
 		err = errors.Wrapf(err, "unable to connect to %s", addr)
 		return
 	}
 
 	result = &client{
-		incoming: make(chan []byte, 1000),
-		outgoing: make(chan []byte, 1000),
-		errorC:   make(chan error),
+		incoming: make(chan []byte, options.IncomingBufferSize),
+		outgoing: make(chan []byte, options.OutgoingBufferSize),
+		errorC:   make(chan error, 1),
 		closeC:   make(chan interface{}),
 		conn:     conn,
+		options:  options,
+		limiter:  newRateLimiter(),
+		queue:    newOutgoingQueue(),
+		name:     name,
+	}
+	if options.OverflowPolicy == OverflowSpill {
+		if result.spill, err = newSpillQueue(result, options.SpillDir, options.SpillMaxBytes); err != nil {
+			_ = conn.Close()
+			result = nil
+			return
+		}
+	}
+	c := result
+	incomingDepthMetrics.Set(result.name, expvar.Func(func() interface{} {
+		return c.incomingDepth()
+	}))
+	outgoingDepthMetrics.Set(result.name, expvar.Func(func() interface{} {
+		return len(c.outgoing) + c.queue.len()
+	}))
+	for msgType, limit := range options.RateLimits {
+		result.limiter.set(msgType, limit)
 	}
 	go result.writePump()
 	go result.readPump()
@@ -107,6 +331,17 @@ func (client *client) ErrorChannel() ErrorChannel {
 	return client.errorC
 }
 
+// SetRateLimit limits the rate at which messages of the given type are written to the
+// websocket. Messages over the limit are held in the outgoing queue until tokens are available.
+func (client *client) SetRateLimit(msgType string, limit RateLimit) {
+	client.limiter.set(msgType, limit)
+}
+
+// SetPriority overrides the outgoing queue priority for messages of the given type.
+func (client *client) SetPriority(msgType string, priority Priority) {
+	client.queue.setPriority(msgType, priority)
+}
+
 // Close closes the websocket connection.
 func (client *client) Close() {
 	atomic.StoreInt32(&client.closeRequested, 1)
@@ -114,6 +349,20 @@ func (client *client) Close() {
 	<-client.closeC
 }
 
+// Shutdown closes the websocket connection cleanly. It closes the outgoing channel, so
+// nothing may be sent after it's called, writes any queued messages followed by a close
+// message, and waits up to the timeout for the server to close the connection.
+func (client *client) Shutdown(timeout time.Duration) {
+	atomic.StoreInt32(&client.closeRequested, 1)
+	close(client.outgoing)
+	select {
+	case <-client.closeC:
+	case <-time.After(timeout):
+		log.Printf("timed out waiting for the server to close the connection")
+	}
+	client.Close()
+}
+
 // readPump pumps messages from the websocket connection to the hub.
 //
 // The application runs readPump in a per-connection goroutine. The application
@@ -122,14 +371,17 @@ func (client *client) Close() {
 func (client *client) readPump() {
 	defer func() {
 		_ = client.conn.Close()
+		if client.spill != nil {
+			client.spill.close()
+		}
 	}()
-	client.conn.SetReadLimit(maxMessageSize)
-	if err := client.conn.SetReadDeadline(time.Now().Add(pongWait)); err != nil {
+	client.conn.SetReadLimit(client.options.MaxMessageSize)
+	if err := client.conn.SetReadDeadline(time.Now().Add(client.options.PongWait)); err != nil {
 		client.onError(err)
 		return
 	}
 	client.conn.SetPongHandler(func(string) error {
-		return client.conn.SetReadDeadline(time.Now().Add(pongWait))
+		return client.conn.SetReadDeadline(time.Now().Add(client.options.PongWait))
 	})
 	for {
 		_, message, err := client.conn.ReadMessage()
@@ -137,60 +389,144 @@ func (client *client) readPump() {
 			client.onError(err)
 			return
 		}
-		client.incoming <- message
+		if err = client.audit(AuditIn, message); err != nil {
+			client.onError(err)
+			return
+		}
+		if err = client.deliver(message); err != nil {
+			client.onError(err)
+			return
+		}
 	}
 }
 
+// writePump writes queued messages to the websocket connection in priority order, holding
+// back any message types that have exceeded their rate limit, and pings the peer.
 func (client *client) writePump() {
-	ticker := time.NewTicker(pingPeriod)
+	ticker := time.NewTicker(client.options.PingPeriod)
+	closeConn := true
 	defer func() {
 		ticker.Stop()
-		_ = client.conn.Close()
+		if closeConn {
+			_ = client.conn.Close()
+		}
 	}()
+	outgoing := client.outgoing
 	for {
-		select {
-		case message, ok := <-client.outgoing:
-			if err := client.conn.SetWriteDeadline(time.Now().Add(writeWait)); err != nil {
+		// queue everything that is already waiting so that priorities apply across it
+		for drained := false; !drained && outgoing != nil && client.queue.len() < maxQueuedMessages; {
+			select {
+			case message, ok := <-outgoing:
+				if !ok {
+					// The hub closed the channel, flush the queue before closing.
+					outgoing = nil
+					break
+				}
+				client.queue.push(message)
+			default:
+				drained = true
+			}
+		}
+
+		message, wait := client.queue.pop(client.limiter, time.Now())
+		if message != nil {
+			if err := client.write(message.data); err != nil {
 				client.onError(err)
 				return
 			}
-			if !ok {
-				// The hub closed the channel.
-				if err := client.conn.WriteMessage(websocket.CloseMessage, []byte{}); err != nil {
-					client.onError(err)
+			select {
+			case <-ticker.C:
+				if err := client.ping(); err != nil {
 					return
 				}
-				return
+			default:
 			}
-
-			w, err := client.conn.NextWriter(websocket.TextMessage)
-			if err != nil {
-				return
-			}
-			if _, err = w.Write(message); err != nil {
+			continue
+		}
+		if outgoing == nil && client.queue.len() == 0 {
+			_ = client.conn.SetWriteDeadline(time.Now().Add(client.options.WriteWait))
+			closeMessage := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+			if err := client.conn.WriteMessage(websocket.CloseMessage, closeMessage); err != nil {
 				client.onError(err)
 				return
 			}
-			if err = w.Close(); err != nil {
-				client.onError(err)
-				return
+			// leave the connection open for the read pump to receive the server's close message
+			closeConn = false
+			return
+		}
+
+		var throttle *time.Timer
+		var throttleC <-chan time.Time
+		if wait > 0 {
+			throttle = time.NewTimer(wait)
+			throttleC = throttle.C
+		}
+		// stop reading while the queue is full, so that rate limited messages back up into the channel
+		queueing := outgoing
+		if client.queue.len() >= maxQueuedMessages {
+			queueing = nil
+		}
+		select {
+		case message, ok := <-queueing:
+			if !ok {
+				outgoing = nil
+			} else {
+				client.queue.push(message)
 			}
+		case <-throttleC:
 		case <-ticker.C:
-			_ = client.conn.SetWriteDeadline(time.Now().Add(writeWait))
-			if err := client.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+			if err := client.ping(); err != nil {
 				return
 			}
 		}
+		if throttle != nil {
+			throttle.Stop()
+		}
 	}
 }
 
-func (client *client) onError(err error) {
-	// if there's an error, the connection is closed and can't be re-used
-	close(client.closeC)
-	if atomic.LoadInt32(&client.closeRequested) != 0 {
-		// close was requested, so unblock the caller and don't forward an error
+// write writes a single text message to the websocket connection, once it's recorded.
+func (client *client) write(message []byte) (err error) {
+	if err = client.audit(AuditOut, message); err != nil {
+		return
+	}
+	if err = client.conn.SetWriteDeadline(time.Now().Add(client.options.WriteWait)); err != nil {
+		return
+	}
+	w, err := client.conn.NextWriter(websocket.TextMessage)
+	if err != nil {
+		return
+	}
+	if _, err = w.Write(message); err != nil {
 		return
 	}
-	log.Printf("error: %v", err)
-	client.errorC <- err
+	return w.Close()
+}
+
+// audit records a frame in the audit log, if any.
+func (client *client) audit(kind string, message []byte) error {
+	if client.options.Audit == nil {
+		return nil
+	}
+	return client.options.Audit.Record(AuditRecord{Conn: client.name, Kind: kind, Frame: message})
+}
+
+// ping sends a ping message to the peer.
+func (client *client) ping() error {
+	_ = client.conn.SetWriteDeadline(time.Now().Add(client.options.WriteWait))
+	return client.conn.WriteMessage(websocket.PingMessage, nil)
+}
+
+func (client *client) onError(err error) {
+	// both pumps report errors, but only the first one is forwarded
+	client.closeOnce.Do(func() {
+		// if there's an error, the connection is closed and can't be re-used
+		close(client.closeC)
+		if atomic.LoadInt32(&client.closeRequested) != 0 {
+			// close was requested, so unblock the caller and don't forward an error
+			return
+		}
+		log.Printf("error: %v", err)
+		client.errorC <- err
+	})
 }
@@ -0,0 +1,111 @@
+package client
+
+import (
+	"expvar"
+	"log"
+	"sync"
+	"time"
+)
+
+// clockSamples is the number of recent samples the clock offset is estimated from.
+const clockSamples = 16
+
+// clockOffsetMetrics publishes the estimated offset of the server clock, in milliseconds.
+var clockOffsetMetrics = expvar.NewMap("clock_offset_ms")
+
+// Clock estimates the offset of the server clock from the local clock, from the timestamps of
+// the messages received from the server. The server stamps a message before sending it, so
+// each sample is the offset minus the latency of that message, and the offset is estimated as
+// the largest of the recent samples, the one with the least latency.
+//
+// If compensating, Now returns the local time plus the offset, so that a drifting host clock
+// doesn't fail the signatures or get orders rejected as stale. A nil Clock uses the local time.
+type Clock struct {
+	name       string
+	threshold  time.Duration
+	compensate bool
+	metric     *expvar.Int
+
+	mu      sync.RWMutex
+	samples []time.Duration
+	next    int
+	offset  time.Duration
+	warned  bool
+}
+
+// NewClock returns a clock for the named connection, which warns when the offset exceeds the
+// threshold, and compensates for it if asked to.
+func NewClock(name string, threshold time.Duration, compensate bool) *Clock {
+	c := &Clock{
+		name:       name,
+		threshold:  threshold,
+		compensate: compensate,
+		metric:     new(expvar.Int),
+	}
+	clockOffsetMetrics.Set(name, c.metric)
+	return c
+}
+
+// Observe records a server timestamp received at the given local time.
+func (c *Clock) Observe(server MicrosTimestamp, received time.Time) {
+	if c == nil || time.Time(server).IsZero() {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sample := time.Time(server).Sub(received)
+	if len(c.samples) < clockSamples {
+		c.samples = append(c.samples, sample)
+	} else {
+		c.samples[c.next] = sample
+		c.next = (c.next + 1) % clockSamples
+	}
+	c.offset = c.samples[0]
+	for _, s := range c.samples[1:] {
+		if s > c.offset {
+			c.offset = s
+		}
+	}
+	c.metric.Set(c.offset.Milliseconds())
+
+	skewed := c.threshold > 0 && (c.offset > c.threshold || c.offset < -c.threshold)
+	if skewed && !c.warned {
+		log.Printf("warning: %s clock is %s off the server clock, over the %s threshold", c.name, -c.offset, c.threshold)
+	} else if !skewed && c.warned {
+		log.Printf("%s clock is back within %s of the server clock", c.name, c.threshold)
+	}
+	c.warned = skewed
+}
+
+// Offset returns the estimated offset of the server clock, zero until a sample is observed.
+func (c *Clock) Offset() time.Duration {
+	if c == nil {
+		return 0
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.offset
+}
+
+// Skewed returns whether the offset is over the threshold.
+func (c *Clock) Skewed() bool {
+	if c == nil {
+		return false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.warned
+}
+
+// Now returns the current time, compensated for the offset if enabled.
+func (c *Clock) Now() time.Time {
+	return c.Adjust(time.Now())
+}
+
+// Adjust returns the given local time compensated for the offset if enabled.
+func (c *Clock) Adjust(t time.Time) time.Time {
+	if c == nil || !c.compensate {
+		return t
+	}
+	return t.Add(c.Offset())
+}
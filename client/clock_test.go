@@ -0,0 +1,50 @@
+package client
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClockOffset(t *testing.T) {
+	clock := NewClock("test", time.Second, true)
+	local := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// the server is 3s ahead, and the messages take between 10ms and 200ms to arrive
+	for i, latency := range []time.Duration{200, 10, 50, 120} {
+		received := local.Add(time.Duration(i) * time.Second)
+		server := received.Add(3*time.Second - latency*time.Millisecond)
+		clock.Observe(MicrosTimestamp(server), received)
+	}
+	if offset := clock.Offset(); offset != 3*time.Second-10*time.Millisecond {
+		t.Errorf("got offset %s, expected the sample with the least latency", offset)
+	}
+	if !clock.Skewed() {
+		t.Errorf("offset over the threshold not reported")
+	}
+	if adjusted := clock.Adjust(local); adjusted != local.Add(clock.Offset()) {
+		t.Errorf("got %s, expected the local time plus the offset", adjusted)
+	}
+
+	// once the clock is corrected, the old samples age out
+	for i := 0; i < clockSamples; i++ {
+		received := local.Add(time.Minute + time.Duration(i)*time.Second)
+		clock.Observe(MicrosTimestamp(received.Add(-20*time.Millisecond)), received)
+	}
+	if offset := clock.Offset(); offset != -20*time.Millisecond || clock.Skewed() {
+		t.Errorf("got offset %s skewed %t after the correction", offset, clock.Skewed())
+	}
+}
+
+func TestClockWithoutCompensation(t *testing.T) {
+	clock := NewClock("uncompensated", time.Second, false)
+	now := time.Now()
+	clock.Observe(MicrosTimestamp(now.Add(time.Hour)), now)
+	if clock.Offset() != time.Hour || clock.Adjust(now) != now {
+		t.Errorf("got offset %s and adjusted %s, expected only the estimate", clock.Offset(), clock.Adjust(now))
+	}
+	var none *Clock
+	if none.Adjust(now) != now || none.Offset() != 0 {
+		t.Errorf("a nil clock doesn't use the local time")
+	}
+	none.Observe(MicrosTimestamp(now), now)
+}
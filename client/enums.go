@@ -1,6 +1,10 @@
+// Code generated by enumgen from enums.yaml. DO NOT EDIT.
+
 package client
 
 import (
+	"database/sql/driver"
+	"encoding/json"
 	"fmt"
 	"strings"
 )
@@ -13,6 +17,13 @@ type SideValues struct {
 
 var Side = SideValues{1, 2}
 
+var sideUnknown = newUnknownEnumValues("Side")
+
+// All returns the known Side values.
+func (v SideValues) All() []SideEnum {
+	return []SideEnum{v.Buy, v.Sell}
+}
+
 func SideString(s SideEnum) string {
 	switch s {
 	case Side.Buy:
@@ -20,7 +31,7 @@ func SideString(s SideEnum) string {
 	case Side.Sell:
 		return "Sell"
 	default:
-		return "Unknown"
+		return sideUnknown.String(uint8(s))
 	}
 }
 
@@ -40,15 +51,58 @@ func ParseSide(str string) (s SideEnum, err error) {
 	return
 }
 
+func (e SideEnum) String() string {
+	return SideString(e)
+}
+
+func (e SideEnum) IsUnknown() bool {
+	_, err := ParseSide(SideString(e))
+	return err != nil
+}
+
+func (e SideEnum) MarshalText() ([]byte, error) {
+	if e.IsUnknown() {
+		return []byte(sideUnknown.text(uint8(e))), nil
+	}
+	return []byte(SideString(e)), nil
+}
+
+func (e *SideEnum) UnmarshalText(b []byte) (err error) {
+	if *e, err = ParseSide(string(b)); err != nil {
+		var code uint8
+		code, err = sideUnknown.code(string(b))
+		*e = SideEnum(code)
+	}
+	return
+}
+
 func (e SideEnum) MarshalJSON() ([]byte, error) {
-	return []byte("\"" + SideString(e) + "\""), nil
+	text, _ := e.MarshalText()
+	return json.Marshal(string(text))
 }
 
 func (e *SideEnum) UnmarshalJSON(b []byte) (err error) {
-	*e, err = ParseSide(string(b))
+	if *e, err = ParseSide(string(b)); err != nil {
+		var code uint8
+		code, err = sideUnknown.decode(b)
+		*e = SideEnum(code)
+	}
 	return
 }
 
+func (e SideEnum) Value() (driver.Value, error) {
+	text, _ := e.MarshalText()
+	return string(text), nil
+}
+
+func (e *SideEnum) Scan(src interface{}) (err error) {
+	value, err := sideUnknown.scan(src)
+	if err != nil {
+		return
+	}
+	return e.UnmarshalText([]byte(value))
+}
+
 type ExecTypeEnum uint8
 type ExecTypeValues struct {
 	New             ExecTypeEnum
@@ -69,6 +123,13 @@ type ExecTypeValues struct {
 
 var ExecType = ExecTypeValues{0, 2, 4, 5, 6, 8, 10, 13, 14, 15, 37, 38, 12, 16}
 
+var execTypeUnknown = newUnknownEnumValues("ExecType")
+
+// All returns the known ExecType values.
+func (v ExecTypeValues) All() []ExecTypeEnum {
+	return []ExecTypeEnum{v.New, v.Trade, v.Canceled, v.Replaced, v.PendingCancel, v.Rejected, v.PendingNew, v.Restated, v.PendingReplace, v.DoneForDay, v.CancelRejected, v.ReplaceRejected, v.Expired, v.Stale}
+}
+
 func ExecTypeString(s ExecTypeEnum) string {
 	switch s {
 	case ExecType.New:
@@ -100,19 +161,10 @@ func ExecTypeString(s ExecTypeEnum) string {
 	case ExecType.Stale:
 		return "Stale"
 	default:
-		return "Unknown"
+		return execTypeUnknown.String(uint8(s))
 	}
 }
 
-func (e ExecTypeEnum) MarshalJSON() ([]byte, error) {
-	return []byte("\"" + ExecTypeString(e) + "\""), nil
-}
-
-func (e *ExecTypeEnum) UnmarshalJSON(b []byte) (err error) {
-	*e, err = ParseExecType(string(b))
-	return
-}
-
 func ParseExecType(str string) (s ExecTypeEnum, err error) {
 	if len(str) >= 2 && str[0] == '"' && str[len(str)-1] == '"' {
 		str = str[1 : len(str)-1]
@@ -153,6 +205,58 @@ func ParseExecType(str string) (s ExecTypeEnum, err error) {
 	return
 }
 
+func (e ExecTypeEnum) String() string {
+	return ExecTypeString(e)
+}
+
+func (e ExecTypeEnum) IsUnknown() bool {
+	_, err := ParseExecType(ExecTypeString(e))
+	return err != nil
+}
+
+func (e ExecTypeEnum) MarshalText() ([]byte, error) {
+	if e.IsUnknown() {
+		return []byte(execTypeUnknown.text(uint8(e))), nil
+	}
+	return []byte(ExecTypeString(e)), nil
+}
+
+func (e *ExecTypeEnum) UnmarshalText(b []byte) (err error) {
+	if *e, err = ParseExecType(string(b)); err != nil {
+		var code uint8
+		code, err = execTypeUnknown.code(string(b))
+		*e = ExecTypeEnum(code)
+	}
+	return
+}
+
+func (e ExecTypeEnum) MarshalJSON() ([]byte, error) {
+	text, _ := e.MarshalText()
+	return json.Marshal(string(text))
+}
+
+func (e *ExecTypeEnum) UnmarshalJSON(b []byte) (err error) {
+	if *e, err = ParseExecType(string(b)); err != nil {
+		var code uint8
+		code, err = execTypeUnknown.decode(b)
+		*e = ExecTypeEnum(code)
+	}
+	return
+}
+
+func (e ExecTypeEnum) Value() (driver.Value, error) {
+	text, _ := e.MarshalText()
+	return string(text), nil
+}
+
+func (e *ExecTypeEnum) Scan(src interface{}) (err error) {
+	value, err := execTypeUnknown.scan(src)
+	if err != nil {
+		return
+	}
+	return e.UnmarshalText([]byte(value))
+}
+
 type OrdStatusEnum uint8
 type OrdStatusValues struct {
 	New             OrdStatusEnum
@@ -169,6 +273,13 @@ type OrdStatusValues struct {
 
 var OrdStatus = OrdStatusValues{0, 1, 2, 4, 5, 6, 8, 10, 14, 15}
 
+var ordStatusUnknown = newUnknownEnumValues("OrdStatus")
+
+// All returns the known OrdStatus values.
+func (v OrdStatusValues) All() []OrdStatusEnum {
+	return []OrdStatusEnum{v.New, v.PartiallyFilled, v.Filled, v.Canceled, v.Replaced, v.PendingCancel, v.Rejected, v.PendingNew, v.PendingReplace, v.DoneForDay}
+}
+
 func OrdStatusString(s OrdStatusEnum) string {
 	switch s {
 	case OrdStatus.New:
@@ -192,7 +303,7 @@ func OrdStatusString(s OrdStatusEnum) string {
 	case OrdStatus.DoneForDay:
 		return "DoneForDay"
 	default:
-		return "Unknown"
+		return ordStatusUnknown.String(uint8(s))
 	}
 }
 
@@ -228,15 +339,58 @@ func ParseOrdStatus(str string) (s OrdStatusEnum, err error) {
 	return
 }
 
+func (e OrdStatusEnum) String() string {
+	return OrdStatusString(e)
+}
+
+func (e OrdStatusEnum) IsUnknown() bool {
+	_, err := ParseOrdStatus(OrdStatusString(e))
+	return err != nil
+}
+
+func (e OrdStatusEnum) MarshalText() ([]byte, error) {
+	if e.IsUnknown() {
+		return []byte(ordStatusUnknown.text(uint8(e))), nil
+	}
+	return []byte(OrdStatusString(e)), nil
+}
+
+func (e *OrdStatusEnum) UnmarshalText(b []byte) (err error) {
+	if *e, err = ParseOrdStatus(string(b)); err != nil {
+		var code uint8
+		code, err = ordStatusUnknown.code(string(b))
+		*e = OrdStatusEnum(code)
+	}
+	return
+}
+
 func (e OrdStatusEnum) MarshalJSON() ([]byte, error) {
-	return []byte("\"" + OrdStatusString(e) + "\""), nil
+	text, _ := e.MarshalText()
+	return json.Marshal(string(text))
 }
 
 func (e *OrdStatusEnum) UnmarshalJSON(b []byte) (err error) {
-	*e, err = ParseOrdStatus(string(b))
+	if *e, err = ParseOrdStatus(string(b)); err != nil {
+		var code uint8
+		code, err = ordStatusUnknown.decode(b)
+		*e = OrdStatusEnum(code)
+	}
 	return
 }
 
+func (e OrdStatusEnum) Value() (driver.Value, error) {
+	text, _ := e.MarshalText()
+	return string(text), nil
+}
+
+func (e *OrdStatusEnum) Scan(src interface{}) (err error) {
+	value, err := ordStatusUnknown.scan(src)
+	if err != nil {
+		return
+	}
+	return e.UnmarshalText([]byte(value))
+}
+
 type OrdTypeEnum uint8
 type OrdTypeValues struct {
 	Market OrdTypeEnum
@@ -246,6 +400,13 @@ type OrdTypeValues struct {
 
 var OrdType = OrdTypeValues{1, 2, 3}
 
+var ordTypeUnknown = newUnknownEnumValues("OrdType")
+
+// All returns the known OrdType values.
+func (v OrdTypeValues) All() []OrdTypeEnum {
+	return []OrdTypeEnum{v.Market, v.Limit, v.RFQ}
+}
+
 func OrdTypeString(s OrdTypeEnum) string {
 	switch s {
 	case OrdType.Market:
@@ -255,7 +416,7 @@ func OrdTypeString(s OrdTypeEnum) string {
 	case OrdType.RFQ:
 		return "RFQ"
 	default:
-		return "Unknown"
+		return ordTypeUnknown.String(uint8(s))
 	}
 }
 
@@ -277,15 +438,58 @@ func ParseOrdType(str string) (s OrdTypeEnum, err error) {
 	return
 }
 
+func (e OrdTypeEnum) String() string {
+	return OrdTypeString(e)
+}
+
+func (e OrdTypeEnum) IsUnknown() bool {
+	_, err := ParseOrdType(OrdTypeString(e))
+	return err != nil
+}
+
+func (e OrdTypeEnum) MarshalText() ([]byte, error) {
+	if e.IsUnknown() {
+		return []byte(ordTypeUnknown.text(uint8(e))), nil
+	}
+	return []byte(OrdTypeString(e)), nil
+}
+
+func (e *OrdTypeEnum) UnmarshalText(b []byte) (err error) {
+	if *e, err = ParseOrdType(string(b)); err != nil {
+		var code uint8
+		code, err = ordTypeUnknown.code(string(b))
+		*e = OrdTypeEnum(code)
+	}
+	return
+}
+
 func (e OrdTypeEnum) MarshalJSON() ([]byte, error) {
-	return []byte("\"" + OrdTypeString(e) + "\""), nil
+	text, _ := e.MarshalText()
+	return json.Marshal(string(text))
 }
 
 func (e *OrdTypeEnum) UnmarshalJSON(b []byte) (err error) {
-	*e, err = ParseOrdType(string(b))
+	if *e, err = ParseOrdType(string(b)); err != nil {
+		var code uint8
+		code, err = ordTypeUnknown.decode(b)
+		*e = OrdTypeEnum(code)
+	}
 	return
 }
 
+func (e OrdTypeEnum) Value() (driver.Value, error) {
+	text, _ := e.MarshalText()
+	return string(text), nil
+}
+
+func (e *OrdTypeEnum) Scan(src interface{}) (err error) {
+	value, err := ordTypeUnknown.scan(src)
+	if err != nil {
+		return
+	}
+	return e.UnmarshalText([]byte(value))
+}
+
 type OrdRejReasonEnum uint8
 type OrdRejReasonValues struct {
 	UnknownSymbol                         OrdRejReasonEnum
@@ -305,6 +509,13 @@ type OrdRejReasonValues struct {
 
 var OrdRejReason = OrdRejReasonValues{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13}
 
+var ordRejReasonUnknown = newUnknownEnumValues("OrdRejReason")
+
+// All returns the known OrdRejReason values.
+func (v OrdRejReasonValues) All() []OrdRejReasonEnum {
+	return []OrdRejReasonEnum{v.UnknownSymbol, v.ExchangeClosed, v.OrderExceedsLimit, v.TooLateToEnter, v.UnknownOrder, v.DuplicateOrder, v.DuplicateOfAVerballyCommunicatedOrder, v.StaleOrder, v.UnknownMarket, v.InternalError, v.BrokerOption, v.RateLimit, v.ForceCancel}
+}
+
 func OrdRejReasonString(s OrdRejReasonEnum) string {
 	switch s {
 	case OrdRejReason.UnknownSymbol:
@@ -334,7 +545,7 @@ func OrdRejReasonString(s OrdRejReasonEnum) string {
 	case OrdRejReason.ForceCancel:
 		return "ForceCancel"
 	default:
-		return "Unknown"
+		return ordRejReasonUnknown.String(uint8(s))
 	}
 }
 
@@ -376,15 +587,58 @@ func ParseOrdRejReason(str string) (s OrdRejReasonEnum, err error) {
 	return
 }
 
+func (e OrdRejReasonEnum) String() string {
+	return OrdRejReasonString(e)
+}
+
+func (e OrdRejReasonEnum) IsUnknown() bool {
+	_, err := ParseOrdRejReason(OrdRejReasonString(e))
+	return err != nil
+}
+
+func (e OrdRejReasonEnum) MarshalText() ([]byte, error) {
+	if e.IsUnknown() {
+		return []byte(ordRejReasonUnknown.text(uint8(e))), nil
+	}
+	return []byte(OrdRejReasonString(e)), nil
+}
+
+func (e *OrdRejReasonEnum) UnmarshalText(b []byte) (err error) {
+	if *e, err = ParseOrdRejReason(string(b)); err != nil {
+		var code uint8
+		code, err = ordRejReasonUnknown.code(string(b))
+		*e = OrdRejReasonEnum(code)
+	}
+	return
+}
+
 func (e OrdRejReasonEnum) MarshalJSON() ([]byte, error) {
-	return []byte("\"" + OrdRejReasonString(e) + "\""), nil
+	text, _ := e.MarshalText()
+	return json.Marshal(string(text))
 }
 
 func (e *OrdRejReasonEnum) UnmarshalJSON(b []byte) (err error) {
-	*e, err = ParseOrdRejReason(string(b))
+	if *e, err = ParseOrdRejReason(string(b)); err != nil {
+		var code uint8
+		code, err = ordRejReasonUnknown.decode(b)
+		*e = OrdRejReasonEnum(code)
+	}
 	return
 }
 
+func (e OrdRejReasonEnum) Value() (driver.Value, error) {
+	text, _ := e.MarshalText()
+	return string(text), nil
+}
+
+func (e *OrdRejReasonEnum) Scan(src interface{}) (err error) {
+	value, err := ordRejReasonUnknown.scan(src)
+	if err != nil {
+		return
+	}
+	return e.UnmarshalText([]byte(value))
+}
+
 type CxlRejReasonEnum uint8
 type CxlRejReasonValues struct {
 	UnknownOrder                                      CxlRejReasonEnum
@@ -401,6 +655,13 @@ type CxlRejReasonValues struct {
 
 var CxlRejReason = CxlRejReasonValues{1, 2, 3, 4, 5, 6, 7, 8, 9, 99}
 
+var cxlRejReasonUnknown = newUnknownEnumValues("CxlRejReason")
+
+// All returns the known CxlRejReason values.
+func (v CxlRejReasonValues) All() []CxlRejReasonEnum {
+	return []CxlRejReasonEnum{v.UnknownOrder, v.Broker, v.OrderAlreadyInPendingCancelOrPendingReplaceStatus, v.UnableToProcessOrderMassCancelRequest, v.OrigOrdModTime, v.DuplicateClOrdID, v.TooLateToCancel, v.StaleRequest, v.RateLimit, v.Other}
+}
+
 func CxlRejReasonString(s CxlRejReasonEnum) string {
 	switch s {
 	case CxlRejReason.UnknownOrder:
@@ -424,7 +685,7 @@ func CxlRejReasonString(s CxlRejReasonEnum) string {
 	case CxlRejReason.Other:
 		return "Other"
 	default:
-		return "Unknown"
+		return cxlRejReasonUnknown.String(uint8(s))
 	}
 }
 
@@ -460,15 +721,58 @@ func ParseCxlRejReason(str string) (s CxlRejReasonEnum, err error) {
 	return
 }
 
+func (e CxlRejReasonEnum) String() string {
+	return CxlRejReasonString(e)
+}
+
+func (e CxlRejReasonEnum) IsUnknown() bool {
+	_, err := ParseCxlRejReason(CxlRejReasonString(e))
+	return err != nil
+}
+
+func (e CxlRejReasonEnum) MarshalText() ([]byte, error) {
+	if e.IsUnknown() {
+		return []byte(cxlRejReasonUnknown.text(uint8(e))), nil
+	}
+	return []byte(CxlRejReasonString(e)), nil
+}
+
+func (e *CxlRejReasonEnum) UnmarshalText(b []byte) (err error) {
+	if *e, err = ParseCxlRejReason(string(b)); err != nil {
+		var code uint8
+		code, err = cxlRejReasonUnknown.code(string(b))
+		*e = CxlRejReasonEnum(code)
+	}
+	return
+}
+
 func (e CxlRejReasonEnum) MarshalJSON() ([]byte, error) {
-	return []byte("\"" + CxlRejReasonString(e) + "\""), nil
+	text, _ := e.MarshalText()
+	return json.Marshal(string(text))
 }
 
 func (e *CxlRejReasonEnum) UnmarshalJSON(b []byte) (err error) {
-	*e, err = ParseCxlRejReason(string(b))
+	if *e, err = ParseCxlRejReason(string(b)); err != nil {
+		var code uint8
+		code, err = cxlRejReasonUnknown.decode(b)
+		*e = CxlRejReasonEnum(code)
+	}
 	return
 }
 
+func (e CxlRejReasonEnum) Value() (driver.Value, error) {
+	text, _ := e.MarshalText()
+	return string(text), nil
+}
+
+func (e *CxlRejReasonEnum) Scan(src interface{}) (err error) {
+	value, err := cxlRejReasonUnknown.scan(src)
+	if err != nil {
+		return
+	}
+	return e.UnmarshalText([]byte(value))
+}
+
 type TimeInForceEnum uint8
 type TimeInForceValues struct {
 	GoodTillCancel TimeInForceEnum
@@ -479,6 +783,13 @@ type TimeInForceValues struct {
 
 var TimeInForce = TimeInForceValues{0, 1, 3, 4}
 
+var timeInForceUnknown = newUnknownEnumValues("TimeInForce")
+
+// All returns the known TimeInForce values.
+func (v TimeInForceValues) All() []TimeInForceEnum {
+	return []TimeInForceEnum{v.GoodTillCancel, v.Day, v.FillAndKill, v.FillOrKill}
+}
+
 func TimeInForceString(s TimeInForceEnum) string {
 	switch s {
 	case TimeInForce.GoodTillCancel:
@@ -490,7 +801,7 @@ func TimeInForceString(s TimeInForceEnum) string {
 	case TimeInForce.FillOrKill:
 		return "FillOrKill"
 	default:
-		return "Unknown"
+		return timeInForceUnknown.String(uint8(s))
 	}
 }
 
@@ -514,11 +825,262 @@ func ParseTimeInForce(str string) (s TimeInForceEnum, err error) {
 	return
 }
 
+func (e TimeInForceEnum) String() string {
+	return TimeInForceString(e)
+}
+
+func (e TimeInForceEnum) IsUnknown() bool {
+	_, err := ParseTimeInForce(TimeInForceString(e))
+	return err != nil
+}
+
+func (e TimeInForceEnum) MarshalText() ([]byte, error) {
+	if e.IsUnknown() {
+		return []byte(timeInForceUnknown.text(uint8(e))), nil
+	}
+	return []byte(TimeInForceString(e)), nil
+}
+
+func (e *TimeInForceEnum) UnmarshalText(b []byte) (err error) {
+	if *e, err = ParseTimeInForce(string(b)); err != nil {
+		var code uint8
+		code, err = timeInForceUnknown.code(string(b))
+		*e = TimeInForceEnum(code)
+	}
+	return
+}
+
 func (e TimeInForceEnum) MarshalJSON() ([]byte, error) {
-	return []byte("\"" + TimeInForceString(e) + "\""), nil
+	text, _ := e.MarshalText()
+	return json.Marshal(string(text))
 }
 
 func (e *TimeInForceEnum) UnmarshalJSON(b []byte) (err error) {
-	*e, err = ParseTimeInForce(string(b))
+	if *e, err = ParseTimeInForce(string(b)); err != nil {
+		var code uint8
+		code, err = timeInForceUnknown.decode(b)
+		*e = TimeInForceEnum(code)
+	}
+	return
+}
+
+func (e TimeInForceEnum) Value() (driver.Value, error) {
+	text, _ := e.MarshalText()
+	return string(text), nil
+}
+
+func (e *TimeInForceEnum) Scan(src interface{}) (err error) {
+	value, err := timeInForceUnknown.scan(src)
+	if err != nil {
+		return
+	}
+	return e.UnmarshalText([]byte(value))
+}
+
+type TradeStatusEnum uint8
+type TradeStatusValues struct {
+	Pending   TradeStatusEnum
+	Confirmed TradeStatusEnum
+	Canceled  TradeStatusEnum
+}
+
+var TradeStatus = TradeStatusValues{0, 1, 2}
+
+var tradeStatusUnknown = newUnknownEnumValues("TradeStatus")
+
+// All returns the known TradeStatus values.
+func (v TradeStatusValues) All() []TradeStatusEnum {
+	return []TradeStatusEnum{v.Pending, v.Confirmed, v.Canceled}
+}
+
+func TradeStatusString(s TradeStatusEnum) string {
+	switch s {
+	case TradeStatus.Pending:
+		return "Pending"
+	case TradeStatus.Confirmed:
+		return "Confirmed"
+	case TradeStatus.Canceled:
+		return "Canceled"
+	default:
+		return tradeStatusUnknown.String(uint8(s))
+	}
+}
+
+func ParseTradeStatus(str string) (s TradeStatusEnum, err error) {
+	if len(str) >= 2 && str[0] == '"' && str[len(str)-1] == '"' {
+		str = str[1 : len(str)-1]
+	}
+	upper := strings.ToUpper(str)
+	switch upper {
+	case "PENDING":
+		s = TradeStatus.Pending
+	case "CONFIRMED":
+		s = TradeStatus.Confirmed
+	case "CANCELED":
+		s = TradeStatus.Canceled
+	default:
+		err = fmt.Errorf("invalid TradeStatus %s", str)
+	}
+	return
+}
+
+func (e TradeStatusEnum) String() string {
+	return TradeStatusString(e)
+}
+
+func (e TradeStatusEnum) IsUnknown() bool {
+	_, err := ParseTradeStatus(TradeStatusString(e))
+	return err != nil
+}
+
+func (e TradeStatusEnum) MarshalText() ([]byte, error) {
+	if e.IsUnknown() {
+		return []byte(tradeStatusUnknown.text(uint8(e))), nil
+	}
+	return []byte(TradeStatusString(e)), nil
+}
+
+func (e *TradeStatusEnum) UnmarshalText(b []byte) (err error) {
+	if *e, err = ParseTradeStatus(string(b)); err != nil {
+		var code uint8
+		code, err = tradeStatusUnknown.code(string(b))
+		*e = TradeStatusEnum(code)
+	}
 	return
 }
+
+func (e TradeStatusEnum) MarshalJSON() ([]byte, error) {
+	text, _ := e.MarshalText()
+	return json.Marshal(string(text))
+}
+
+func (e *TradeStatusEnum) UnmarshalJSON(b []byte) (err error) {
+	if *e, err = ParseTradeStatus(string(b)); err != nil {
+		var code uint8
+		code, err = tradeStatusUnknown.decode(b)
+		*e = TradeStatusEnum(code)
+	}
+	return
+}
+
+func (e TradeStatusEnum) Value() (driver.Value, error) {
+	text, _ := e.MarshalText()
+	return string(text), nil
+}
+
+func (e *TradeStatusEnum) Scan(src interface{}) (err error) {
+	value, err := tradeStatusUnknown.scan(src)
+	if err != nil {
+		return
+	}
+	return e.UnmarshalText([]byte(value))
+}
+
+type QuoteStatusEnum uint8
+type QuoteStatusValues struct {
+	PendingNew QuoteStatusEnum
+	Open       QuoteStatusEnum
+	Filled     QuoteStatusEnum
+	Canceled   QuoteStatusEnum
+	Rejected   QuoteStatusEnum
+}
+
+var QuoteStatus = QuoteStatusValues{0, 1, 2, 4, 8}
+
+var quoteStatusUnknown = newUnknownEnumValues("QuoteStatus")
+
+// All returns the known QuoteStatus values.
+func (v QuoteStatusValues) All() []QuoteStatusEnum {
+	return []QuoteStatusEnum{v.PendingNew, v.Open, v.Filled, v.Canceled, v.Rejected}
+}
+
+func QuoteStatusString(s QuoteStatusEnum) string {
+	switch s {
+	case QuoteStatus.PendingNew:
+		return "PendingNew"
+	case QuoteStatus.Open:
+		return "Open"
+	case QuoteStatus.Filled:
+		return "Filled"
+	case QuoteStatus.Canceled:
+		return "Canceled"
+	case QuoteStatus.Rejected:
+		return "Rejected"
+	default:
+		return quoteStatusUnknown.String(uint8(s))
+	}
+}
+
+func ParseQuoteStatus(str string) (s QuoteStatusEnum, err error) {
+	if len(str) >= 2 && str[0] == '"' && str[len(str)-1] == '"' {
+		str = str[1 : len(str)-1]
+	}
+	upper := strings.ToUpper(str)
+	switch upper {
+	case "PENDINGNEW":
+		s = QuoteStatus.PendingNew
+	case "OPEN":
+		s = QuoteStatus.Open
+	case "FILLED":
+		s = QuoteStatus.Filled
+	case "CANCELED":
+		s = QuoteStatus.Canceled
+	case "REJECTED":
+		s = QuoteStatus.Rejected
+	default:
+		err = fmt.Errorf("invalid QuoteStatus %s", str)
+	}
+	return
+}
+
+func (e QuoteStatusEnum) String() string {
+	return QuoteStatusString(e)
+}
+
+func (e QuoteStatusEnum) IsUnknown() bool {
+	_, err := ParseQuoteStatus(QuoteStatusString(e))
+	return err != nil
+}
+
+func (e QuoteStatusEnum) MarshalText() ([]byte, error) {
+	if e.IsUnknown() {
+		return []byte(quoteStatusUnknown.text(uint8(e))), nil
+	}
+	return []byte(QuoteStatusString(e)), nil
+}
+
+func (e *QuoteStatusEnum) UnmarshalText(b []byte) (err error) {
+	if *e, err = ParseQuoteStatus(string(b)); err != nil {
+		var code uint8
+		code, err = quoteStatusUnknown.code(string(b))
+		*e = QuoteStatusEnum(code)
+	}
+	return
+}
+
+func (e QuoteStatusEnum) MarshalJSON() ([]byte, error) {
+	text, _ := e.MarshalText()
+	return json.Marshal(string(text))
+}
+
+func (e *QuoteStatusEnum) UnmarshalJSON(b []byte) (err error) {
+	if *e, err = ParseQuoteStatus(string(b)); err != nil {
+		var code uint8
+		code, err = quoteStatusUnknown.decode(b)
+		*e = QuoteStatusEnum(code)
+	}
+	return
+}
+
+func (e QuoteStatusEnum) Value() (driver.Value, error) {
+	text, _ := e.MarshalText()
+	return string(text), nil
+}
+
+func (e *QuoteStatusEnum) Scan(src interface{}) (err error) {
+	value, err := quoteStatusUnknown.scan(src)
+	if err != nil {
+		return
+	}
+	return e.UnmarshalText([]byte(value))
+}
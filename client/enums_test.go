@@ -0,0 +1,96 @@
+package client
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestUnknownEnumRoundTrip(t *testing.T) {
+	report := &ExecutionReport{}
+	data := []byte(`{"ExecType":"Triggered","OrdStatus":"Filled","Side":"Lend","TimeInForce":"GoodTillCancel"}`)
+	if err := json.Unmarshal(data, report); err != nil {
+		t.Fatalf("unknown values failed the decode: %s", err)
+	}
+	if !report.ExecType.IsUnknown() || !report.Side.IsUnknown() {
+		t.Errorf("unknown values not reported as unknown")
+	}
+	if report.OrdStatus.IsUnknown() || report.OrdStatus != OrdStatus.Filled {
+		t.Errorf("got OrdStatus %s, expected Filled", OrdStatusString(report.OrdStatus))
+	}
+	if s := ExecTypeString(report.ExecType); s != "Unknown(Triggered)" {
+		t.Errorf("got ExecType %s, expected Unknown(Triggered)", s)
+	}
+
+	// the same unknown value always gets the same code, and distinct ones don't collide
+	other := &ExecutionReport{}
+	if err := json.Unmarshal([]byte(`{"ExecType":"Triggered","Side":"Borrow"}`), other); err != nil {
+		t.Fatal(err)
+	}
+	if other.ExecType != report.ExecType {
+		t.Errorf("same unknown ExecType decoded to different codes")
+	}
+	if other.Side == report.Side {
+		t.Errorf("different unknown Sides decoded to the same code")
+	}
+
+	encoded, err := json.Marshal(struct {
+		ExecType ExecTypeEnum
+		Side     SideEnum
+		Known    SideEnum
+	}{report.ExecType, report.Side, Side.Buy})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"ExecType":"Triggered","Side":"Lend","Known":"Buy"}`; string(encoded) != expected {
+		t.Errorf("got %s, expected %s", encoded, expected)
+	}
+}
+
+func TestUnknownEnumParse(t *testing.T) {
+	// parsing user input still rejects unknown values, only decoding messages keeps them
+	if _, err := ParseExecType("Triggered"); err == nil {
+		t.Errorf("ParseExecType accepted an unknown value")
+	}
+	var side SideEnum
+	for _, invalid := range []string{`""`, `null`, `12`} {
+		if err := json.Unmarshal([]byte(invalid), &side); err == nil {
+			t.Errorf("decoded %s as a Side", invalid)
+		}
+	}
+}
+
+func TestEnumSQL(t *testing.T) {
+	var status TradeStatusEnum
+	if err := status.Scan([]byte("Confirmed")); err != nil || status != TradeStatus.Confirmed {
+		t.Errorf("got %s %v, expected Confirmed", status, err)
+	}
+	if err := status.Scan("Settled"); err != nil || !status.IsUnknown() {
+		t.Errorf("got %s %v, expected an unknown status", status, err)
+	}
+	if value, err := status.Value(); err != nil || value != "Settled" {
+		t.Errorf("got value %v %v, expected the unknown value unchanged", value, err)
+	}
+	if err := status.Scan(12); err == nil {
+		t.Errorf("scanned an integer into a TradeStatus")
+	}
+	if value, _ := TimeInForce.FillOrKill.Value(); value != "FillOrKill" {
+		t.Errorf("got value %v, expected FillOrKill", value)
+	}
+}
+
+func TestEnumAll(t *testing.T) {
+	all := OrdStatus.All()
+	if len(all) != 10 || all[0] != OrdStatus.New || all[len(all)-1] != OrdStatus.DoneForDay {
+		t.Errorf("got %v, expected every OrdStatus in spec order", all)
+	}
+	for _, status := range all {
+		text, err := status.MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var parsed OrdStatusEnum
+		if err = parsed.UnmarshalText(text); err != nil || parsed != status || parsed.IsUnknown() {
+			t.Errorf("%s didn't round trip as text: got %s %v", status, parsed, err)
+		}
+	}
+}
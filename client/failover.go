@@ -0,0 +1,120 @@
+package client
+
+import (
+	"expvar"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// activeAddrMetrics publishes the address each connection currently uses.
+	activeAddrMetrics = expvar.NewMap("active_addr")
+
+	// failoverMetrics counts the switches to another address per connection.
+	failoverMetrics = expvar.NewMap("failovers")
+)
+
+// Failover selects the address to connect to from an ordered list, the first one being the
+// primary. After maxFailures consecutive failures on an address it moves on to the next one,
+// wrapping around at the end of the list. It is safe for concurrent use.
+type Failover struct {
+	name        string
+	addrs       []string
+	maxFailures int
+	active      *expvar.String
+
+	mu       sync.Mutex
+	current  int
+	failures int
+}
+
+// NewFailover returns a failover over the given addresses for the named connection.
+func NewFailover(name string, addrs []string, maxFailures int) *Failover {
+	f := &Failover{
+		name:        name,
+		addrs:       addrs,
+		maxFailures: maxFailures,
+		active:      new(expvar.String),
+	}
+	f.active.Set(addrs[0])
+	activeAddrMetrics.Set(name, f.active)
+	return f
+}
+
+// Addr returns the address to connect to.
+func (f *Failover) Addr() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.addrs[f.current]
+}
+
+// Primary returns the first address.
+func (f *Failover) Primary() string {
+	return f.addrs[0]
+}
+
+// OnPrimary returns whether the primary address is in use.
+func (f *Failover) OnPrimary() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.current == 0
+}
+
+// Succeeded resets the failures of the current address, once a connection proved healthy.
+func (f *Failover) Succeeded() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures = 0
+}
+
+// Failed records a failed connection to the current address, and moves to the next address
+// after too many in a row. It returns the address to connect to next.
+func (f *Failover) Failed() (addr string, switched bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures++
+	if f.failures >= f.maxFailures && len(f.addrs) > 1 {
+		f.use((f.current + 1) % len(f.addrs))
+		switched = true
+	}
+	return f.addrs[f.current], switched
+}
+
+// FailBack moves back to the primary address.
+func (f *Failover) FailBack() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.current != 0 {
+		f.use(0)
+	}
+}
+
+// use switches to the given address.
+func (f *Failover) use(index int) {
+	log.Printf("%s failing over from %s to %s", f.name, f.addrs[f.current], f.addrs[index])
+	f.current = index
+	f.failures = 0
+	f.active.Set(f.addrs[index])
+	failoverMetrics.Add(f.name, 1)
+}
+
+// Probe checks that the given address accepts a connection and says hello within the timeout,
+// then closes the connection.
+func Probe(addr string, apikey string, apisecret string, options ConnectOptions, timeout time.Duration) (err error) {
+	probe, err := ConnectWithOptions(addr, apikey, apisecret, options)
+	if err != nil {
+		return
+	}
+	defer probe.Close()
+	select {
+	case <-probe.IncomingChannel():
+	case err = <-probe.ErrorChannel():
+		err = errors.Wrapf(err, "probe of %s failed", addr)
+	case <-time.After(timeout):
+		err = errors.Errorf("no hello from %s after %s", addr, timeout)
+	}
+	return
+}
@@ -0,0 +1,82 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestFailover(t *testing.T) {
+	f := NewFailover("test", []string{"wss://primary", "wss://secondary", "wss://tertiary"}, 2)
+	if f.Addr() != "wss://primary" || !f.OnPrimary() {
+		t.Fatalf("got %s, expected to start on the primary", f.Addr())
+	}
+	steps := []struct {
+		succeeded bool
+		addr      string
+		switched  bool
+	}{
+		{false, "wss://primary", false},
+		{false, "wss://secondary", true},
+		// a healthy connection resets the failures
+		{false, "wss://secondary", false},
+		{true, "wss://secondary", false},
+		{false, "wss://secondary", false},
+		{false, "wss://tertiary", true},
+		{false, "wss://tertiary", false},
+		// wraps around to the primary
+		{false, "wss://primary", true},
+	}
+	for i, step := range steps {
+		if step.succeeded {
+			f.Succeeded()
+			continue
+		}
+		if addr, switched := f.Failed(); addr != step.addr || switched != step.switched {
+			t.Errorf("step %d: got %s switched %t, expected %s switched %t", i, addr, switched, step.addr, step.switched)
+		}
+	}
+
+	f.Failed()
+	f.Failed()
+	if f.OnPrimary() {
+		t.Fatal("still on the primary")
+	}
+	f.FailBack()
+	if f.Addr() != "wss://primary" || !f.OnPrimary() {
+		t.Errorf("got %s after failing back", f.Addr())
+	}
+
+	single := NewFailover("single", []string{"wss://only"}, 1)
+	if addr, switched := single.Failed(); addr != "wss://only" || switched {
+		t.Errorf("got %s switched %t with a single address", addr, switched)
+	}
+}
+
+func TestProbe(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if r.URL.Path == "/hello" {
+			_ = conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"hello","session_id":"S1"}`))
+		}
+		_, _, _ = conn.ReadMessage()
+	}))
+	defer server.Close()
+	addr := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	if err := Probe(addr+"/hello", "key", "secret", DefaultConnectOptions(), time.Second); err != nil {
+		t.Errorf("probe of a healthy address failed: %s", err)
+	}
+	if err := Probe(addr+"/silent", "key", "secret", DefaultConnectOptions(), 50*time.Millisecond); err == nil {
+		t.Errorf("probe of an address that never says hello succeeded")
+	}
+}
@@ -0,0 +1,3 @@
+package client
+
+//go:generate go run ./internal/enumgen -spec enums.yaml -out enums.go
@@ -0,0 +1,157 @@
+package client
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Connection is the part of a websocket client used to exchange messages.
+type Connection interface {
+	IncomingChannel() IncomingChannel
+	OutgoingChannel() OutgoingChannel
+	ErrorChannel() ErrorChannel
+}
+
+// History iterates over the elements of a stream between a start and an end date. The
+// subscription replays the window and then continues live, so the iteration completes at the
+// first element after the end date, or once no element arrives for the idle timeout, and the
+// subscription is then ended. It reads every message of the connection, which must not be
+// shared with a handler.
+//
+//	history, err := client.NewHistory(conn, 1, "Trade", start, end, 5*time.Second)
+//	for history.Next() {
+//		trade := &client.Trade{}
+//		err = history.Decode(trade)
+//	}
+//	err = history.Err()
+type History struct {
+	conn      Connection
+	requestID int64
+	end       time.Time
+	idle      time.Duration
+
+	pending []json.RawMessage
+	current json.RawMessage
+	seq     int64
+	done    bool
+	err     error
+}
+
+// NewHistory subscribes to the stream from the start to the end date, with the given request ID.
+func NewHistory(conn Connection, requestID int64, stream string, start time.Time, end time.Time,
+	idle time.Duration) (result *History, err error) {
+	if !start.Before(end) {
+		err = errors.Errorf("the start %s of the %s history is not before its end %s", start, stream, end)
+		return
+	}
+	if idle <= 0 {
+		err = errors.New("the history idle timeout must be positive")
+		return
+	}
+	startDate, endDate := MicrosTimestamp(start), MicrosTimestamp(end)
+	data, err := json.Marshal(NewSubscribeRequest(time.Now(), requestID,
+		StreamParameters{Name: stream, StartDate: &startDate, EndDate: &endDate}))
+	if err != nil {
+		err = errors.Wrap(err, "unable to encode subscribe request")
+		return
+	}
+	conn.OutgoingChannel() <- data
+	result = &History{conn: conn, requestID: requestID, end: end, idle: idle}
+	return
+}
+
+// Next advances to the next element, and returns false once the window is exhausted or on error.
+func (h *History) Next() bool {
+	for len(h.pending) == 0 {
+		if h.done {
+			return false
+		}
+		h.receive()
+	}
+	// an element after the end date means the replay is over
+	element := struct {
+		Timestamp MicrosTimestamp
+	}{}
+	if err := json.Unmarshal(h.pending[0], &element); err != nil {
+		h.finish(errors.Wrap(err, "unable to decode the timestamp of a history element"))
+		return false
+	}
+	if time.Time(element.Timestamp).After(h.end) {
+		h.finish(nil)
+		return false
+	}
+	h.current, h.pending = h.pending[0], h.pending[1:]
+	return true
+}
+
+// Data returns the current element.
+func (h *History) Data() json.RawMessage {
+	return h.current
+}
+
+// Decode decodes the current element into the given value.
+func (h *History) Decode(v interface{}) error {
+	return json.Unmarshal(h.current, v)
+}
+
+// Err returns the error that ended the iteration, if any.
+func (h *History) Err() error {
+	return h.err
+}
+
+// Close ends the subscription, if the iteration didn't complete.
+func (h *History) Close() {
+	h.finish(nil)
+}
+
+// receive waits for the next response to the subscription.
+func (h *History) receive() {
+	timer := time.NewTimer(h.idle)
+	defer timer.Stop()
+	for {
+		select {
+		case msg := <-h.conn.IncomingChannel():
+			response := &Response{}
+			if err := json.Unmarshal(msg, response); err != nil || response.ReqID != h.requestID {
+				// not a response to the subscription, such as the hello
+				continue
+			}
+			switch {
+			case response.Error != nil:
+				h.finish(errors.Errorf("history request failed: %s (%d)", response.Error.Message, response.Error.Code))
+			case response.Seq != 0 && h.seq != 0 && response.Seq != h.seq+1:
+				h.finish(errors.Errorf("missed %d messages of the history", response.Seq-h.seq-1))
+			default:
+				if response.Seq != 0 {
+					h.seq = response.Seq
+				}
+				h.pending = append(h.pending, response.Data...)
+			}
+			return
+		case err := <-h.conn.ErrorChannel():
+			// the connection is gone, so there's no subscription left to end
+			h.done = true
+			h.err = errors.Wrap(err, "connection failed during the history")
+			h.pending = nil
+			return
+		case <-timer.C:
+			h.finish(nil)
+			return
+		}
+	}
+}
+
+// finish ends the iteration with the given error and the subscription.
+func (h *History) finish(err error) {
+	if h.done {
+		return
+	}
+	h.done = true
+	h.err = err
+	h.pending = nil
+	if data, encodeErr := json.Marshal(NewUnsubscribeRequest(time.Now(), h.requestID)); encodeErr == nil {
+		h.conn.OutgoingChannel() <- data
+	}
+}
@@ -0,0 +1,165 @@
+package client
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// fakeConnection is a connection whose messages are exchanged by the test.
+type fakeConnection struct {
+	incoming chan []byte
+	outgoing chan []byte
+	errors   chan error
+}
+
+func newFakeConnection() *fakeConnection {
+	return &fakeConnection{
+		incoming: make(chan []byte, 10),
+		outgoing: make(chan []byte, 10),
+		errors:   make(chan error, 1),
+	}
+}
+
+func (c *fakeConnection) IncomingChannel() IncomingChannel { return c.incoming }
+func (c *fakeConnection) OutgoingChannel() OutgoingChannel { return c.outgoing }
+func (c *fakeConnection) ErrorChannel() ErrorChannel       { return c.errors }
+
+// sent returns the type of the next message sent, or an empty string if there's none.
+func (c *fakeConnection) sent() string {
+	select {
+	case data := <-c.outgoing:
+		header := struct {
+			Type string `json:"type"`
+		}{}
+		_ = json.Unmarshal(data, &header)
+		return header.Type
+	default:
+		return ""
+	}
+}
+
+var (
+	historyStart = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	historyEnd   = time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+)
+
+// collect iterates over the history and returns the trade IDs.
+func collect(t *testing.T, history *History) (tradeIDs []string) {
+	for history.Next() {
+		trade := &Trade{}
+		if err := history.Decode(trade); err != nil {
+			t.Fatal(err)
+		}
+		tradeIDs = append(tradeIDs, trade.TradeID)
+	}
+	return
+}
+
+func TestHistory(t *testing.T) {
+	conn := newFakeConnection()
+	history, err := NewHistory(conn, 3, "Trade", historyStart, historyEnd, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent := conn.sent(); sent != "subscribe" {
+		t.Fatalf("sent %q, expected a subscribe", sent)
+	}
+	conn.incoming <- []byte(`{"reqid":3,"seq":1,"type":"Trade","data":[
+		{"Timestamp":"2026-01-01T10:00:00.000000Z","TradeID":"T1"},
+		{"Timestamp":"2026-01-01T11:00:00.000000Z","TradeID":"T2"}]}`)
+	// responses to other requests are skipped
+	conn.incoming <- []byte(`{"reqid":1,"seq":1,"type":"Trade","data":[{"TradeID":"X"}]}`)
+	conn.incoming <- []byte(`{"reqid":3,"seq":2,"type":"Trade","data":[
+		{"Timestamp":"2026-01-01T12:00:00.000000Z","TradeID":"T3"},
+		{"Timestamp":"2026-01-02T00:00:01.000000Z","TradeID":"live"}]}`)
+
+	tradeIDs := collect(t, history)
+	if strings.Join(tradeIDs, ",") != "T1,T2,T3" || history.Err() != nil {
+		t.Errorf("got %v and error %v, expected T1,T2,T3", tradeIDs, history.Err())
+	}
+	// the live subscription is ended once, even if closed again
+	history.Close()
+	if sent := conn.sent(); sent != "unsubscribe" {
+		t.Errorf("sent %q, expected an unsubscribe", sent)
+	}
+	if sent := conn.sent(); sent != "" {
+		t.Errorf("sent another %q", sent)
+	}
+}
+
+func TestHistoryIdle(t *testing.T) {
+	conn := newFakeConnection()
+	history, err := NewHistory(conn, 1, "Trade", historyStart, historyEnd, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.sent()
+	conn.incoming <- []byte(`{"reqid":1,"seq":1,"type":"Trade","data":[
+		{"Timestamp":"2026-01-01T10:00:00.000000Z","TradeID":"T1"}]}`)
+
+	// nothing after the window has been seen, so the iteration ends when no more trades arrive
+	if tradeIDs := collect(t, history); len(tradeIDs) != 1 || history.Err() != nil {
+		t.Errorf("got %v and error %v, expected T1", tradeIDs, history.Err())
+	}
+	if sent := conn.sent(); sent != "unsubscribe" {
+		t.Errorf("sent %q, expected an unsubscribe", sent)
+	}
+}
+
+func TestHistoryErrors(t *testing.T) {
+	if _, err := NewHistory(newFakeConnection(), 1, "Trade", historyEnd, historyStart, time.Second); err == nil {
+		t.Error("accepted a window ending before it starts")
+	}
+
+	tests := []struct {
+		name     string
+		messages []string
+		expected string
+	}{
+		{"gap", []string{
+			`{"reqid":1,"seq":1,"type":"Trade","data":[{"Timestamp":"2026-01-01T10:00:00.000000Z","TradeID":"T1"}]}`,
+			`{"reqid":1,"seq":4,"type":"Trade","data":[{"Timestamp":"2026-01-01T11:00:00.000000Z","TradeID":"T4"}]}`,
+		}, "missed 2 messages"},
+		{"error response", []string{
+			`{"reqid":1,"type":"error","error":{"code":400,"msg":"bad stream"}}`,
+		}, "bad stream"},
+	}
+	for _, test := range tests {
+		conn := newFakeConnection()
+		history, err := NewHistory(conn, 1, "Trade", historyStart, historyEnd, time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.sent()
+		for _, msg := range test.messages {
+			conn.incoming <- []byte(msg)
+		}
+		collect(t, history)
+		if err = history.Err(); err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("%s: got error %v, expected %q", test.name, err, test.expected)
+		}
+		if sent := conn.sent(); sent != "unsubscribe" {
+			t.Errorf("%s: sent %q, expected an unsubscribe", test.name, sent)
+		}
+	}
+
+	// a failed connection ends the iteration without an unsubscribe
+	conn := newFakeConnection()
+	history, err := NewHistory(conn, 1, "Trade", historyStart, historyEnd, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.sent()
+	conn.errors <- errors.New("connection reset")
+	if history.Next() || history.Err() == nil {
+		t.Errorf("got error %v, expected the connection error", history.Err())
+	}
+	history.Close()
+	if sent := conn.sent(); sent != "" {
+		t.Errorf("sent %q on a failed connection", sent)
+	}
+}
@@ -0,0 +1,220 @@
+// enumgen generates the enum types of the client package from a YAML spec. It is run by
+// go generate in the client package.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// minUnknownCode is the lowest code allocated to unknown values, see client.minUnknownCode.
+const minUnknownCode = 128
+
+var specPath = flag.String("spec", "enums.yaml", "Path to the enum spec")
+var outPath = flag.String("out", "enums.go", "Path to the generated file")
+
+// Enum is an enum in the spec.
+type Enum struct {
+	Name   string  `yaml:"name"`
+	Values []Value `yaml:"values"`
+}
+
+// Value is a value of an enum, with its numeric code.
+type Value struct {
+	Name string `yaml:"name"`
+	Code int    `yaml:"code"`
+}
+
+// Lower returns the enum name starting with a lower case letter.
+func (e Enum) Lower() string {
+	return strings.ToLower(e.Name[:1]) + e.Name[1:]
+}
+
+// Upper returns the value name in upper case, as matched when parsing.
+func (v Value) Upper() string {
+	return strings.ToUpper(v.Name)
+}
+
+func main() {
+	flag.Parse()
+	if err := generate(*specPath, *outPath); err != nil {
+		log.Fatalf("enumgen: %s", err)
+	}
+}
+
+func generate(specPath string, outPath string) (err error) {
+	data, err := os.ReadFile(specPath)
+	if err != nil {
+		return errors.Wrapf(err, "unable to read spec %s", specPath)
+	}
+	var enums []Enum
+	if err = yaml.Unmarshal(data, &enums); err != nil {
+		return errors.Wrapf(err, "unable to decode spec %s", specPath)
+	}
+	if err = validate(enums); err != nil {
+		return errors.Wrapf(err, "invalid spec %s", specPath)
+	}
+	var out bytes.Buffer
+	if err = enumTemplate.Execute(&out, struct {
+		Spec  string
+		Enums []Enum
+	}{specPath, enums}); err != nil {
+		return errors.Wrap(err, "unable to generate enums")
+	}
+	source, err := format.Source(out.Bytes())
+	if err != nil {
+		return errors.Wrap(err, "unable to format the generated enums")
+	}
+	return os.WriteFile(outPath, source, 0644)
+}
+
+// validate checks that the names and codes are unique, and that the codes don't clash with
+// the codes allocated to unknown values.
+func validate(enums []Enum) error {
+	enumNames := make(map[string]bool)
+	for _, enum := range enums {
+		if enum.Name == "" || len(enum.Values) == 0 {
+			return errors.New("every enum needs a name and values")
+		}
+		if enumNames[enum.Name] {
+			return fmt.Errorf("duplicate enum %s", enum.Name)
+		}
+		enumNames[enum.Name] = true
+		names := make(map[string]bool)
+		codes := make(map[int]bool)
+		for _, value := range enum.Values {
+			if value.Name == "" || strings.HasPrefix(value.Upper(), "UNKNOWN(") {
+				return fmt.Errorf("invalid %s value %q", enum.Name, value.Name)
+			}
+			if names[value.Upper()] {
+				return fmt.Errorf("duplicate %s value %s", enum.Name, value.Name)
+			}
+			if value.Code < 0 || value.Code >= minUnknownCode {
+				return fmt.Errorf("%s value %s code %d must be between 0 and %d",
+					enum.Name, value.Name, value.Code, minUnknownCode-1)
+			}
+			if codes[value.Code] {
+				return fmt.Errorf("duplicate %s code %d", enum.Name, value.Code)
+			}
+			names[value.Upper()] = true
+			codes[value.Code] = true
+		}
+	}
+	return nil
+}
+
+var enumTemplate = template.Must(template.New("enums").Parse(`// Code generated by enumgen from {{.Spec}}. DO NOT EDIT.
+
+package client
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+{{range .Enums}}{{$enum := .}}
+type {{.Name}}Enum uint8
+type {{.Name}}Values struct {
+{{- range .Values}}
+	{{.Name}} {{$enum.Name}}Enum
+{{- end}}
+}
+
+var {{.Name}} = {{.Name}}Values{ {{- range $i, $v := .Values}}{{if $i}}, {{end}}{{$v.Code}}{{end -}} }
+
+var {{.Lower}}Unknown = newUnknownEnumValues("{{.Name}}")
+
+// All returns the known {{.Name}} values.
+func (v {{.Name}}Values) All() []{{.Name}}Enum {
+	return []{{.Name}}Enum{ {{- range $i, $v := .Values}}{{if $i}}, {{end}}v.{{$v.Name}}{{end -}} }
+}
+
+func {{.Name}}String(s {{.Name}}Enum) string {
+	switch s {
+{{- range .Values}}
+	case {{$enum.Name}}.{{.Name}}:
+		return "{{.Name}}"
+{{- end}}
+	default:
+		return {{.Lower}}Unknown.String(uint8(s))
+	}
+}
+
+func Parse{{.Name}}(str string) (s {{.Name}}Enum, err error) {
+	if len(str) >= 2 && str[0] == '"' && str[len(str)-1] == '"' {
+		str = str[1 : len(str)-1]
+	}
+	upper := strings.ToUpper(str)
+	switch upper {
+{{- range .Values}}
+	case "{{.Upper}}":
+		s = {{$enum.Name}}.{{.Name}}
+{{- end}}
+	default:
+		err = fmt.Errorf("invalid {{.Name}} %s", str)
+	}
+	return
+}
+
+func (e {{.Name}}Enum) String() string {
+	return {{.Name}}String(e)
+}
+
+func (e {{.Name}}Enum) IsUnknown() bool {
+	_, err := Parse{{.Name}}({{.Name}}String(e))
+	return err != nil
+}
+
+func (e {{.Name}}Enum) MarshalText() ([]byte, error) {
+	if e.IsUnknown() {
+		return []byte({{.Lower}}Unknown.text(uint8(e))), nil
+	}
+	return []byte({{.Name}}String(e)), nil
+}
+
+func (e *{{.Name}}Enum) UnmarshalText(b []byte) (err error) {
+	if *e, err = Parse{{.Name}}(string(b)); err != nil {
+		var code uint8
+		code, err = {{.Lower}}Unknown.code(string(b))
+		*e = {{.Name}}Enum(code)
+	}
+	return
+}
+
+func (e {{.Name}}Enum) MarshalJSON() ([]byte, error) {
+	text, _ := e.MarshalText()
+	return json.Marshal(string(text))
+}
+
+func (e *{{.Name}}Enum) UnmarshalJSON(b []byte) (err error) {
+	if *e, err = Parse{{.Name}}(string(b)); err != nil {
+		var code uint8
+		code, err = {{.Lower}}Unknown.decode(b)
+		*e = {{.Name}}Enum(code)
+	}
+	return
+}
+
+func (e {{.Name}}Enum) Value() (driver.Value, error) {
+	text, _ := e.MarshalText()
+	return string(text), nil
+}
+
+func (e *{{.Name}}Enum) Scan(src interface{}) (err error) {
+	value, err := {{.Lower}}Unknown.scan(src)
+	if err != nil {
+		return
+	}
+	return e.UnmarshalText([]byte(value))
+}
+{{end}}`))
@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestValidate(t *testing.T) {
+	valid := Enum{Name: "Side", Values: []Value{{"Buy", 1}, {"Sell", 2}}}
+	tests := []struct {
+		name  string
+		enums []Enum
+		valid bool
+	}{
+		{"valid", []Enum{valid}, true},
+		{"duplicate enum", []Enum{valid, valid}, false},
+		{"no values", []Enum{{Name: "Side"}}, false},
+		{"duplicate name", []Enum{{Name: "Side", Values: []Value{{"Buy", 1}, {"BUY", 2}}}}, false},
+		{"duplicate code", []Enum{{Name: "Side", Values: []Value{{"Buy", 1}, {"Sell", 1}}}}, false},
+		{"unknown code", []Enum{{Name: "Side", Values: []Value{{"Buy", 128}}}}, false},
+		{"negative code", []Enum{{Name: "Side", Values: []Value{{"Buy", -1}}}}, false},
+	}
+	for _, test := range tests {
+		if err := validate(test.enums); (err == nil) != test.valid {
+			t.Errorf("%s: got error %v, expected valid %t", test.name, err, test.valid)
+		}
+	}
+}
@@ -2,8 +2,11 @@ package client
 
 import (
 	"encoding/json"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/shopspring/decimal"
 )
 
@@ -41,6 +44,16 @@ func NewSubscribeRequest(now time.Time, requestID int64,
 	return
 }
 
+// NewUnsubscribeRequest returns a request to end the subscription with the given request ID.
+func NewUnsubscribeRequest(now time.Time, subscriptionID int64) (result *request) {
+	result = &request{
+		Id:        subscriptionID,
+		Type:      "unsubscribe",
+		Timestamp: MicrosTimestamp(now),
+	}
+	return
+}
+
 // Hello is the message that's sent by the server on connection.
 type Hello struct {
 	Type      string          `json:"type"`
@@ -126,7 +139,7 @@ type Trade struct {
 	Fee            decimal.Decimal
 	FeeCurrency    string
 	MarketTradeID  string
-	TradeStatus    string
+	TradeStatus    TradeStatusEnum
 	AggressorSide  SideEnum
 	AmountCurrency string
 	DealtCurrency  string
@@ -134,6 +147,78 @@ type Trade struct {
 	Group          string
 }
 
+// Amounts returns the currencies of the BASE-QUOTE symbol of the trade, its quantity in the
+// base currency and its amount in the quote currency, whichever currency its quantity is in. A
+// missing amount is worked out from the price. It's an error if the quantity is in the quote
+// currency and the base quantity can't be worked out, without an amount or a price.
+func (t *Trade) Amounts() (base string, quote string, quantity decimal.Decimal, amount decimal.Decimal, err error) {
+	var ok bool
+	if base, quote, ok = strings.Cut(t.Symbol, "-"); !ok {
+		err = errors.Errorf("trade %s has an invalid symbol '%s'", t.TradeID, t.Symbol)
+		return
+	}
+	if t.Currency == "" || t.Currency == base {
+		quantity, amount = t.Quantity, t.Amount
+		if amount.IsZero() {
+			amount = t.Quantity.Mul(t.Price)
+		}
+		return
+	}
+	quantity, amount = t.Amount, t.Quantity
+	if quantity.IsZero() {
+		if t.Price.IsZero() {
+			err = errors.Errorf("trade %s has a quantity in %s without an amount or a price", t.TradeID, t.Currency)
+			return
+		}
+		quantity = t.Quantity.Div(t.Price)
+	}
+	return
+}
+
+// LatestTrades returns the last update of each of the trades, in the order they happened, and
+// without the trades that were canceled.
+func LatestTrades(trades []*Trade) (result []*Trade) {
+	last := make(map[string]*Trade)
+	var ids []string
+	for _, trade := range trades {
+		if _, ok := last[trade.TradeID]; !ok {
+			ids = append(ids, trade.TradeID)
+		}
+		last[trade.TradeID] = trade
+	}
+	for _, id := range ids {
+		if trade := last[id]; trade.TradeStatus != TradeStatus.Canceled {
+			result = append(result, trade)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return time.Time(result[i].TransactTime).Before(time.Time(result[j].TransactTime))
+	})
+	return
+}
+
+// Security is the result of a subscription to securities and is the reference data of a symbol.
+// It is returned in the Data field on a response.
+type Security struct {
+	Timestamp         MicrosTimestamp
+	Symbol            string
+	BaseCurrency      string
+	QuoteCurrency     string
+	MinimumSize       decimal.Decimal
+	MaximumSize       decimal.Decimal
+	MinSizeIncrement  decimal.Decimal
+	MinPriceIncrement decimal.Decimal
+}
+
+// Balance is the result of a subscription to balances and is the balance of a currency on the
+// account. It is returned in the Data field on a response.
+type Balance struct {
+	Timestamp       MicrosTimestamp
+	Currency        string
+	Amount          decimal.Decimal
+	AvailableAmount decimal.Decimal
+}
+
 // NewOrderSingle is a request to submit an order. It should be sent as the Data
 // field on a request.
 type NewOrderSingle struct {
@@ -144,9 +229,12 @@ type NewOrderSingle struct {
 	OrderQty        decimal.Decimal
 	OrdType         OrdTypeEnum
 	Price           *decimal.Decimal `json:",omitempty"`
+	RFQID           string           `json:",omitempty"`
 	TimeInForce     TimeInForceEnum
 	TransactTime    MicrosTimestamp
 	CancelSessionID string `json:",omitempty"`
+	SubAccount      string `json:",omitempty"`
+	Group           string `json:",omitempty"`
 }
 
 // NewOrderSingleRequest is a request message for a new order.
@@ -169,3 +257,127 @@ func NewNewOrderSingleRequest(now time.Time, requestID int64, message *NewOrderS
 	}
 	return
 }
+
+// QuoteRequest is a request for a firm price to buy or sell a quantity. It should be sent as the
+// Data field on a request, and is answered on the quote stream.
+type QuoteRequest struct {
+	QuoteReqID   string
+	Symbol       string
+	Currency     string `json:",omitempty"`
+	Side         SideEnum
+	OrderQty     decimal.Decimal
+	TransactTime MicrosTimestamp
+	SubAccount   string `json:",omitempty"`
+	Group        string `json:",omitempty"`
+}
+
+// QuoteRequestRequest is a request message for a quote.
+type quoteRequestRequest struct {
+	request
+	Data []QuoteRequest `json:"data"`
+}
+
+// NewQuoteRequestRequest returns a new quote request with the given params.
+func NewQuoteRequestRequest(now time.Time, requestID int64, message *QuoteRequest) (result *quoteRequestRequest) {
+	result = &quoteRequestRequest{
+		request: request{
+			Id:        requestID,
+			Type:      "QuoteRequest",
+			Timestamp: MicrosTimestamp(now),
+		},
+		Data: []QuoteRequest{
+			*message,
+		},
+	}
+	return
+}
+
+// Quote is the result of a subscription to quotes and is the answer to a quote request. It is
+// accepted by a NewOrderSingle of type RFQ with its RFQID until ValidUntilTime. It is returned
+// in the Data field on a response.
+type Quote struct {
+	Timestamp      MicrosTimestamp
+	QuoteReqID     string
+	RFQID          string
+	Symbol         string
+	Currency       string
+	Side           SideEnum
+	OrderQty       decimal.Decimal
+	BidPx          decimal.Decimal
+	OfferPx        decimal.Decimal
+	ValidUntilTime MicrosTimestamp
+	QuoteStatus    QuoteStatusEnum
+	Text           string
+	SubAccount     string
+	Group          string
+}
+
+// Price returns the price of the quote for its side: the offer for a buy and the bid for a sell.
+func (q *Quote) Price() decimal.Decimal {
+	if q.Side == Side.Sell {
+		return q.BidPx
+	}
+	return q.OfferPx
+}
+
+// OrderCancelRequest is a request to cancel an order. It should be sent as the Data field on
+// a request.
+type OrderCancelRequest struct {
+	ClOrdID      string
+	OrigClOrdID  string
+	Symbol       string `json:",omitempty"`
+	TransactTime MicrosTimestamp
+}
+
+// OrderCancelRequestRequest is a request message to cancel an order.
+type orderCancelRequestRequest struct {
+	request
+	Data []OrderCancelRequest `json:"data"`
+}
+
+// NewOrderCancelRequest returns a new order cancel request with the given params.
+func NewOrderCancelRequest(now time.Time, requestID int64, message *OrderCancelRequest) (result *orderCancelRequestRequest) {
+	result = &orderCancelRequestRequest{
+		request: request{
+			Id:        requestID,
+			Type:      "OrderCancelRequest",
+			Timestamp: MicrosTimestamp(now),
+		},
+		Data: []OrderCancelRequest{
+			*message,
+		},
+	}
+	return
+}
+
+// OrderMassCancelRequest is a request to cancel all the open orders, optionally only those of a
+// symbol. It should be sent as the Data field on a request.
+type OrderMassCancelRequest struct {
+	ClOrdID      string
+	Symbol       string `json:",omitempty"`
+	SubAccount   string `json:",omitempty"`
+	Group        string `json:",omitempty"`
+	TransactTime MicrosTimestamp
+}
+
+// OrderMassCancelRequestRequest is a request message to cancel all the open orders.
+type orderMassCancelRequestRequest struct {
+	request
+	Data []OrderMassCancelRequest `json:"data"`
+}
+
+// NewOrderMassCancelRequest returns a new order mass cancel request with the given params.
+func NewOrderMassCancelRequest(now time.Time, requestID int64,
+	message *OrderMassCancelRequest) (result *orderMassCancelRequestRequest) {
+	result = &orderMassCancelRequestRequest{
+		request: request{
+			Id:        requestID,
+			Type:      "OrderMassCancelRequest",
+			Timestamp: MicrosTimestamp(now),
+		},
+		Data: []OrderMassCancelRequest{
+			*message,
+		},
+	}
+	return
+}
@@ -22,6 +22,16 @@ func (t MicrosTimestamp) MarshalJSON() ([]byte, error) {
 	return []byte(strconv.Quote(t.String())), nil
 }
 
+// ParseMicrosTimestamp parses an RFC-3339 time string.
+func ParseMicrosTimestamp(value string) (t MicrosTimestamp, err error) {
+	var tm time.Time
+	if tm, err = time.Parse(time.RFC3339Nano, value); err != nil {
+		return
+	}
+	t = MicrosTimestamp(tm)
+	return
+}
+
 // UnmarshalJSON parses a quoted JSON time string.
 func (t *MicrosTimestamp) UnmarshalJSON(data []byte) (err error) {
 	var value string
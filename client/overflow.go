@@ -0,0 +1,301 @@
+package client
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// OverflowPolicy is what the read pump does with a message when the incoming channel is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock waits for the consumer, which stops reading from the websocket. The read
+	// deadline is extended once the consumer catches up, so that a slow consumer isn't reported
+	// as a pong timeout.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest drops the oldest message in the channel, and sends an Overflow message
+	// with the number of messages dropped ahead of the next message delivered.
+	OverflowDropOldest
+	// OverflowSpill appends the messages to a file until the consumer catches up, keeping their
+	// order.
+	OverflowSpill
+)
+
+var overflowPolicyNames = []string{"block", "dropOldest", "spill"}
+
+// String returns the name of the policy.
+func (p OverflowPolicy) String() string {
+	if p < 0 || int(p) >= len(overflowPolicyNames) {
+		return fmt.Sprintf("OverflowPolicy(%d)", int(p))
+	}
+	return overflowPolicyNames[p]
+}
+
+// ParseOverflowPolicy returns the policy with the given name.
+func ParseOverflowPolicy(name string) (OverflowPolicy, error) {
+	for i, policyName := range overflowPolicyNames {
+		if name == policyName {
+			return OverflowPolicy(i), nil
+		}
+	}
+	return OverflowBlock, fmt.Errorf("invalid overflow policy %s, expected one of %v", name, overflowPolicyNames)
+}
+
+// OverflowMessageType is the type of the message sent on the incoming channel after messages
+// were dropped by the OverflowDropOldest policy.
+const OverflowMessageType = "overflow"
+
+// Overflow is sent on the incoming channel ahead of the first message delivered after messages
+// were dropped, with the number of messages dropped since connecting. The streams should be
+// resumed from their checkpoint.
+type Overflow struct {
+	Type    string `json:"type"`
+	Dropped int64  `json:"dropped"`
+}
+
+// overflowPrefix starts every Overflow message, to tell them from the messages of the server.
+var overflowPrefix = []byte(`{"type":"` + OverflowMessageType + `"`)
+
+const (
+	// slowConsumerThreshold is the fill ratio of the incoming channel over which the consumer is
+	// reported as slow.
+	slowConsumerThreshold = 0.8
+
+	// slowConsumerWarnInterval is the minimum interval between slow consumer warnings.
+	slowConsumerWarnInterval = 10 * time.Second
+)
+
+var (
+	// incomingDepthMetrics and outgoingDepthMetrics publish the number of messages waiting for
+	// the consumer and for the websocket, per connection.
+	incomingDepthMetrics = expvar.NewMap("incoming_queue_depth")
+	outgoingDepthMetrics = expvar.NewMap("outgoing_queue_depth")
+
+	// droppedMetrics and spilledMetrics count the incoming messages dropped and spilled to disk,
+	// per connection.
+	droppedMetrics = expvar.NewMap("incoming_dropped")
+	spilledMetrics = expvar.NewMap("incoming_spilled")
+)
+
+// deliver sends an incoming message to the consumer following the overflow policy. It returns
+// an error if the connection should be closed.
+func (client *client) deliver(message []byte) (err error) {
+	client.warnSlowConsumer()
+	switch client.options.OverflowPolicy {
+	case OverflowDropOldest:
+		client.deliverDroppingOldest(message)
+	case OverflowSpill:
+		err = client.spill.deliver(message)
+	default:
+		err = client.deliverBlocking(message)
+	}
+	return
+}
+
+// deliverBlocking waits for the consumer to take the message.
+func (client *client) deliverBlocking(message []byte) (err error) {
+	select {
+	case client.incoming <- message:
+		return
+	default:
+	}
+	started := time.Now()
+	select {
+	case client.incoming <- message:
+	case <-client.closeC:
+		return
+	}
+	// the pongs were left unread while waiting, don't blame the server for it
+	log.Printf("warning: %s consumer blocked reads for %s", client.name, time.Since(started))
+	return client.conn.SetReadDeadline(time.Now().Add(client.options.PongWait))
+}
+
+// deliverDroppingOldest makes room for the message, and for an Overflow message if any were
+// dropped, by dropping the oldest ones. An Overflow message that is dropped in turn is sent again.
+// The read pump is the only sender, so the room it makes can't be taken before it sends.
+func (client *client) deliverDroppingOldest(message []byte) {
+	for {
+		needed := 1
+		if client.overflowPending {
+			needed = 2
+		}
+		if cap(client.incoming)-len(client.incoming) >= needed {
+			break
+		}
+		select {
+		case dropped := <-client.incoming:
+			client.overflowPending = true
+			if !bytes.HasPrefix(dropped, overflowPrefix) {
+				client.dropped++
+				droppedMetrics.Add(client.name, 1)
+			}
+		default:
+		}
+	}
+	if client.overflowPending {
+		marker, _ := json.Marshal(Overflow{Type: OverflowMessageType, Dropped: client.dropped})
+		client.incoming <- marker
+		client.overflowPending = false
+	}
+	client.incoming <- message
+}
+
+// warnSlowConsumer logs a warning, at most once per interval, while the incoming channel is
+// nearly full.
+func (client *client) warnSlowConsumer() {
+	if float64(len(client.incoming)) < slowConsumerThreshold*float64(cap(client.incoming)) {
+		return
+	}
+	if now := time.Now(); now.Sub(client.lastSlowWarning) >= slowConsumerWarnInterval {
+		client.lastSlowWarning = now
+		log.Printf("warning: %s consumer is slow, %d incoming messages waiting (%s policy)",
+			client.name, client.incomingDepth(), client.options.OverflowPolicy)
+	}
+}
+
+// incomingDepth returns the number of incoming messages waiting for the consumer.
+func (client *client) incomingDepth() int64 {
+	depth := int64(len(client.incoming))
+	if client.spill != nil {
+		depth += client.spill.len()
+	}
+	return depth
+}
+
+// spillQueue holds the incoming messages in a file while the consumer is behind, and feeds
+// them to the incoming channel in order as it catches up.
+type spillQueue struct {
+	client   *client
+	maxBytes int64
+
+	mu       sync.Mutex
+	ready    *sync.Cond
+	file     *os.File
+	readAt   int64
+	writeAt  int64
+	count    int64
+	inFlight bool
+	closed   bool
+}
+
+// newSpillQueue creates the spill file in the given directory, or the temporary directory.
+func newSpillQueue(client *client, dir string, maxBytes int64) (q *spillQueue, err error) {
+	q = &spillQueue{client: client, maxBytes: maxBytes}
+	q.ready = sync.NewCond(&q.mu)
+	if q.file, err = os.CreateTemp(dir, "pintu-spill-*"); err != nil {
+		err = errors.Wrap(err, "unable to create spill file")
+		return
+	}
+	// the file is only needed while the connection is open
+	_ = os.Remove(q.file.Name())
+	go q.feed()
+	return
+}
+
+// deliver sends the message to the consumer if nothing is spilled, or spills it.
+func (q *spillQueue) deliver(message []byte) (err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.count == 0 && !q.inFlight {
+		select {
+		case q.client.incoming <- message:
+			return
+		default:
+		}
+	}
+	if q.maxBytes > 0 && q.writeAt-q.readAt+int64(len(message)) > q.maxBytes {
+		return errors.Errorf("consumer too slow, over %d bytes spilled", q.maxBytes)
+	}
+	record := make([]byte, 4+len(message))
+	binary.BigEndian.PutUint32(record, uint32(len(message)))
+	copy(record[4:], message)
+	if _, err = q.file.WriteAt(record, q.writeAt); err != nil {
+		return errors.Wrap(err, "unable to spill message")
+	}
+	q.writeAt += int64(len(record))
+	q.count++
+	spilledMetrics.Add(q.client.name, 1)
+	q.ready.Signal()
+	return
+}
+
+// feed sends the spilled messages to the consumer, oldest first.
+func (q *spillQueue) feed() {
+	for {
+		q.mu.Lock()
+		for q.count == 0 && !q.closed {
+			q.ready.Wait()
+		}
+		if q.closed {
+			q.mu.Unlock()
+			return
+		}
+		message, err := q.read()
+		if err != nil {
+			q.mu.Unlock()
+			q.client.onError(err)
+			return
+		}
+		q.inFlight = true
+		q.mu.Unlock()
+
+		select {
+		case q.client.incoming <- message:
+		case <-q.client.closeC:
+		}
+
+		q.mu.Lock()
+		q.inFlight = false
+		if q.count == 0 {
+			// start the file over once the consumer caught up
+			q.readAt, q.writeAt = 0, 0
+			_ = q.file.Truncate(0)
+		}
+		q.mu.Unlock()
+	}
+}
+
+// read returns the oldest spilled message.
+func (q *spillQueue) read() (message []byte, err error) {
+	var length [4]byte
+	if _, err = q.file.ReadAt(length[:], q.readAt); err == nil {
+		message = make([]byte, binary.BigEndian.Uint32(length[:]))
+		_, err = q.file.ReadAt(message, q.readAt+4)
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		err = errors.Wrap(err, "unable to read spilled message")
+		return
+	}
+	q.readAt += int64(4 + len(message))
+	q.count--
+	return
+}
+
+// len returns the number of spilled messages.
+func (q *spillQueue) len() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.count
+}
+
+// close stops feeding and removes the spill file.
+func (q *spillQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.ready.Broadcast()
+	_ = q.file.Close()
+}
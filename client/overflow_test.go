@@ -0,0 +1,109 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// newTestClient returns a client without a connection, to test the delivery of incoming messages.
+func newTestClient(policy OverflowPolicy, bufferSize int) *client {
+	return &client{
+		incoming: make(chan []byte, bufferSize),
+		closeC:   make(chan interface{}),
+		options:  ConnectOptions{OverflowPolicy: policy},
+		name:     "test",
+	}
+}
+
+func TestOverflowDropOldest(t *testing.T) {
+	c := newTestClient(OverflowDropOldest, 4)
+	for i := 1; i <= 10; i++ {
+		if err := c.deliver([]byte(fmt.Sprintf(`{"seq":%d}`, i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var received []string
+	for len(c.incoming) > 0 {
+		received = append(received, string(<-c.incoming))
+	}
+	// the markers are dropped in turn as more messages arrive, and report every drop so far
+	expected := []string{`{"type":"overflow","dropped":7}`, `{"seq":9}`, `{"type":"overflow","dropped":8}`, `{"seq":10}`}
+	if fmt.Sprint(received) != fmt.Sprint(expected) {
+		t.Errorf("got %v, expected %v", received, expected)
+	}
+
+	// once the consumer caught up, nothing more is dropped
+	if err := c.deliver([]byte(`{"seq":11}`)); err != nil || string(<-c.incoming) != `{"seq":11}` {
+		t.Errorf("got an error %v or a marker once caught up", err)
+	}
+}
+
+func TestOverflowDropOldestMarker(t *testing.T) {
+	c := newTestClient(OverflowDropOldest, 2)
+	for i := 1; i <= 3; i++ {
+		_ = c.deliver([]byte(fmt.Sprintf(`{"seq":%d}`, i)))
+	}
+	overflow := Overflow{}
+	if err := json.Unmarshal(<-c.incoming, &overflow); err != nil || overflow.Type != OverflowMessageType || overflow.Dropped != 2 {
+		t.Errorf("got %+v %v, expected a marker for the 2 dropped messages", overflow, err)
+	}
+	if message := string(<-c.incoming); message != `{"seq":3}` {
+		t.Errorf("got %s after the marker, expected the latest message", message)
+	}
+}
+
+func TestOverflowSpill(t *testing.T) {
+	c := newTestClient(OverflowSpill, 2)
+	spill, err := newSpillQueue(c, t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.spill = spill
+	defer spill.close()
+
+	for i := 1; i <= 50; i++ {
+		if err = c.deliver([]byte(fmt.Sprintf(`{"seq":%d}`, i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if depth := c.incomingDepth(); depth < 48 {
+		t.Errorf("got depth %d, expected the spilled messages to be counted", depth)
+	}
+	for i := 1; i <= 50; i++ {
+		select {
+		case message := <-c.incoming:
+			if expected := fmt.Sprintf(`{"seq":%d}`, i); string(message) != expected {
+				t.Fatalf("got %s, expected %s", message, expected)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("message %d not delivered", i)
+		}
+		// keep delivering while the spill drains, the order still holds
+		if i == 25 {
+			_ = c.deliver([]byte(`{"seq":51}`))
+		}
+	}
+	if message := string(<-c.incoming); message != `{"seq":51}` {
+		t.Errorf("got %s, expected the message spilled while draining", message)
+	}
+}
+
+func TestOverflowSpillLimit(t *testing.T) {
+	c := newTestClient(OverflowSpill, 1)
+	spill, err := newSpillQueue(c, t.TempDir(), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.spill = spill
+	defer spill.close()
+
+	message := []byte(`{"data":"` + string(make([]byte, 40)) + `"}`)
+	for i := 0; i < 10; i++ {
+		if err = c.deliver(message); err != nil {
+			return
+		}
+	}
+	t.Errorf("spilled over the limit without an error")
+}
@@ -0,0 +1,167 @@
+package client
+
+import (
+	"container/heap"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// Priority is the priority of an outgoing message. Messages with a higher priority are
+// written to the websocket before any queued messages with a lower priority.
+type Priority int
+
+const (
+	// PriorityLow is used for new orders.
+	PriorityLow Priority = iota
+	// PriorityNormal is used for messages without an explicit priority.
+	PriorityNormal
+	// PriorityHigh is used for cancels and kill-switch traffic.
+	PriorityHigh
+)
+
+// defaultPriorities are the priorities of the known outgoing message types, so that cancels
+// are never stuck behind a burst of new orders.
+var defaultPriorities = map[string]Priority{
+	"OrderCancelRequest":     PriorityHigh,
+	"OrderMassCancelRequest": PriorityHigh,
+	"subscribe":              PriorityNormal,
+	"NewOrderSingle":         PriorityLow,
+}
+
+// outgoingMessage is a message waiting in the outgoing queue.
+type outgoingMessage struct {
+	data     []byte
+	msgType  string
+	priority Priority
+	seq      uint64
+}
+
+// messageType returns the type field of an outgoing request.
+func messageType(data []byte) string {
+	header := struct {
+		Type string `json:"type"`
+	}{}
+	_ = json.Unmarshal(data, &header)
+	return header.Type
+}
+
+// outgoingQueue orders outgoing messages by priority and then by arrival.
+type outgoingQueue struct {
+	mu         sync.Mutex
+	priorities map[string]Priority
+	messages   messageHeap
+	seq        uint64
+}
+
+func newOutgoingQueue() *outgoingQueue {
+	q := &outgoingQueue{
+		priorities: make(map[string]Priority),
+	}
+	for msgType, priority := range defaultPriorities {
+		q.priorities[msgType] = priority
+	}
+	return q
+}
+
+func (q *outgoingQueue) setPriority(msgType string, priority Priority) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.priorities[msgType] = priority
+}
+
+func (q *outgoingQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.messages.Len()
+}
+
+func (q *outgoingQueue) push(data []byte) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	msgType := messageType(data)
+	priority, ok := q.priorities[msgType]
+	if !ok {
+		priority = PriorityNormal
+	}
+	q.seq++
+	heap.Push(&q.messages, &outgoingMessage{
+		data:     data,
+		msgType:  msgType,
+		priority: priority,
+		seq:      q.seq,
+	})
+}
+
+// pop returns the highest priority message that the limiter allows to be sent now. If every
+// queued message is rate limited, it returns nil and the time until the first one is allowed.
+func (q *outgoingQueue) pop(limiter *rateLimiter, now time.Time) (message *outgoingMessage, wait time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.messages.Len() == 0 {
+		return
+	}
+	// fast path, the highest priority message isn't limited
+	if top := q.messages[0]; limiter.wait(top.msgType, now) == 0 {
+		limiter.take(top.msgType)
+		heap.Pop(&q.messages)
+		return top, 0
+	}
+	// walk the messages in priority order, skipping the types that have no tokens left
+	ordered := make(messageHeap, len(q.messages))
+	copy(ordered, q.messages)
+	limited := make(map[string]bool)
+	for ordered.Len() > 0 {
+		candidate := heap.Pop(&ordered).(*outgoingMessage)
+		if limited[candidate.msgType] {
+			continue
+		}
+		delay := limiter.wait(candidate.msgType, now)
+		if delay == 0 {
+			limiter.take(candidate.msgType)
+			heap.Remove(&q.messages, q.messages.index(candidate))
+			return candidate, 0
+		}
+		limited[candidate.msgType] = true
+		if wait == 0 || delay < wait {
+			wait = delay
+		}
+	}
+	return
+}
+
+// messageHeap implements heap.Interface for outgoing messages.
+type messageHeap []*outgoingMessage
+
+func (h messageHeap) Len() int { return len(h) }
+
+func (h messageHeap) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority > h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h messageHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *messageHeap) Push(x interface{}) {
+	*h = append(*h, x.(*outgoingMessage))
+}
+
+func (h *messageHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return item
+}
+
+func (h messageHeap) index(message *outgoingMessage) int {
+	for i, m := range h {
+		if m == message {
+			return i
+		}
+	}
+	return -1
+}
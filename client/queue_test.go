@@ -0,0 +1,87 @@
+package client
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func testMessage(msgType string, id int) []byte {
+	return []byte(fmt.Sprintf(`{"reqid":%d,"type":"%s"}`, id, msgType))
+}
+
+// popAll pops the queue until it's empty or everything left is rate limited.
+func popAll(q *outgoingQueue, limiter *rateLimiter, now time.Time) (sent []string, wait time.Duration) {
+	for {
+		message, delay := q.pop(limiter, now)
+		if message == nil {
+			return sent, delay
+		}
+		sent = append(sent, string(message.data))
+	}
+}
+
+func TestOutgoingQueuePriority(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	q := newOutgoingQueue()
+	q.push(testMessage("NewOrderSingle", 1))
+	q.push(testMessage("subscribe", 2))
+	q.push(testMessage("NewOrderSingle", 3))
+	q.push(testMessage("OrderCancelRequest", 4))
+	q.push(testMessage("OrderMassCancelRequest", 5))
+
+	sent, _ := popAll(q, newRateLimiter(), now)
+	expected := []string{
+		string(testMessage("OrderCancelRequest", 4)),
+		string(testMessage("OrderMassCancelRequest", 5)),
+		string(testMessage("subscribe", 2)),
+		string(testMessage("NewOrderSingle", 1)),
+		string(testMessage("NewOrderSingle", 3)),
+	}
+	if fmt.Sprint(sent) != fmt.Sprint(expected) {
+		t.Errorf("got %v, expected %v", sent, expected)
+	}
+}
+
+func TestOutgoingQueueRateLimit(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := newRateLimiter()
+	limiter.buckets["NewOrderSingle"] = newTokenBucket(RateLimit{Rate: 1, Burst: 1}, now)
+	limiter.buckets["OrderCancelRequest"] = newTokenBucket(RateLimit{Rate: 4, Burst: 1}, now)
+	q := newOutgoingQueue()
+	q.push(testMessage("OrderCancelRequest", 1))
+	q.push(testMessage("OrderCancelRequest", 2))
+	q.push(testMessage("NewOrderSingle", 3))
+	q.push(testMessage("NewOrderSingle", 4))
+	q.push(testMessage("subscribe", 5))
+
+	// the second cancel is limited, so the lower priority messages that aren't get sent
+	sent, wait := popAll(q, limiter, now)
+	expected := []string{
+		string(testMessage("OrderCancelRequest", 1)),
+		string(testMessage("subscribe", 5)),
+		string(testMessage("NewOrderSingle", 3)),
+	}
+	if fmt.Sprint(sent) != fmt.Sprint(expected) {
+		t.Errorf("got %v, expected %v", sent, expected)
+	}
+	if wait != 250*time.Millisecond {
+		t.Errorf("got wait %s, expected the cancel's 250ms", wait)
+	}
+
+	sent, wait = popAll(q, limiter, now.Add(wait))
+	if fmt.Sprint(sent) != fmt.Sprint([]string{string(testMessage("OrderCancelRequest", 2))}) {
+		t.Errorf("got %v, expected the second cancel", sent)
+	}
+	if wait != 750*time.Millisecond {
+		t.Errorf("got wait %s, expected the order's 750ms", wait)
+	}
+
+	sent, _ = popAll(q, limiter, now.Add(time.Second))
+	if fmt.Sprint(sent) != fmt.Sprint([]string{string(testMessage("NewOrderSingle", 4))}) {
+		t.Errorf("got %v, expected the second order", sent)
+	}
+	if q.len() != 0 {
+		t.Errorf("got %d queued messages, expected none", q.len())
+	}
+}
@@ -0,0 +1,135 @@
+package client
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimit is a token bucket limit for a single outgoing message type. Rate tokens are
+// added every second, up to a maximum of Burst tokens. Each message consumes one token.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// ParseRateLimit parses a rate limit in the form Type=rate[:burst], for example
+// NewOrderSingle=10:20. If the burst is omitted, it defaults to the rate rounded up.
+func ParseRateLimit(str string) (msgType string, limit RateLimit, err error) {
+	parts := strings.SplitN(str, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		err = fmt.Errorf("invalid rate limit %s, expected Type=rate[:burst]", str)
+		return
+	}
+	msgType = parts[0]
+	values := strings.SplitN(parts[1], ":", 2)
+	if limit.Rate, err = strconv.ParseFloat(values[0], 64); err != nil || !limit.validRate() {
+		err = fmt.Errorf("invalid rate in rate limit %s", str)
+		return
+	}
+	limit.Burst = int(math.Ceil(limit.Rate))
+	if len(values) == 2 {
+		if limit.Burst, err = strconv.Atoi(values[1]); err != nil || limit.Burst < 1 {
+			err = fmt.Errorf("invalid burst in rate limit %s", str)
+			return
+		}
+	}
+	return
+}
+
+// Validate checks that the rate is a positive finite number and that the burst allows at least
+// one message.
+func (l RateLimit) Validate() error {
+	if !l.validRate() || l.Burst < 1 {
+		return fmt.Errorf("invalid rate limit %s", l)
+	}
+	return nil
+}
+
+// validRate returns true if the rate is positive and finite, as NaN fails every comparison.
+func (l RateLimit) validRate() bool {
+	return l.Rate > 0 && !math.IsInf(l.Rate, 0)
+}
+
+func (l RateLimit) String() string {
+	return fmt.Sprintf("%g/s burst %d", l.Rate, l.Burst)
+}
+
+// tokenBucket tracks the available tokens for a single message type.
+type tokenBucket struct {
+	limit  RateLimit
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(limit RateLimit, now time.Time) *tokenBucket {
+	return &tokenBucket{
+		limit:  limit,
+		tokens: float64(limit.Burst),
+		last:   now,
+	}
+}
+
+// refill adds the tokens accumulated since the last refill.
+func (b *tokenBucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed > 0 {
+		b.tokens = math.Min(float64(b.limit.Burst), b.tokens+elapsed*b.limit.Rate)
+		b.last = now
+	}
+}
+
+// wait returns how long until a token is available, or zero if one is available now.
+func (b *tokenBucket) wait(now time.Time) time.Duration {
+	b.refill(now)
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.limit.Rate * float64(time.Second))
+}
+
+// take consumes a token. It must only be called after wait returned zero.
+func (b *tokenBucket) take() {
+	b.tokens--
+}
+
+// rateLimiter holds the token buckets for each limited message type. Message types
+// without a configured limit are not limited.
+type rateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+func (l *rateLimiter) set(msgType string, limit RateLimit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.buckets[msgType] = newTokenBucket(limit, time.Now())
+}
+
+// wait returns how long until the given message type may be sent.
+func (l *rateLimiter) wait(msgType string, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if bucket, ok := l.buckets[msgType]; ok {
+		return bucket.wait(now)
+	}
+	return 0
+}
+
+// take consumes a token for the given message type.
+func (l *rateLimiter) take(msgType string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if bucket, ok := l.buckets[msgType]; ok {
+		bucket.take()
+	}
+}
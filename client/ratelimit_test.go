@@ -0,0 +1,71 @@
+package client
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	tests := []struct {
+		value   string
+		msgType string
+		limit   RateLimit
+		valid   bool
+	}{
+		{"NewOrderSingle=10", "NewOrderSingle", RateLimit{Rate: 10, Burst: 10}, true},
+		{"NewOrderSingle=2.5:5", "NewOrderSingle", RateLimit{Rate: 2.5, Burst: 5}, true},
+		{"NewOrderSingle=0.5", "NewOrderSingle", RateLimit{Rate: 0.5, Burst: 1}, true},
+		{"NewOrderSingle", "", RateLimit{}, false},
+		{"=10", "", RateLimit{}, false},
+		{"NewOrderSingle=0", "", RateLimit{}, false},
+		{"NewOrderSingle=-1", "", RateLimit{}, false},
+		{"NewOrderSingle=NaN", "", RateLimit{}, false},
+		{"NewOrderSingle=Inf", "", RateLimit{}, false},
+		{"NewOrderSingle=10:0", "", RateLimit{}, false},
+	}
+	for _, test := range tests {
+		msgType, limit, err := ParseRateLimit(test.value)
+		if (err == nil) != test.valid {
+			t.Errorf("%s: got error %v, expected valid %t", test.value, err, test.valid)
+			continue
+		}
+		if test.valid && (msgType != test.msgType || limit != test.limit) {
+			t.Errorf("%s: got %s %s, expected %s %s", test.value, msgType, limit, test.msgType, test.limit)
+		}
+	}
+}
+
+func TestTokenBucketWait(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	bucket := newTokenBucket(RateLimit{Rate: 2, Burst: 2}, start)
+
+	// the bucket starts full
+	for i := 0; i < 2; i++ {
+		if wait := bucket.wait(start); wait != 0 {
+			t.Fatalf("token %d: got wait %s, expected none", i, wait)
+		}
+		bucket.take()
+	}
+	if wait := bucket.wait(start); wait != 500*time.Millisecond {
+		t.Errorf("empty bucket: got wait %s, expected 500ms", wait)
+	}
+	if wait := bucket.wait(start.Add(200 * time.Millisecond)); wait != 300*time.Millisecond {
+		t.Errorf("partially refilled bucket: got wait %s, expected 300ms", wait)
+	}
+	if wait := bucket.wait(start.Add(500 * time.Millisecond)); wait != 0 {
+		t.Errorf("refilled token: got wait %s, expected none", wait)
+	}
+	bucket.take()
+
+	// the tokens never exceed the burst, however long the bucket is idle
+	later := start.Add(time.Hour)
+	for i := 0; i < 2; i++ {
+		if wait := bucket.wait(later); wait != 0 {
+			t.Fatalf("token %d after idle: got wait %s, expected none", i, wait)
+		}
+		bucket.take()
+	}
+	if wait := bucket.wait(later); wait == 0 {
+		t.Errorf("burst exceeded after idle")
+	}
+}
@@ -12,7 +12,8 @@ import (
 const newLine = "\n"
 
 // Sign returns a signature for the given parameters suitable for connecting to the Pintu API.
-func sign(secret string, httpMethod string, dateTime time.Time, host string, path string) string {
+// The signature is the HMAC-SHA256 of the method, timestamp, host and path joined by new lines.
+func Sign(secret string, httpMethod string, dateTime time.Time, host string, path string) string {
 	components := strings.Join([]string{
 		httpMethod,
 		MicrosTimestamp(dateTime).String(),
@@ -0,0 +1,114 @@
+package client
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// minUnknownCode is the lowest code allocated to unknown enum values. The known values of every
+// enum are below it.
+const minUnknownCode = 128
+
+// unknownEnumMetrics counts the unknown values decoded per enum.
+var unknownEnumMetrics = expvar.NewMap("unknown_enum_values")
+
+// unknownEnumValues records the values of an enum that the client doesn't know, such as an
+// ExecType added by Pintu after this code was written, so that they are decoded instead of
+// failing the whole message, and encoded again unchanged. Each distinct value is allocated a
+// code counting down from 255.
+type unknownEnumValues struct {
+	name  string
+	mu    sync.RWMutex
+	codes map[string]uint8
+	raws  map[uint8]string
+}
+
+func newUnknownEnumValues(name string) *unknownEnumValues {
+	return &unknownEnumValues{
+		name:  name,
+		codes: make(map[string]uint8),
+		raws:  make(map[uint8]string),
+	}
+}
+
+// decode returns the code of the unknown value in the given JSON string.
+func (u *unknownEnumValues) decode(b []byte) (code uint8, err error) {
+	var raw string
+	if err = json.Unmarshal(b, &raw); err != nil {
+		err = fmt.Errorf("invalid %s %s", u.name, b)
+		return
+	}
+	return u.code(raw)
+}
+
+// code returns the code of the unknown value, allocating one the first time the value is seen.
+func (u *unknownEnumValues) code(raw string) (code uint8, err error) {
+	if raw == "" {
+		err = fmt.Errorf("invalid %s, empty value", u.name)
+		return
+	}
+	unknownEnumMetrics.Add(u.name, 1)
+	u.mu.RLock()
+	code, ok := u.codes[raw]
+	u.mu.RUnlock()
+	if ok {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if code, ok = u.codes[raw]; ok {
+		return
+	}
+	if len(u.codes) > 255-minUnknownCode {
+		err = fmt.Errorf("too many unknown %s values, unable to decode %s", u.name, raw)
+		return
+	}
+	code = uint8(255 - len(u.codes))
+	u.codes[raw] = code
+	u.raws[code] = raw
+	log.Printf("warning: unknown %s %s, decoded as Unknown", u.name, raw)
+	return
+}
+
+// raw returns the original value of an unknown code.
+func (u *unknownEnumValues) raw(code uint8) (raw string, ok bool) {
+	if code < minUnknownCode {
+		return
+	}
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	raw, ok = u.raws[code]
+	return
+}
+
+// String returns Unknown, with the original value if the code was decoded from one.
+func (u *unknownEnumValues) String(code uint8) string {
+	if raw, ok := u.raw(code); ok {
+		return "Unknown(" + raw + ")"
+	}
+	return "Unknown"
+}
+
+// text returns the original value of an unknown code, or Unknown, to encode it unchanged.
+func (u *unknownEnumValues) text(code uint8) string {
+	if raw, ok := u.raw(code); ok {
+		return raw
+	}
+	return "Unknown"
+}
+
+// scan returns the value of a database column holding an enum as text.
+func (u *unknownEnumValues) scan(src interface{}) (value string, err error) {
+	switch src := src.(type) {
+	case string:
+		value = src
+	case []byte:
+		value = string(src)
+	default:
+		err = fmt.Errorf("unable to scan %T into %s", src, u.name)
+	}
+	return
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pintu-crypto/b2b-order/config"
+)
+
+// accountsFlag collects the repeated --account flags.
+type accountsFlag []config.Account
+
+func (f *accountsFlag) String() string {
+	names := make([]string, 0, len(*f))
+	for _, a := range *f {
+		names = append(names, a.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+// Set parses an account in the form name:apikey:apisecret[:subaccount[:group]].
+func (f *accountsFlag) Set(value string) error {
+	parts := strings.Split(value, ":")
+	if len(parts) < 3 || len(parts) > 5 {
+		return fmt.Errorf("invalid account, expected name:apikey:apisecret[:subaccount[:group]]")
+	}
+	a := config.Account{
+		Name:      parts[0],
+		APIKey:    parts[1],
+		APISecret: parts[2],
+	}
+	if len(parts) > 3 {
+		a.SubAccount = parts[3]
+	}
+	if len(parts) > 4 {
+		a.Group = parts[4]
+	}
+	if a.Name == "" || a.APIKey == "" || a.APISecret == "" {
+		return fmt.Errorf("account name, apikey and apisecret are required")
+	}
+	for _, existing := range *f {
+		if existing.Name == a.Name {
+			return fmt.Errorf("duplicate account %s", a.Name)
+		}
+	}
+	*f = append(*f, a)
+	return nil
+}
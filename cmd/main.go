@@ -1,24 +1,65 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/pkg/errors"
+
 	"github.com/pintu-crypto/b2b-order/client"
+	"github.com/pintu-crypto/b2b-order/config"
 	"github.com/pintu-crypto/b2b-order/endpoint"
+	"github.com/pintu-crypto/b2b-order/export"
 	"github.com/pintu-crypto/b2b-order/order"
 )
 
-var addr = flag.String("addr", "", "Pintu websocket address")
+var configPath = flag.String("config", "", "Path to a YAML config file")
+var printConfig = flag.Bool("print-config", false, "Print the effective config with secrets redacted and exit")
+
+var addr = flag.String("addr", "", "Pintu websocket address, or a comma separated list of the primary and failover addresses")
 var apikey = flag.String("apikey", "", "Pintu api key")
-var apisecret = flag.String("apisecret", "", "Pintu api secret")
+var apisecret = flag.String("apisecret", "", "Pintu api secret, prefer PINTU_APISECRET_FILE or the config file")
 
 var serveAddr = flag.String("serve-addr", ":8085", "Order server address")
 
+var rateLimits = rateLimitsFlag{}
+
+func init() {
+	flag.Var(&rateLimits, "rate-limit",
+		"Outgoing rate limit per message type as Type=rate[:burst], e.g. NewOrderSingle=10:20 (repeatable)")
+}
+
+// rateLimitsFlag collects the repeated --rate-limit flags.
+type rateLimitsFlag map[string]client.RateLimit
+
+func (f rateLimitsFlag) String() string {
+	return fmt.Sprint(map[string]client.RateLimit(f))
+}
+
+func (f rateLimitsFlag) Set(value string) error {
+	msgType, limit, err := client.ParseRateLimit(value)
+	if err != nil {
+		return err
+	}
+	f[msgType] = limit
+	return nil
+}
+
+var accounts = accountsFlag{}
+
+func init() {
+	flag.Var(&accounts, "account",
+		"Named Pintu account as name:apikey:apisecret[:subaccount[:group]] (repeatable)")
+}
+
 var interrupt = make(chan os.Signal, 1)
 
 func init() {
@@ -28,49 +69,301 @@ func init() {
 
 func main() {
 	flag.Parse()
-	if *addr == "" {
-		log.Fatalf("--addr required")
+	cfg, err := loadConfig()
+	if err != nil {
+		log.Fatalf("invalid config: %s", err)
 		return
 	}
-	if *apikey == "" {
-		log.Fatalf("--apikey required")
+	if *printConfig {
+		data, err := cfg.Redacted()
+		if err != nil {
+			log.Fatalf("unable to print config: %s", err)
+		}
+		fmt.Print(string(data))
 		return
 	}
-	if *apisecret == "" {
-		log.Fatalf("--apisecret required")
+	if err = cfg.Validate(); err != nil {
+		log.Fatalf("invalid config: %s", err)
 		return
 	}
 
-	requestsEndpoint, err := endpoint.Serve(*serveAddr)
+	names := make([]string, 0, len(cfg.Accounts))
+	for _, a := range cfg.Accounts {
+		names = append(names, a.Name)
+	}
+	auth, err := cfg.Authenticator()
+	if err != nil {
+		log.Fatalf("unable to create endpoint authenticator: %s", err)
+		return
+	}
+	refData, err := cfg.RefDataCache()
+	if err != nil {
+		log.Fatalf("unable to load reference data: %s", err)
+		return
+	}
+	requestsEndpoint, err := endpoint.Serve(cfg.ServeAddr, auth, names...)
 	if err != nil {
 		log.Fatalf("unable to create endpoint: %s", err)
 		return
 	}
+	if refData != nil {
+		requestsEndpoint.SetValidator(refData)
+	}
+	var streams *order.Streams
+	if cfg.ReferenceData.Subscribe {
+		if streams, err = order.NewStreams(refData.Stream()); err != nil {
+			log.Fatalf("unable to register the reference data stream: %s", err)
+			return
+		}
+	}
+
+	// the shutdown channel is closed once the user requests shutdown, stopping every account.
+	// The endpoint stops taking orders first so that the accounts can drain their pending orders.
+	shutdown := make(chan interface{})
+	go func() {
+		<-interrupt
+		requestsEndpoint.Drain()
+		close(shutdown)
+	}()
+
+	// the order config of each account holds the state shared by its connections, such as the
+	// checkpoint, so that each new connection resumes where the last one stopped
+	orderConfigs := make([]order.Config, 0, len(cfg.Accounts))
+	audits := make([]*client.AuditLog, 0, len(cfg.Accounts))
+	reconcilers := make(map[string]http.Handler)
+	deadLetters := make(map[string]http.Handler)
+	executions := make(map[string]http.Handler)
+	balances := make(map[string]http.Handler)
+	subscriptions := make(map[string]http.Handler)
+	exports := make(map[string]http.Handler)
+	for _, a := range cfg.Accounts {
+		orderConfig := cfg.OrderConfig(a)
+		if orderConfig.Checkpoint, err = order.LoadCheckpoint(cfg.CheckpointPath(a)); err != nil {
+			log.Fatalf("account %s: %s", a.Name, err)
+			return
+		}
+		orderConfig.Reconciler = cfg.Reconciler(a)
+		orderConfig.Clock = cfg.Clock(a)
+		orderConfig.Merger = order.NewMerger(a.Name)
+		orderConfig.Streams = streams
+		orderConfig.Subscriptions = order.NewSubscriptions()
+		orderConfig.Quotes = cfg.AccountQuotes()
+		if orderConfig.Balances = cfg.AccountBalances(a); orderConfig.Balances != nil {
+			balances[a.Name] = orderConfig.Balances
+		}
+		if orderConfig.DeadLetters, err = order.OpenDeadLetters(a.Name, cfg.DeadLetterPath(a)); err != nil {
+			log.Fatalf("account %s: %s", a.Name, err)
+			return
+		}
+		var audit *client.AuditLog
+		if audit, err = cfg.AuditLog(a.Name); err != nil {
+			log.Fatalf("account %s: %s", a.Name, err)
+			return
+		}
+		audits = append(audits, audit)
+		orderConfigs = append(orderConfigs, orderConfig)
+		reconcilers[a.Name] = orderConfig.Reconciler
+		deadLetters[a.Name] = orderConfig.DeadLetters
+		executions[a.Name] = orderConfig.Merger
+		subscriptions[a.Name] = orderConfig.Subscriptions
+		exports[a.Name] = export.NewExporter(orderConfig.Merger, exportDialer(cfg, a, orderConfig.Clock, audit))
+	}
+	requestsEndpoint.HandleAccounts("/reconciliation", endpoint.PermissionRead, reconcilers)
+	requestsEndpoint.HandleAccounts("/deadletters", endpoint.PermissionRead, deadLetters)
+	requestsEndpoint.HandleAccounts("/executions", endpoint.PermissionRead, executions)
+	requestsEndpoint.HandleAccounts("/subscriptions", endpoint.PermissionTrade, subscriptions)
+	requestsEndpoint.HandleAccounts("/exports", endpoint.PermissionRead, exports)
+	if len(balances) > 0 {
+		requestsEndpoint.HandleAccounts("/balances", endpoint.PermissionRead, balances)
+	}
+
+	var wg sync.WaitGroup
+	for i, a := range cfg.Accounts {
+		wg.Add(1)
+		go func(a config.Account, orderConfig order.Config, audit *client.AuditLog) {
+			defer wg.Done()
+			runAccount(cfg, a, orderConfig, audit, requestsEndpoint, shutdown)
+		}(a, orderConfigs[i], audits[i])
+	}
+	wg.Wait()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Shutdown.HTTPTimeout))
+	defer cancel()
+	if err = requestsEndpoint.Shutdown(ctx); err != nil {
+		log.Printf("error shutting down endpoint: %s", err)
+	}
+}
+
+// loadConfig loads the config file, then applies the environment and the flags set on the
+// command line on top of it.
+func loadConfig() (cfg *config.Config, err error) {
+	if cfg, err = config.Load(*configPath); err != nil {
+		return
+	}
+	if err = cfg.ApplyEnv(os.LookupEnv); err != nil {
+		return
+	}
+
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "addr":
+			cfg.SetAddrs(*addr)
+		case "serve-addr":
+			cfg.ServeAddr = *serveAddr
+		}
+	})
+	if *apikey != "" || *apisecret != "" {
+		if cfg.Account(config.DefaultAccount) == nil {
+			cfg.Accounts = append(cfg.Accounts, config.Account{Name: config.DefaultAccount})
+		}
+		defaultAccount := cfg.Account(config.DefaultAccount)
+		if *apikey != "" {
+			defaultAccount.APIKey = *apikey
+		}
+		if *apisecret != "" {
+			defaultAccount.APISecret = *apisecret
+			defaultAccount.APISecretFile = ""
+		}
+	}
+	for _, a := range accounts {
+		if existing := cfg.Account(a.Name); existing != nil {
+			*existing = a
+			continue
+		}
+		cfg.Accounts = append(cfg.Accounts, a)
+	}
+	if len(rateLimits) > 0 && cfg.Client.RateLimits == nil {
+		cfg.Client.RateLimits = make(map[string]config.RateLimit)
+	}
+	for msgType, limit := range rateLimits {
+		cfg.Client.RateLimits[msgType] = config.RateLimit{Rate: limit.Rate, Burst: limit.Burst}
+	}
+
+	err = cfg.ResolveSecrets()
+	return
+}
 
+// exportDialer returns how to open a connection of the account for an export, to the first
+// address that accepts it.
+func exportDialer(cfg *config.Config, a config.Account, clock *client.Clock, audit *client.AuditLog) export.Dialer {
+	return func() (conn export.Connection, err error) {
+		options, err := cfg.ConnectOptions()
+		if err != nil {
+			return
+		}
+		options.Clock = clock
+		options.Audit = audit
+		options.Name = a.Name + "/export"
+		for _, addr := range cfg.Addrs() {
+			websocketClient, connectErr := client.ConnectWithOptions(addr, a.APIKey, a.APISecret, options)
+			if connectErr == nil {
+				return websocketClient, nil
+			}
+			err = connectErr
+			log.Printf("account %s export unable to connect to %s: %s", a.Name, addr, err)
+		}
+		return
+	}
+}
+
+const (
+	// stableConnection is how long a connection must last to reset the failures of its address.
+	stableConnection = time.Minute
+
+	// probeTimeout is how long to wait for the hello when probing the primary address.
+	probeTimeout = 10 * time.Second
+)
+
+// errFailBack is returned by connectAndRun when it closed the connection to fail back to the
+// primary address.
+var errFailBack = errors.New("failing back to the primary address")
+
+// runAccount serves the requests of the account on its pool of connections, routing the orders
+// by symbol, until shutdown.
+func runAccount(cfg *config.Config, a config.Account, orderConfig order.Config, audit *client.AuditLog,
+	requestsEndpoint *endpoint.Endpoint, shutdown <-chan interface{}) {
+	defer func() {
+		if err := orderConfig.Checkpoint.Flush(); err != nil {
+			log.Printf("account %s error flushing checkpoint: %s", a.Name, err)
+		}
+		if err := orderConfig.DeadLetters.Close(); err != nil {
+			log.Printf("account %s error closing dead letters: %s", a.Name, err)
+		}
+		if audit != nil {
+			if err := audit.Close(); err != nil {
+				log.Printf("account %s error closing audit log: %s", a.Name, err)
+			}
+		}
+	}()
+
+	router := order.NewRouter(requestsEndpoint.RequestsChannel(a.Name), cfg.Pool.Connections)
+	defer router.Close()
+	var wg sync.WaitGroup
+	for slot := 0; slot < cfg.Pool.Connections; slot++ {
+		name := a.Name
+		if cfg.Pool.Connections > 1 {
+			name = fmt.Sprintf("%s/%d", a.Name, slot+1)
+		}
+		wg.Add(1)
+		go func(name string, slot int) {
+			defer wg.Done()
+			runConnection(cfg, a, name, orderConfig, audit, router, slot, shutdown)
+		}(name, slot)
+	}
+	wg.Wait()
+}
+
+// runConnection connects and serves the requests routed to the given slot, re-connecting on
+// errors until shutdown. Repeated failures move to the next address, and the primary is probed
+// to fail back to it. The new connection resumes the streams from the checkpoint.
+func runConnection(cfg *config.Config, a config.Account, name string, orderConfig order.Config,
+	audit *client.AuditLog, router *order.Router, slot int, shutdown <-chan interface{}) {
+	failover := cfg.NewFailover(name)
 	for attempt := 0; ; attempt++ {
 		// check if the user requested shutdown
 		select {
-		case <-interrupt:
+		case <-shutdown:
 			return
 		default:
 		}
 
 		// connect to the websocket and serve requests
-		if runError := connectAndRun(*addr, *apikey, *apisecret, requestsEndpoint, attempt); runError != nil {
-			log.Printf("received error: %s, re-connecting after 5 seconds", runError)
-			time.Sleep(5 * time.Second)
+		started := time.Now()
+		runError := connectAndRun(cfg, a, name, failover, orderConfig, audit, router, slot, shutdown, attempt)
+		if runError == errFailBack {
+			continue
+		}
+		if runError != nil {
+			if time.Since(started) >= stableConnection {
+				failover.Succeeded()
+			}
+			next, _ := failover.Failed()
+			log.Printf("account %s received error: %s, re-connecting to %s after 5 seconds", name, runError, next)
+			select {
+			case <-shutdown:
+				return
+			case <-time.After(5 * time.Second):
+			}
 			continue
 		}
 		break
 	}
 }
 
-func connectAndRun(addr, apikey, apisecret string, requestsEndpoint *endpoint.Endpoint, attempt int) error {
-	websocketClient, err := client.Connect(addr, apikey, apisecret)
+func connectAndRun(cfg *config.Config, a config.Account, name string, failover *client.Failover,
+	orderConfig order.Config, audit *client.AuditLog, router *order.Router, slot int, shutdown <-chan interface{}, attempt int) error {
+	options, err := cfg.ConnectOptions()
+	if err != nil {
+		return err
+	}
+	options.Clock = orderConfig.Clock
+	options.Audit = audit
+	options.Name = name
+	websocketClient, err := client.ConnectWithOptions(failover.Addr(), a.APIKey, a.APISecret, options)
 	if err != nil {
-		if attempt == 0 {
-			// bail on connection if this is the first attempt
-			log.Fatalf(err.Error())
+		if attempt == 0 && len(cfg.FailoverAddrs) == 0 {
+			// bail on connection if this is the first attempt and there's nowhere to fail over to
+			log.Fatalf("account %s: %s", name, err)
 		}
 		return err
 	}
@@ -78,16 +371,54 @@ func connectAndRun(addr, apikey, apisecret string, requestsEndpoint *endpoint.En
 
 	handler, err := order.New(websocketClient.IncomingChannel(),
 		websocketClient.OutgoingChannel(),
-		requestsEndpoint.RequestsChannel())
+		router.Requests(slot),
+		orderConfig)
 	if err != nil {
 		log.Fatalf("unable to create order handler %s", err)
 	}
 	defer handler.Close()
+	router.SetHealthy(slot, true)
+	defer router.SetHealthy(slot, false)
 
-	select {
-	case <-interrupt:
-		return nil
-	case err = <-websocketClient.ErrorChannel():
-		return err
+	// probe the primary address while connected to another one. The probe is a connection of its
+	// own, so it has its own name in the metrics and the audit log.
+	probeOptions := options
+	probeOptions.Name = name + "/probe"
+	var probe <-chan time.Time
+	if !failover.OnPrimary() {
+		probeTicker := time.NewTicker(time.Duration(cfg.Failover.ProbeInterval))
+		defer probeTicker.Stop()
+		probe = probeTicker.C
+	}
+
+	for {
+		select {
+		case <-shutdown:
+			// drain the pending orders, then stop the handler before closing the websocket cleanly
+			if unknown := handler.Drain(cfg.DrainOptions()); unknown > 0 {
+				log.Printf("account %s shut down with %d orders of unknown outcome", name, unknown)
+			}
+			handler.Close()
+			websocketClient.Shutdown(time.Duration(cfg.Shutdown.CloseTimeout))
+			return nil
+		case <-probe:
+			if probeErr := client.Probe(failover.Primary(), a.APIKey, a.APISecret, probeOptions, probeTimeout); probeErr != nil {
+				log.Printf("account %s primary still failing: %s", name, probeErr)
+				continue
+			}
+			// the orders are cancelled on disconnect, so let the pending ones complete first
+			log.Printf("account %s primary %s is healthy again, failing back", name, failover.Primary())
+			if unknown := handler.Drain(order.DrainOptions{Timeout: time.Duration(cfg.Shutdown.DrainTimeout)}); unknown > 0 {
+				log.Printf("account %s failed back with %d orders of unknown outcome", name, unknown)
+			}
+			handler.Close()
+			websocketClient.Shutdown(time.Duration(cfg.Shutdown.CloseTimeout))
+			failover.FailBack()
+			return errFailBack
+		case err = <-websocketClient.ErrorChannel():
+			return err
+		case err = <-handler.ErrorChannel():
+			return err
+		}
 	}
 }
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+
+	"github.com/pintu-crypto/b2b-order/client"
+)
+
+// auditCommand verifies audit logs, or prints the records of an order. It reads the files
+// only, so it needs no account. Each log is checked to reach the record of its head file, so
+// that records dropped from its end are detected.
+func auditCommand(args []string) (err error) {
+	if len(args) == 0 || (args[0] != "verify" && args[0] != "query") {
+		return errors.New("audit verify|query <file>...")
+	}
+	flags := flag.NewFlagSet("audit "+args[0], flag.ExitOnError)
+	clOrdID := flags.String("clordid", "", "ClOrdID of the order to print the records of, including its cancels")
+	_ = flags.Parse(args[1:])
+	if flags.NArg() == 0 {
+		return errors.New("the audit log files are required")
+	}
+	if args[0] == "query" && *clOrdID == "" {
+		return errors.New("--clordid is required")
+	}
+
+	out := newPrinter(os.Stdout, *asJSON)
+	failed := 0
+	for _, path := range flags.Args() {
+		last, readErr := readAuditLog(path, func(record *client.AuditRecord) error {
+			if args[0] == "query" {
+				for _, id := range record.ClOrdIDs() {
+					if id == *clOrdID {
+						return out.auditRecord(record)
+					}
+				}
+			}
+			return nil
+		})
+		if readErr != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path, readErr)
+			continue
+		}
+		if args[0] == "verify" {
+			if last == nil {
+				fmt.Printf("%s: empty\n", path)
+			} else {
+				fmt.Printf("%s: %d records, last %s at %s\n", path, last.Seq, last.Hash, last.Time)
+			}
+		}
+	}
+	if err = out.flush(); err != nil {
+		return
+	}
+	if failed > 0 {
+		return errors.Errorf("%d of %d audit logs failed verification", failed, flags.NArg())
+	}
+	return
+}
+
+// readAuditLog reads the records of the audit log file, and returns the last one after checking
+// that the log reaches the record of its head file.
+func readAuditLog(path string, f func(record *client.AuditRecord) error) (last *client.AuditRecord, err error) {
+	// the head is read first, as the log may be appended to meanwhile
+	head, err := client.ReadAuditHead(path)
+	if err != nil {
+		return
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	err = client.ReadAuditLog(file, func(record *client.AuditRecord) error {
+		last = record
+		if head != nil && record.Seq == head.Seq && record.Hash != head.Hash {
+			return errors.Errorf("record %d doesn't match its head", record.Seq)
+		}
+		return f(record)
+	})
+	if err != nil {
+		return
+	}
+	switch {
+	case head == nil && last != nil:
+		err = errors.New("no head file, the end of the log can't be checked")
+	case head != nil && (last == nil || last.Seq < head.Seq):
+		seq := int64(0)
+		if last != nil {
+			seq = last.Seq
+		}
+		err = errors.Errorf("the log ends at record %d, before record %d of its head", seq, head.Seq)
+	}
+	return
+}
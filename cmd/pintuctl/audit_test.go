@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pintu-crypto/b2b-order/client"
+)
+
+func TestAuditVerify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.audit.jsonl")
+	audit, err := client.OpenAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err = audit.Record(client.AuditRecord{Conn: "test", Kind: client.AuditIn, Frame: []byte(`{"type":"heartbeat"}`)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = audit.Close()
+	if err = auditCommand([]string{"verify", path}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+
+	tests := []struct {
+		name     string
+		log      string
+		head     bool
+		expected string
+	}{
+		{"dropped last record", lines[0] + lines[1], true, "ends at record 2, before record 3"},
+		{"dropped every record", "", true, "ends at record 0, before record 3"},
+		{"no head", string(data), false, "no head file"},
+	}
+	for _, test := range tests {
+		if err = os.WriteFile(path, []byte(test.log), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if !test.head {
+			if err = os.Remove(client.AuditHeadPath(path)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err = auditCommand([]string{"verify", path}); err == nil {
+			t.Errorf("%s: verified", test.name)
+		}
+		if _, err = readAuditLog(path, func(*client.AuditRecord) error { return nil }); err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("%s: got %v, expected %s", test.name, err, test.expected)
+		}
+	}
+}
@@ -0,0 +1,614 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+
+	"github.com/pintu-crypto/b2b-order/client"
+	"github.com/pintu-crypto/b2b-order/config"
+	"github.com/pintu-crypto/b2b-order/costbasis"
+	"github.com/pintu-crypto/b2b-order/export"
+	"github.com/pintu-crypto/b2b-order/journal"
+)
+
+// orderCommand places an order and prints its execution reports until it's done, or until a
+// limit order is resting on the book.
+func orderCommand(cfg *config.Config, account config.Account, args []string) (err error) {
+	flags := flag.NewFlagSet("order", flag.ExitOnError)
+	symbol := flags.String("symbol", "", "Currency pair, e.g. DOGE-USDT")
+	currency := flags.String("currency", "", "Currency of the quantity, defaults to the base currency")
+	sideString := flags.String("side", "", "Buy or Sell")
+	quantityString := flags.String("qty", "", "Quantity to buy or sell")
+	typeString := flags.String("type", "Market", "Market or Limit")
+	priceString := flags.String("price", "", "Limit price, required for limit orders")
+	tifString := flags.String("tif", "", "Time in force, defaults to FillOrKill for market and GoodTillCancel for limit orders")
+	wait := flags.Duration("wait", 30*time.Second, "How long to wait for the order outcome")
+	_ = flags.Parse(args)
+
+	if *symbol == "" || *sideString == "" || *quantityString == "" {
+		return errors.New("--symbol, --side and --qty are required")
+	}
+	order := &client.NewOrderSingle{
+		Symbol:       *symbol,
+		Currency:     *currency,
+		ClOrdID:      uuid.New().String(),
+		TransactTime: client.MicrosTimestamp(time.Now()),
+		SubAccount:   account.SubAccount,
+		Group:        account.Group,
+	}
+	if order.Side, err = client.ParseSide(*sideString); err != nil {
+		return
+	}
+	if order.OrderQty, err = decimal.NewFromString(*quantityString); err != nil {
+		return errors.Wrapf(err, "invalid quantity %s", *quantityString)
+	}
+	if order.OrdType, err = client.ParseOrdType(*typeString); err != nil {
+		return
+	}
+	switch order.OrdType {
+	case client.OrdType.Market:
+		order.TimeInForce = client.TimeInForce.FillOrKill
+	case client.OrdType.Limit:
+		if *priceString == "" {
+			return errors.New("--price is required for limit orders")
+		}
+		var price decimal.Decimal
+		if price, err = decimal.NewFromString(*priceString); err != nil {
+			return errors.Wrapf(err, "invalid price %s", *priceString)
+		}
+		order.Price = &price
+		order.TimeInForce = client.TimeInForce.GoodTillCancel
+	default:
+		return fmt.Errorf("unsupported order type %s", *typeString)
+	}
+	if *tifString != "" {
+		if order.TimeInForce, err = client.ParseTimeInForce(*tifString); err != nil {
+			return
+		}
+	}
+	// check the order against the reference data file, if any, as the order server would
+	refData, err := cfg.RefDataCache()
+	if err != nil {
+		return
+	}
+	if refData != nil {
+		if err = refData.Validate(order); err != nil {
+			return errors.Wrap(err, "rejected")
+		}
+	}
+
+	s, err := connect(cfg, account)
+	if err != nil {
+		return
+	}
+	defer s.Close()
+	// the order isn't tied to the session, as the session ends when the command exits
+	if _, err = s.subscribe(client.StreamParameters{Name: "ExecutionReport"}); err != nil {
+		return
+	}
+	order.TransactTime = client.MicrosTimestamp(s.clock.Adjust(time.Time(order.TransactTime)))
+	if err = s.send(client.NewNewOrderSingleRequest(s.clock.Now(), s.nextRequestID(), order)); err != nil {
+		return
+	}
+
+	out := newPrinter(os.Stdout, *asJSON)
+	return waitForReports(s, *wait, func(report *client.ExecutionReport) (done bool, err error) {
+		if report.ClOrdID != order.ClOrdID {
+			return
+		}
+		if err = out.executionReport(report); err != nil {
+			return
+		}
+		if err = out.flush(); err != nil {
+			return
+		}
+		done = isTerminal(report.OrdStatus) ||
+			(order.TimeInForce != client.TimeInForce.FillOrKill &&
+				order.TimeInForce != client.TimeInForce.FillAndKill &&
+				report.OrdStatus == client.OrdStatus.New)
+		return
+	})
+}
+
+// cancelCommand cancels an order and prints the outcome.
+func cancelCommand(cfg *config.Config, account config.Account, args []string) (err error) {
+	flags := flag.NewFlagSet("cancel", flag.ExitOnError)
+	origClOrdID := flags.String("clordid", "", "ClOrdID of the order to cancel")
+	symbol := flags.String("symbol", "", "Symbol of the order to cancel")
+	wait := flags.Duration("wait", 30*time.Second, "How long to wait for the cancel outcome")
+	_ = flags.Parse(args)
+
+	if *origClOrdID == "" {
+		return errors.New("--clordid is required")
+	}
+	cancel := &client.OrderCancelRequest{
+		ClOrdID:      uuid.New().String(),
+		OrigClOrdID:  *origClOrdID,
+		Symbol:       *symbol,
+		TransactTime: client.MicrosTimestamp(time.Now()),
+	}
+
+	s, err := connect(cfg, account)
+	if err != nil {
+		return
+	}
+	defer s.Close()
+	if _, err = s.subscribe(client.StreamParameters{Name: "ExecutionReport"}); err != nil {
+		return
+	}
+	cancel.TransactTime = client.MicrosTimestamp(s.clock.Adjust(time.Time(cancel.TransactTime)))
+	if err = s.send(client.NewOrderCancelRequest(s.clock.Now(), s.nextRequestID(), cancel)); err != nil {
+		return
+	}
+
+	out := newPrinter(os.Stdout, *asJSON)
+	return waitForReports(s, *wait, func(report *client.ExecutionReport) (done bool, err error) {
+		if report.ClOrdID != cancel.ClOrdID {
+			return
+		}
+		if err = out.executionReport(report); err != nil {
+			return
+		}
+		if err = out.flush(); err != nil {
+			return
+		}
+		done = report.ExecType != client.ExecType.PendingCancel
+		return
+	})
+}
+
+// openOrdersCommand prints the open orders, which the server sends on subscribing to execution
+// reports without a start date.
+func openOrdersCommand(cfg *config.Config, account config.Account, args []string) (err error) {
+	flags := flag.NewFlagSet("open-orders", flag.ExitOnError)
+	wait := flags.Duration("wait", 2*time.Second, "How long to wait for more open orders after the last one")
+	_ = flags.Parse(args)
+
+	s, err := connect(cfg, account)
+	if err != nil {
+		return
+	}
+	defer s.Close()
+	if _, err = s.subscribe(client.StreamParameters{Name: "ExecutionReport"}); err != nil {
+		return
+	}
+
+	latest := make(map[string]*client.ExecutionReport)
+	err = waitForReports(s, *wait, func(report *client.ExecutionReport) (done bool, err error) {
+		latest[report.OrderID] = report
+		return
+	})
+	if err != nil && err != errTimeout {
+		return
+	}
+
+	var open []*client.ExecutionReport
+	for _, report := range latest {
+		if !isTerminal(report.OrdStatus) {
+			open = append(open, report)
+		}
+	}
+	sort.Slice(open, func(i, j int) bool {
+		return time.Time(open[i].SubmitTime).Before(time.Time(open[j].SubmitTime))
+	})
+	out := newPrinter(os.Stdout, *asJSON)
+	for _, report := range open {
+		if err = out.executionReport(report); err != nil {
+			return
+		}
+	}
+	return out.flush()
+}
+
+// tailCommand prints execution reports, trades or the elements of another stream as they happen.
+func tailCommand(cfg *config.Config, account config.Account, args []string) (err error) {
+	flags := flag.NewFlagSet("tail", flag.ExitOnError)
+	from := flags.String("from", "", "Replay from this time, RFC-3339 or YYYY-MM-DD")
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return errors.New("tail executions|trades|<stream>")
+	}
+	stream := client.StreamParameters{}
+	switch flags.Arg(0) {
+	case "executions":
+		stream.Name = "ExecutionReport"
+	case "trades":
+		stream.Name = "Trade"
+	default:
+		stream.Name = flags.Arg(0)
+	}
+	if *from != "" {
+		var startDate client.MicrosTimestamp
+		if startDate, err = parseTime(*from); err != nil {
+			return
+		}
+		stream.StartDate = &startDate
+	}
+
+	s, err := connect(cfg, account)
+	if err != nil {
+		return
+	}
+	defer s.Close()
+	if _, err = s.subscribe(stream); err != nil {
+		return
+	}
+
+	out := newPrinter(os.Stdout, *asJSON)
+	for {
+		var response *client.Response
+		if response, err = s.nextResponse(0); err != nil {
+			return
+		}
+		if err = printResponse(out, response); err != nil {
+			return
+		}
+		if err = out.flush(); err != nil {
+			return
+		}
+	}
+}
+
+// tradesCommand prints the trades in a time range.
+func tradesCommand(cfg *config.Config, account config.Account, args []string) (err error) {
+	flags := flag.NewFlagSet("trades", flag.ExitOnError)
+	from := flags.String("from", "", "Start of the range, RFC-3339 or YYYY-MM-DD")
+	to := flags.String("to", "", "End of the range, RFC-3339 or YYYY-MM-DD, defaults to now")
+	wait := flags.Duration("wait", 5*time.Second, "How long to wait for more trades after the last one")
+	_ = flags.Parse(args)
+
+	if *from == "" {
+		return errors.New("--from is required")
+	}
+	startDate, err := parseTime(*from)
+	if err != nil {
+		return
+	}
+	endDate := client.MicrosTimestamp(time.Now())
+	if *to != "" {
+		if endDate, err = parseTime(*to); err != nil {
+			return
+		}
+	}
+
+	s, err := connect(cfg, account)
+	if err != nil {
+		return
+	}
+	defer s.Close()
+	history, err := client.NewHistory(s.conn, s.nextRequestID(), "Trade",
+		time.Time(startDate), time.Time(endDate), *wait)
+	if err != nil {
+		return
+	}
+	defer history.Close()
+
+	out := newPrinter(os.Stdout, *asJSON)
+	for history.Next() {
+		trade := &client.Trade{}
+		if err = history.Decode(trade); err != nil {
+			return errors.Wrap(err, "unable to decode trade")
+		}
+		if err = out.trade(trade); err != nil {
+			return
+		}
+	}
+	if err = history.Err(); err != nil {
+		return
+	}
+	return out.flush()
+}
+
+// exportCommand writes the trades or execution reports in a time range to a file.
+func exportCommand(cfg *config.Config, account config.Account, args []string) (err error) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	from := flags.String("from", "", "Start of the range, RFC-3339 or YYYY-MM-DD")
+	to := flags.String("to", "", "End of the range, RFC-3339 or YYYY-MM-DD, defaults to now")
+	formatName := flags.String("format", "csv", "File format: csv, jsonl or parquet")
+	out := flags.String("out", "", "File to write, defaults to stdout")
+	wait := flags.Duration("wait", export.DefaultIdle, "How long to wait for more records after the last one")
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 1 || (flags.Arg(0) != "trades" && flags.Arg(0) != "executions") {
+		return errors.New("export trades|executions")
+	}
+	if *from == "" {
+		return errors.New("--from is required")
+	}
+	format, err := export.ParseFormat(*formatName)
+	if err != nil {
+		return
+	}
+	startDate, err := parseTime(*from)
+	if err != nil {
+		return
+	}
+	endDate := client.MicrosTimestamp(time.Now())
+	if *to != "" {
+		if endDate, err = parseTime(*to); err != nil {
+			return
+		}
+	}
+
+	s, err := connect(cfg, account)
+	if err != nil {
+		return
+	}
+	defer s.Close()
+	var records interface{}
+	if flags.Arg(0) == "trades" {
+		records, err = export.FetchTrades(s.conn, s.nextRequestID(), time.Time(startDate), time.Time(endDate), *wait)
+	} else {
+		records, err = export.FetchExecutionReports(s.conn, s.nextRequestID(), time.Time(startDate), time.Time(endDate), *wait)
+	}
+	if err != nil {
+		return
+	}
+
+	if *out == "" {
+		return export.Write(os.Stdout, format, records)
+	}
+	file, err := os.Create(*out)
+	if err != nil {
+		return errors.Wrapf(err, "unable to create %s", *out)
+	}
+	if err = export.Write(file, format, records); err != nil {
+		_ = file.Close()
+		return
+	}
+	return file.Close()
+}
+
+// costBasisCommand prints the gains realized by the sales in a time range, with the cost of
+// the lots bought since an earlier date.
+func costBasisCommand(cfg *config.Config, account config.Account, args []string) (err error) {
+	flags := flag.NewFlagSet("costbasis", flag.ExitOnError)
+	from := flags.String("from", "", "Start of the report, RFC-3339 or YYYY-MM-DD")
+	to := flags.String("to", "", "End of the report, excluded, RFC-3339 or YYYY-MM-DD, defaults to now")
+	since := flags.String("since", "", "Start of the trades making up the lots, defaults to --from")
+	methodName := flags.String("method", "fifo", "Cost basis method: fifo, lifo or average")
+	periodName := flags.String("period", "", "Total per day, month or year, instead of for the whole report")
+	zone := flags.String("tz", "UTC", "Time zone of the dates and periods, such as Asia/Jakarta")
+	wait := flags.Duration("wait", export.DefaultIdle, "How long to wait for more trades after the last one")
+	_ = flags.Parse(args)
+
+	if *from == "" {
+		return errors.New("--from is required")
+	}
+	if *since == "" {
+		since = from
+	}
+	method, err := costbasis.ParseMethod(*methodName)
+	if err != nil {
+		return
+	}
+	period, err := costbasis.ParsePeriod(*periodName)
+	if err != nil {
+		return
+	}
+	location, err := time.LoadLocation(*zone)
+	if err != nil {
+		return errors.Wrapf(err, "invalid time zone %s", *zone)
+	}
+	var startDate, reportDate, endDate time.Time
+	if startDate, err = parseTimeIn(*since, location); err != nil {
+		return
+	}
+	if reportDate, err = parseTimeIn(*from, location); err != nil {
+		return
+	}
+	endDate = time.Now().In(location)
+	if *to != "" {
+		if endDate, err = parseTimeIn(*to, location); err != nil {
+			return
+		}
+	}
+	if startDate.After(reportDate) {
+		return errors.New("--since is after --from, the lots would miss the trades in between")
+	}
+
+	s, err := connect(cfg, account)
+	if err != nil {
+		return
+	}
+	defer s.Close()
+	trades, err := export.FetchTrades(s.conn, s.nextRequestID(), startDate, endDate, *wait)
+	if err != nil {
+		return
+	}
+	ledger := costbasis.NewLedger(method)
+	if err = ledger.AddAll(trades); err != nil {
+		return
+	}
+
+	report := ledger.Report(reportDate, endDate, period)
+	out := newPrinter(os.Stdout, *asJSON)
+	for _, total := range report.Totals {
+		if err = out.total(total); err != nil {
+			return
+		}
+	}
+	if err = out.flush(); err != nil {
+		return
+	}
+	for _, fee := range report.Fees {
+		fmt.Fprintf(os.Stderr, "fee of %s %s on trade %s isn't in the cost basis\n", fee.Amount, fee.Currency, fee.TradeID)
+	}
+	return
+}
+
+// journalCommand writes the double-entry journal of the trades in a time range.
+func journalCommand(cfg *config.Config, account config.Account, args []string) (err error) {
+	flags := flag.NewFlagSet("journal", flag.ExitOnError)
+	from := flags.String("from", "", "Start of the range, RFC-3339 or YYYY-MM-DD")
+	to := flags.String("to", "", "End of the range, RFC-3339 or YYYY-MM-DD, defaults to now")
+	formatName := flags.String("format", "ledger", "File format: csv or ledger")
+	out := flags.String("out", "", "File to write, defaults to stdout")
+	wait := flags.Duration("wait", export.DefaultIdle, "How long to wait for more trades after the last one")
+	_ = flags.Parse(args)
+
+	if *from == "" {
+		return errors.New("--from is required")
+	}
+	format, err := journal.ParseFormat(*formatName)
+	if err != nil {
+		return
+	}
+	startDate, err := parseTime(*from)
+	if err != nil {
+		return
+	}
+	endDate := client.MicrosTimestamp(time.Now())
+	if *to != "" {
+		if endDate, err = parseTime(*to); err != nil {
+			return
+		}
+	}
+	mapping := cfg.JournalMapping()
+	if err = mapping.Validate(); err != nil {
+		return
+	}
+
+	s, err := connect(cfg, account)
+	if err != nil {
+		return
+	}
+	defer s.Close()
+	trades, err := export.FetchTrades(s.conn, s.nextRequestID(), time.Time(startDate), time.Time(endDate), *wait)
+	if err != nil {
+		return
+	}
+	// the trades of the account itself have no sub-account
+	for _, trade := range trades {
+		if trade.SubAccount == "" {
+			trade.SubAccount = account.SubAccount
+		}
+	}
+	entries, err := mapping.Entries(trades)
+	if err != nil {
+		return
+	}
+
+	if *out == "" {
+		return journal.Write(os.Stdout, format, entries)
+	}
+	file, err := os.Create(*out)
+	if err != nil {
+		return errors.Wrapf(err, "unable to create %s", *out)
+	}
+	if err = journal.Write(file, format, entries); err != nil {
+		_ = file.Close()
+		return
+	}
+	return file.Close()
+}
+
+// parseTimeIn parses an RFC-3339 time, or a YYYY-MM-DD date at midnight in the location.
+func parseTimeIn(value string, location *time.Location) (result time.Time, err error) {
+	if strings.Contains(value, "T") {
+		var ts client.MicrosTimestamp
+		if ts, err = parseTime(value); err == nil {
+			result = time.Time(ts).In(location)
+		}
+		return
+	}
+	if result, err = time.ParseInLocation("2006-01-02", value, location); err != nil {
+		err = errors.Wrapf(err, "invalid date %s", value)
+	}
+	return
+}
+
+// waitForReports calls the given function for every execution report until it returns done, or
+// until no message arrives within the timeout.
+func waitForReports(s *session, timeout time.Duration,
+	f func(report *client.ExecutionReport) (done bool, err error)) (err error) {
+	for {
+		var response *client.Response
+		if response, err = s.nextResponse(timeout); err != nil {
+			return
+		}
+		if response.Type != "ExecutionReport" {
+			continue
+		}
+		var reports []*client.ExecutionReport
+		if reports, err = executionReports(response); err != nil {
+			return
+		}
+		for _, report := range reports {
+			var done bool
+			if done, err = f(report); err != nil || done {
+				return
+			}
+		}
+	}
+}
+
+// printResponse prints the execution reports or trades of a response, or the elements of any
+// other stream as JSON.
+func printResponse(out *printer, response *client.Response) (err error) {
+	switch response.Type {
+	case "ExecutionReport":
+		var reports []*client.ExecutionReport
+		if reports, err = executionReports(response); err != nil {
+			return
+		}
+		for _, report := range reports {
+			if err = out.executionReport(report); err != nil {
+				return
+			}
+		}
+	case "Trade":
+		var batch []*client.Trade
+		if batch, err = trades(response); err != nil {
+			return
+		}
+		for _, trade := range batch {
+			if err = out.trade(trade); err != nil {
+				return
+			}
+		}
+	default:
+		for _, data := range response.Data {
+			if _, err = fmt.Fprintln(out.out, string(data)); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// isTerminal returns true if an order with the given status can't change anymore.
+func isTerminal(status client.OrdStatusEnum) bool {
+	switch status {
+	case client.OrdStatus.Filled, client.OrdStatus.Canceled,
+		client.OrdStatus.Rejected, client.OrdStatus.DoneForDay:
+		return true
+	}
+	return false
+}
+
+// parseTime parses an RFC-3339 time or a YYYY-MM-DD date in UTC.
+func parseTime(value string) (ts client.MicrosTimestamp, err error) {
+	if !strings.Contains(value, "T") {
+		var date time.Time
+		if date, err = time.Parse("2006-01-02", value); err != nil {
+			err = errors.Wrapf(err, "invalid date %s", value)
+			return
+		}
+		ts = client.MicrosTimestamp(date)
+		return
+	}
+	if ts, err = client.ParseMicrosTimestamp(value); err != nil {
+		err = errors.Wrapf(err, "invalid time %s", value)
+	}
+	return
+}
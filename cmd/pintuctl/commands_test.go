@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Time
+		valid    bool
+	}{
+		{"2026-03-01", time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), true},
+		{"2026-03-01T10:30:00Z", time.Date(2026, 3, 1, 10, 30, 0, 0, time.UTC), true},
+		{"2026-03-01T10:30:00.123456+07:00", time.Date(2026, 3, 1, 3, 30, 0, 123456000, time.UTC), true},
+		{"2026-02-30", time.Time{}, false},
+		{"2026-03-01T10:30", time.Time{}, false},
+		{"yesterday", time.Time{}, false},
+	}
+	for _, test := range tests {
+		ts, err := parseTime(test.value)
+		if (err == nil) != test.valid {
+			t.Errorf("%s: got error %v, expected valid %t", test.value, err, test.valid)
+			continue
+		}
+		if test.valid && !time.Time(ts).Equal(test.expected) {
+			t.Errorf("%s: got %s, expected %s", test.value, ts, test.expected)
+		}
+	}
+}
+
+func TestParseTimeIn(t *testing.T) {
+	jakarta := time.FixedZone("WIB", 7*60*60)
+	tests := []struct {
+		value    string
+		expected time.Time
+		valid    bool
+	}{
+		// a date is midnight in the location, not in UTC
+		{"2026-03-01", time.Date(2026, 3, 1, 0, 0, 0, 0, jakarta), true},
+		// a time keeps its own offset
+		{"2026-03-01T10:30:00Z", time.Date(2026, 3, 1, 10, 30, 0, 0, time.UTC), true},
+		{"2026-13-01", time.Time{}, false},
+		{"2026-03-01Tnoon", time.Time{}, false},
+	}
+	for _, test := range tests {
+		result, err := parseTimeIn(test.value, jakarta)
+		if (err == nil) != test.valid {
+			t.Errorf("%s: got error %v, expected valid %t", test.value, err, test.valid)
+			continue
+		}
+		if !test.valid {
+			continue
+		}
+		if !result.Equal(test.expected) || result.Location() != jakarta {
+			t.Errorf("%s: got %s, expected %s in %s", test.value, result, test.expected, jakarta)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+// pintuctl is a command line tool to act on a Pintu account directly over the websocket API.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/pintu-crypto/b2b-order/config"
+)
+
+const usage = `usage: pintuctl [flags] <command> [command flags]
+
+Commands:
+  order        place a market or limit order and wait for its outcome
+  cancel       cancel an order by its ClOrdID
+  open-orders  list the open orders
+  tail         follow executions, trades or another stream: tail executions|trades|<stream>
+  trades       list the trades between --from and --to
+  export       write the trades or executions between --from and --to as csv, jsonl or parquet
+  costbasis    report the gains realized between --from and --to, from the trades since --since
+  journal      write the double-entry journal of the trades between --from and --to as ledger or csv
+  audit        check audit logs for gaps, tampering and records dropped since their <file>.head,
+               or print the records of an order:
+               audit verify <file>..., audit query --clordid <ClOrdID> <file>...
+
+Run 'pintuctl <command> -h' for the command flags.
+
+Flags:
+`
+
+var configPath = flag.String("config", "", "Path to a YAML config file, as used by the order server")
+var addr = flag.String("addr", "", "Pintu websocket address, or a comma separated list tried in order")
+var accountName = flag.String("account", config.DefaultAccount, "Name of the account to use")
+var apikey = flag.String("apikey", "", "Pintu api key")
+var apisecret = flag.String("apisecret", "", "Pintu api secret, prefer PINTU_APISECRET_FILE or the config file")
+var asJSON = flag.Bool("json", false, "Print JSON lines instead of tables")
+var verbose = flag.Bool("v", false, "Log connection details")
+
+// command is a pintuctl subcommand.
+type command func(cfg *config.Config, account config.Account, args []string) error
+
+var commands = map[string]command{
+	"order":       orderCommand,
+	"cancel":      cancelCommand,
+	"open-orders": openOrdersCommand,
+	"tail":        tailCommand,
+	"trades":      tradesCommand,
+	"export":      exportCommand,
+	"costbasis":   costBasisCommand,
+	"journal":     journalCommand,
+}
+
+// offlineCommands are the subcommands that don't connect, so they need no account.
+var offlineCommands = map[string]func(args []string) error{
+	"audit": auditCommand,
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if !*verbose {
+		log.SetOutput(io.Discard)
+	}
+	if offline, ok := offlineCommands[flag.Arg(0)]; ok {
+		if err := offline(flag.Args()[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	run, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %s\n", flag.Arg(0))
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, account, err := loadAccount()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(1)
+	}
+	if err = run(cfg, account, flag.Args()[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// loadAccount loads the config the same way as the order server and returns the selected account.
+func loadAccount() (cfg *config.Config, account config.Account, err error) {
+	if cfg, err = config.Load(*configPath); err != nil {
+		return
+	}
+	if err = cfg.ApplyEnv(os.LookupEnv); err != nil {
+		return
+	}
+	if *addr != "" {
+		cfg.SetAddrs(*addr)
+	}
+	if *apikey != "" || *apisecret != "" {
+		if cfg.Account(*accountName) == nil {
+			cfg.Accounts = append(cfg.Accounts, config.Account{Name: *accountName})
+		}
+		selected := cfg.Account(*accountName)
+		if *apikey != "" {
+			selected.APIKey = *apikey
+		}
+		if *apisecret != "" {
+			selected.APISecret = *apisecret
+			selected.APISecretFile = ""
+		}
+	}
+	selected := cfg.Account(*accountName)
+	if selected == nil {
+		err = fmt.Errorf("unknown account %s", *accountName)
+		return
+	}
+	// only the selected account is resolved and validated, so that a shared config can be used
+	// with just the secret of that account, and without the endpoint's api key secrets
+	cfg.Accounts = []config.Account{*selected}
+	cfg.Endpoint.APIKeys = nil
+	if err = cfg.ResolveSecrets(); err != nil {
+		return
+	}
+	if err = cfg.Validate(); err != nil {
+		return
+	}
+	account = cfg.Accounts[0]
+	return
+}
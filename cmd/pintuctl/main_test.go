@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// setFlags sets the global flags for a test, and restores them once it's done.
+func setFlags(t *testing.T, path string, account string, key string, secret string) {
+	saved := []string{*configPath, *addr, *accountName, *apikey, *apisecret}
+	t.Cleanup(func() {
+		*configPath, *addr, *accountName, *apikey, *apisecret = saved[0], saved[1], saved[2], saved[3], saved[4]
+	})
+	*configPath, *addr, *accountName, *apikey, *apisecret = path, "", account, key, secret
+}
+
+func TestLoadAccount(t *testing.T) {
+	dir := t.TempDir()
+	secretFile := filepath.Join(dir, "entity-a-secret")
+	if err := os.WriteFile(secretFile, []byte("secret-a\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	// entity-b has no secret, and the endpoint api key secret can't be read by pintuctl
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(path, []byte(`
+addr: wss://example.com/ws/v1
+accounts:
+  - name: entity-a
+    apiKey: KEY-A
+    apiSecretFile: `+secretFile+`
+    subAccount: desk-a
+  - name: entity-b
+    apiKey: KEY-B
+endpoint:
+  apiKeys:
+    - key: desk-1
+      secretFile: `+filepath.Join(dir, "missing")+`
+      permission: trade
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		account string
+		key     string
+		secret  string
+		// expected is the api key and secret of the selected account, or the error
+		expected string
+	}{
+		{"selected account only", path, "entity-a", "", "", "KEY-A secret-a"},
+		{"missing secret", path, "entity-b", "", "", "apiSecret or apiSecretFile is required for account entity-b"},
+		{"secret flag", path, "entity-b", "", "secret-b", "KEY-B secret-b"},
+		{"secret flag replaces the file", path, "entity-a", "", "secret-flag", "KEY-A secret-flag"},
+		{"unknown account", path, "entity-c", "", "", "unknown account entity-c"},
+		{"flags only", "", "default", "KEY", "secret", "KEY secret"},
+		{"flags for a new account", path, "entity-c", "KEY-C", "secret-c", "KEY-C secret-c"},
+	}
+	for _, test := range tests {
+		setFlags(t, test.path, test.account, test.key, test.secret)
+		if test.path == "" {
+			*addr = "wss://example.com/ws/v1"
+		}
+		cfg, account, err := loadAccount()
+		if err != nil {
+			if !strings.Contains(err.Error(), test.expected) {
+				t.Errorf("%s: got error %s, expected %s", test.name, err, test.expected)
+			}
+			continue
+		}
+		if got := account.APIKey + " " + account.APISecret; got != test.expected {
+			t.Errorf("%s: got %s, expected %s", test.name, got, test.expected)
+			continue
+		}
+		if account.Name != test.account || len(cfg.Accounts) != 1 || len(cfg.Endpoint.APIKeys) != 0 {
+			t.Errorf("%s: got account %s of %d, expected only the selected account", test.name,
+				account.Name, len(cfg.Accounts))
+		}
+	}
+}
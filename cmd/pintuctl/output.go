@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pintu-crypto/b2b-order/client"
+	"github.com/pintu-crypto/b2b-order/costbasis"
+)
+
+// printer writes execution reports and trades either as aligned text or as JSON lines.
+type printer struct {
+	json   bool
+	out    io.Writer
+	table  *tabwriter.Writer
+	header bool
+}
+
+func newPrinter(out io.Writer, asJSON bool) *printer {
+	return &printer{
+		json:  asJSON,
+		out:   out,
+		table: tabwriter.NewWriter(out, 0, 4, 2, ' ', 0),
+	}
+}
+
+// printJSON writes the value as a single JSON line.
+func (p *printer) printJSON(value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(p.out, string(data))
+	return err
+}
+
+// executionReport prints an execution report.
+func (p *printer) executionReport(report *client.ExecutionReport) error {
+	if p.json {
+		return p.printJSON(report)
+	}
+	if !p.header {
+		fmt.Fprintln(p.table, "TIME\tSYMBOL\tSIDE\tTYPE\tSTATUS\tEXEC\tQTY\tPRICE\tCUMQTY\tAVGPX\tCLORDID\tORDERID\tTEXT")
+		p.header = true
+	}
+	price := ""
+	if report.OrdType == client.OrdType.Limit {
+		price = report.Price.String()
+	}
+	fmt.Fprintf(p.table, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+		report.TransactTime, report.Symbol, client.SideString(report.Side), client.OrdTypeString(report.OrdType),
+		client.OrdStatusString(report.OrdStatus), client.ExecTypeString(report.ExecType),
+		report.OrderQty, price, report.CumQty, report.AvgPx, report.ClOrdID, report.OrderID, report.Text)
+	return nil
+}
+
+// trade prints a trade.
+func (p *printer) trade(trade *client.Trade) error {
+	if p.json {
+		return p.printJSON(trade)
+	}
+	if !p.header {
+		fmt.Fprintln(p.table, "TIME\tSYMBOL\tSIDE\tQTY\tPRICE\tAMOUNT\tFEE\tTRADEID\tORDERID\tSTATUS")
+		p.header = true
+	}
+	fmt.Fprintf(p.table, "%s\t%s\t%s\t%s %s\t%s\t%s %s\t%s %s\t%s\t%s\t%s\n",
+		trade.TransactTime, trade.Symbol, client.SideString(trade.Side), trade.Quantity, trade.Currency,
+		trade.Price, trade.Amount, trade.AmountCurrency, trade.Fee, trade.FeeCurrency,
+		trade.TradeID, trade.OrderID, trade.TradeStatus)
+	return nil
+}
+
+// total prints the realized gains of a currency in a period.
+func (p *printer) total(total costbasis.Total) error {
+	if p.json {
+		return p.printJSON(total)
+	}
+	if !p.header {
+		fmt.Fprintln(p.table, "PERIOD\tCURRENCY\tSALES\tQTY\tPROCEEDS\tCOST\tGAIN")
+		p.header = true
+	}
+	fmt.Fprintf(p.table, "%s\t%s\t%d\t%s\t%s %s\t%s %s\t%s %s\n",
+		total.Period, total.Currency, total.Disposals, total.Quantity, total.Proceeds, total.CostCurrency,
+		total.Cost, total.CostCurrency, total.Gain, total.CostCurrency)
+	return nil
+}
+
+// flush writes the buffered rows, aligning the columns across them.
+func (p *printer) flush() error {
+	return p.table.Flush()
+}
+
+// auditRecord prints a record of an audit log.
+func (p *printer) auditRecord(record *client.AuditRecord) error {
+	if p.json {
+		return p.printJSON(record)
+	}
+	if !p.header {
+		fmt.Fprintln(p.table, "SEQ\tTIME\tCONN\tKIND\tTYPE\tCLORDIDS\tERROR")
+		p.header = true
+	}
+	fmt.Fprintf(p.table, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", record.Seq, record.Time, record.Conn, record.Kind,
+		record.Type(), strings.Join(record.ClOrdIDs(), ","), record.Error)
+	return nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/pintu-crypto/b2b-order/client"
+)
+
+func testReports() []*client.ExecutionReport {
+	price := decimal.RequireFromString("0.2")
+	ts := client.MicrosTimestamp(time.Date(2026, 3, 1, 10, 30, 0, 0, time.UTC))
+	return []*client.ExecutionReport{
+		{TransactTime: ts, Symbol: "DOGE-USDT", Side: client.Side.Buy, OrdType: client.OrdType.Limit,
+			OrdStatus: client.OrdStatus.New, ExecType: client.ExecType.New, OrderQty: decimal.NewFromInt(1000),
+			Price: price, ClOrdID: "C1", OrderID: "O1"},
+		{TransactTime: ts, Symbol: "BTC-IDR", Side: client.Side.Sell, OrdType: client.OrdType.Market,
+			OrdStatus: client.OrdStatus.Rejected, ExecType: client.ExecType.Rejected,
+			OrderQty: decimal.RequireFromString("0.5"), ClOrdID: "C2", Text: "insufficient balance"},
+	}
+}
+
+func TestPrinterTable(t *testing.T) {
+	var out bytes.Buffer
+	p := newPrinter(&out, false)
+	for _, report := range testReports() {
+		if err := p.executionReport(report); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.flush(); err != nil {
+		t.Fatal(err)
+	}
+	expected := "" +
+		"TIME                         SYMBOL     SIDE  TYPE    STATUS    EXEC      QTY   PRICE  CUMQTY  AVGPX  CLORDID  ORDERID  TEXT\n" +
+		"2026-03-01T10:30:00.000000Z  DOGE-USDT  Buy   Limit   New       New       1000  0.2    0       0      C1       O1       \n" +
+		"2026-03-01T10:30:00.000000Z  BTC-IDR    Sell  Market  Rejected  Rejected  0.5          0       0      C2                insufficient balance\n"
+	if out.String() != expected {
+		t.Errorf("got\n%s\nexpected\n%s", out.String(), expected)
+	}
+}
+
+func TestPrinterJSON(t *testing.T) {
+	var out bytes.Buffer
+	p := newPrinter(&out, true)
+	for _, report := range testReports() {
+		if err := p.executionReport(report); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.flush(); err != nil {
+		t.Fatal(err)
+	}
+	// one report per line, without a header
+	lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, expected 2: %s", len(lines), out.String())
+	}
+	var report client.ExecutionReport
+	if err := json.Unmarshal(lines[1], &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.ClOrdID != "C2" || report.OrdStatus != client.OrdStatus.Rejected || report.Text != "insufficient balance" {
+		t.Errorf("got %+v, expected the second report", report)
+	}
+}
+
+func TestPrinterTrade(t *testing.T) {
+	var out bytes.Buffer
+	p := newPrinter(&out, false)
+	err := p.trade(&client.Trade{
+		TransactTime: client.MicrosTimestamp(time.Date(2026, 3, 1, 10, 30, 0, 0, time.UTC)),
+		Symbol:       "DOGE-USDT", Side: client.Side.Buy, Quantity: decimal.NewFromInt(1000), Currency: "DOGE",
+		Price: decimal.RequireFromString("0.2"), Amount: decimal.NewFromInt(200), AmountCurrency: "USDT",
+		Fee: decimal.RequireFromString("0.1"), FeeCurrency: "USDT", TradeID: "T1", OrderID: "O1",
+		TradeStatus: client.TradeStatus.Confirmed,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = p.flush(); err != nil {
+		t.Fatal(err)
+	}
+	expected := "" +
+		"TIME                         SYMBOL     SIDE  QTY        PRICE  AMOUNT    FEE       TRADEID  ORDERID  STATUS\n" +
+		"2026-03-01T10:30:00.000000Z  DOGE-USDT  Buy   1000 DOGE  0.2    200 USDT  0.1 USDT  T1       O1       Confirmed\n"
+	if out.String() != expected {
+		t.Errorf("got\n%s\nexpected\n%s", out.String(), expected)
+	}
+}
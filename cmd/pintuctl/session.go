@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/pintu-crypto/b2b-order/client"
+	"github.com/pintu-crypto/b2b-order/config"
+)
+
+// connection is the subset of the websocket client used by a session.
+type connection interface {
+	IncomingChannel() client.IncomingChannel
+	OutgoingChannel() client.OutgoingChannel
+	ErrorChannel() client.ErrorChannel
+	Close()
+}
+
+// session is a single websocket connection used by a command.
+type session struct {
+	conn      connection
+	requestID int64
+	sessionID string
+	clock     *client.Clock
+	audit     *client.AuditLog
+}
+
+// connect connects the account and waits for the hello message.
+func connect(cfg *config.Config, account config.Account) (s *session, err error) {
+	options, err := cfg.ConnectOptions()
+	if err != nil {
+		return
+	}
+	options.Clock = cfg.Clock(account)
+	options.Name = "pintuctl/" + account.Name
+	// the connections of pintuctl are recorded in an audit log of their own, as the server
+	// holds the log of the account
+	if options.Audit, err = cfg.AuditLog(account.Name + ".pintuctl"); err != nil {
+		return
+	}
+	// try the addresses in order, the first one being the primary
+	var conn connection
+	for _, addr := range cfg.Addrs() {
+		if conn, err = client.ConnectWithOptions(addr, account.APIKey, account.APISecret, options); err == nil {
+			break
+		}
+		log.Printf("unable to connect to %s: %s", addr, err)
+	}
+	if err != nil {
+		if options.Audit != nil {
+			_ = options.Audit.Close()
+		}
+		return
+	}
+	s = &session{conn: conn, clock: options.Clock, audit: options.Audit}
+	msg, err := s.next(5 * time.Second)
+	received := time.Now()
+	if err != nil {
+		s.Close()
+		err = errors.Wrap(err, "no hello message")
+		return
+	}
+	hello := client.Hello{}
+	if err = json.Unmarshal(msg, &hello); err != nil {
+		s.Close()
+		err = errors.Wrap(err, "unable to decode hello message")
+		return
+	}
+	s.clock.Observe(hello.Timestamp, received)
+	if s.clock.Skewed() {
+		fmt.Fprintf(os.Stderr, "warning: the local clock is %s off the server clock\n", -s.clock.Offset())
+	}
+	s.sessionID = hello.SessionID
+	return
+}
+
+// Close closes the connection.
+func (s *session) Close() {
+	s.conn.Close()
+	if s.audit != nil {
+		_ = s.audit.Close()
+	}
+}
+
+// nextRequestID returns the ID for the next request.
+func (s *session) nextRequestID() int64 {
+	s.requestID++
+	return s.requestID
+}
+
+// send sends the given request.
+func (s *session) send(request interface{}) (err error) {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return errors.Wrap(err, "unable to encode request")
+	}
+	s.conn.OutgoingChannel() <- data
+	return
+}
+
+// subscribe subscribes to the given streams and returns the request ID.
+func (s *session) subscribe(streams ...client.StreamParameters) (requestID int64, err error) {
+	requestID = s.nextRequestID()
+	err = s.send(client.NewSubscribeRequest(s.clock.Now(), requestID, streams...))
+	return
+}
+
+// next returns the next message, or an error if none arrives within the timeout. A zero
+// timeout waits forever.
+func (s *session) next(timeout time.Duration) (msg []byte, err error) {
+	var timeoutC <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutC = timer.C
+	}
+	select {
+	case msg = <-s.conn.IncomingChannel():
+	case err = <-s.conn.ErrorChannel():
+	case <-timeoutC:
+		err = errTimeout
+	}
+	return
+}
+
+// errTimeout is returned by next when no message arrives in time.
+var errTimeout = errors.New("timed out waiting for a message")
+
+// nextResponse returns the next response, failing on error responses.
+func (s *session) nextResponse(timeout time.Duration) (response *client.Response, err error) {
+	msg, err := s.next(timeout)
+	if err != nil {
+		return
+	}
+	response = &client.Response{}
+	if err = json.Unmarshal(msg, response); err != nil {
+		err = errors.Wrap(err, "unable to decode response")
+		return
+	}
+	if response.Error != nil {
+		err = fmt.Errorf("request %d failed: %s (%d)", response.ReqID, response.Error.Message, response.Error.Code)
+	}
+	return
+}
+
+// executionReports decodes the execution reports of a response.
+func executionReports(response *client.Response) (reports []*client.ExecutionReport, err error) {
+	for _, data := range response.Data {
+		report := &client.ExecutionReport{}
+		if err = json.Unmarshal(data, report); err != nil {
+			err = errors.Wrap(err, "unable to decode execution report")
+			return
+		}
+		reports = append(reports, report)
+	}
+	return
+}
+
+// trades decodes the trades of a response.
+func trades(response *client.Response) (result []*client.Trade, err error) {
+	for _, data := range response.Data {
+		trade := &client.Trade{}
+		if err = json.Unmarshal(data, trade); err != nil {
+			err = errors.Wrap(err, "unable to decode trade")
+			return
+		}
+		result = append(result, trade)
+	}
+	return
+}
@@ -0,0 +1,750 @@
+package config
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+
+	"github.com/pintu-crypto/b2b-order/client"
+	"github.com/pintu-crypto/b2b-order/endpoint"
+	"github.com/pintu-crypto/b2b-order/journal"
+	"github.com/pintu-crypto/b2b-order/order"
+	"github.com/pintu-crypto/b2b-order/refdata"
+)
+
+// envPrefix is the prefix of all environment variables read by the config loader.
+const envPrefix = "PINTU_"
+
+// redacted replaces secrets when the config is printed.
+const redacted = "<redacted>"
+
+// Config is the application configuration, loaded from a YAML file and overridden by
+// environment variables and command line flags.
+type Config struct {
+	Addr string `yaml:"addr"`
+	// FailoverAddrs are tried in order when Addr, the primary, fails.
+	FailoverAddrs []string      `yaml:"failoverAddrs,omitempty"`
+	ServeAddr     string        `yaml:"serveAddr"`
+	Accounts      []Account     `yaml:"accounts"`
+	Client        Client        `yaml:"client"`
+	Failover      Failover      `yaml:"failover"`
+	Pool          Pool          `yaml:"pool"`
+	Order         Order         `yaml:"order"`
+	ReferenceData ReferenceData `yaml:"referenceData"`
+	Balances      Balances      `yaml:"balances"`
+	Quotes        Quotes        `yaml:"quotes"`
+	Journal       Journal       `yaml:"journal"`
+	Shutdown      Shutdown      `yaml:"shutdown"`
+	Endpoint      Endpoint      `yaml:"endpoint"`
+}
+
+// Account is a named set of Pintu credentials. The secret can be given inline or, preferably,
+// read from a file.
+type Account struct {
+	Name          string `yaml:"name"`
+	APIKey        string `yaml:"apiKey"`
+	APISecret     string `yaml:"apiSecret,omitempty"`
+	APISecretFile string `yaml:"apiSecretFile,omitempty"`
+	SubAccount    string `yaml:"subAccount,omitempty"`
+	Group         string `yaml:"group,omitempty"`
+}
+
+// Client contains the websocket connection tunables.
+type Client struct {
+	WriteWait        Duration             `yaml:"writeWait"`
+	PongWait         Duration             `yaml:"pongWait"`
+	PingPeriod       Duration             `yaml:"pingPeriod"`
+	MaxMessageSize   int64                `yaml:"maxMessageSize"`
+	HandshakeTimeout Duration             `yaml:"handshakeTimeout"`
+	RateLimits       map[string]RateLimit `yaml:"rateLimits,omitempty"`
+	// ReadBufferSize and WriteBufferSize are the websocket I/O buffer sizes, 4096 if zero.
+	ReadBufferSize  int `yaml:"readBufferSize,omitempty"`
+	WriteBufferSize int `yaml:"writeBufferSize,omitempty"`
+	// IncomingBufferSize and OutgoingBufferSize are the channel capacities, in messages.
+	IncomingBufferSize int `yaml:"incomingBufferSize"`
+	OutgoingBufferSize int `yaml:"outgoingBufferSize"`
+	// Headers are extra headers sent with the websocket handshake. Their values are redacted when
+	// the config is printed.
+	Headers map[string]string `yaml:"headers,omitempty"`
+	// Proxy is the URL of an HTTP or SOCKS5 proxy. If empty, HTTPS_PROXY and HTTP_PROXY are used.
+	Proxy             string `yaml:"proxy,omitempty"`
+	EnableCompression bool   `yaml:"enableCompression"`
+	TLS               TLS    `yaml:"tls,omitempty"`
+	// OverflowPolicy is what to do with incoming messages when the handler falls behind: block,
+	// dropOldest or spill to a file in SpillDir, up to SpillMaxBytes.
+	OverflowPolicy string `yaml:"overflowPolicy"`
+	SpillDir       string `yaml:"spillDir,omitempty"`
+	SpillMaxBytes  int64  `yaml:"spillMaxBytes,omitempty"`
+	// ClockSkewThreshold is the offset from the server clock over which a warning is logged,
+	// and CompensateClockSkew applies the offset to the signatures and request timestamps.
+	ClockSkewThreshold  Duration `yaml:"clockSkewThreshold"`
+	CompensateClockSkew bool     `yaml:"compensateClockSkew"`
+	// AuditDir keeps a hash-chained audit log of the connects and frames of each account.
+	AuditDir string `yaml:"auditDir,omitempty"`
+}
+
+// TLS contains the TLS settings of wss connections.
+type TLS struct {
+	// CAFile is a PEM file of the certificate authorities to trust instead of the system ones.
+	CAFile string `yaml:"caFile,omitempty"`
+	// CertFile and KeyFile are the client certificate, for proxies or servers requiring one.
+	CertFile   string `yaml:"certFile,omitempty"`
+	KeyFile    string `yaml:"keyFile,omitempty"`
+	ServerName string `yaml:"serverName,omitempty"`
+}
+
+// RateLimit is the token bucket limit for an outgoing message type.
+type RateLimit struct {
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
+}
+
+// Failover contains the settings to switch between the websocket addresses.
+type Failover struct {
+	// MaxFailures is how many connections to an address may fail in a row before moving on to
+	// the next address.
+	MaxFailures int `yaml:"maxFailures"`
+	// ProbeInterval is how often the primary address is probed, while connected to another one,
+	// to fail back once it is healthy.
+	ProbeInterval Duration `yaml:"probeInterval"`
+}
+
+// Pool contains the settings of the connections of each account.
+type Pool struct {
+	// Connections is the number of connections of each account. The orders are distributed
+	// across them by symbol, and their execution reports merged.
+	Connections int `yaml:"connections"`
+}
+
+// Order contains the order handler tunables.
+type Order struct {
+	TradeLookback Duration `yaml:"tradeLookback"`
+	// CheckpointDir is the directory of the per account checkpoint files. If empty, the
+	// checkpoints are only kept in memory and lost on restart.
+	CheckpointDir      string   `yaml:"checkpointDir,omitempty"`
+	CheckpointInterval Duration `yaml:"checkpointInterval"`
+	// ReconcileGrace is how long an order must be quiet before its trades are reconciled
+	// with its execution reports, and ReconcileRetention how long reconciled orders are kept.
+	ReconcileGrace     Duration `yaml:"reconcileGrace"`
+	ReconcileRetention Duration `yaml:"reconcileRetention"`
+	// DeadLetterDir is the directory of the per account dead letter files. If empty, the dead
+	// letters are only kept in memory.
+	DeadLetterDir string `yaml:"deadLetterDir,omitempty"`
+	// MaxFailures is how many messages may fail within the FailureWindow before reconnecting.
+	MaxFailures   int      `yaml:"maxFailures"`
+	FailureWindow Duration `yaml:"failureWindow"`
+}
+
+// ReferenceData contains the settings of the symbol reference data, used to validate the
+// orders before they are sent. If neither is set, the orders aren't validated.
+type ReferenceData struct {
+	// Subscribe keeps the reference data up to date from the Security stream.
+	Subscribe bool `yaml:"subscribe"`
+	// File is a file of securities to start from, or to use offline, one JSON object per line.
+	File string `yaml:"file,omitempty"`
+}
+
+// Balances contains the settings of the account balances.
+type Balances struct {
+	// Subscribe keeps the balances of each account from the Balance stream, served on /balances.
+	Subscribe bool `yaml:"subscribe"`
+	// RejectInsufficient rejects the orders exceeding the available balance before they are sent.
+	RejectInsufficient bool `yaml:"rejectInsufficient"`
+}
+
+// Quotes contains the settings of the quote requests.
+type Quotes struct {
+	// Enabled subscribes every account to the Quote stream, to request quotes on /quote and
+	// accept them with RFQ orders.
+	Enabled bool `yaml:"enabled"`
+}
+
+// Journal contains the ledger accounts of the journal of trades. The account names may contain
+// {currency} and {subaccount}, replaced by the currency and the sub-account of the posting.
+type Journal struct {
+	// Asset is the account holding a currency at Pintu.
+	Asset string `yaml:"asset"`
+	// Trading is the account balancing the currencies exchanged by a trade.
+	Trading string `yaml:"trading"`
+	// Fees is the expense account of the fees.
+	Fees string `yaml:"fees"`
+	// Accounts replace the accounts of some currencies or sub-accounts, the first match first.
+	Accounts []JournalAccount `yaml:"accounts,omitempty"`
+}
+
+// JournalAccount replaces the journal accounts of a currency, a sub-account, or both.
+type JournalAccount struct {
+	Currency   string `yaml:"currency,omitempty"`
+	SubAccount string `yaml:"subAccount,omitempty"`
+	Asset      string `yaml:"asset,omitempty"`
+	Trading    string `yaml:"trading,omitempty"`
+	Fees       string `yaml:"fees,omitempty"`
+}
+
+// Shutdown contains the graceful shutdown settings.
+type Shutdown struct {
+	// DrainTimeout is how long to wait for pending orders to complete.
+	DrainTimeout Duration `yaml:"drainTimeout"`
+	// CancelRemaining cancels the orders still pending after the drain timeout.
+	CancelRemaining bool `yaml:"cancelRemaining"`
+	// CancelTimeout is how long to wait for the cancels to complete.
+	CancelTimeout Duration `yaml:"cancelTimeout"`
+	// CloseTimeout is how long to wait for the server to close the websocket.
+	CloseTimeout Duration `yaml:"closeTimeout"`
+	// HTTPTimeout is how long to wait for the http server to shut down.
+	HTTPTimeout Duration `yaml:"httpTimeout"`
+}
+
+// Endpoint contains the authentication settings of the order endpoint. If no API keys are
+// configured, the endpoint is not authenticated.
+type Endpoint struct {
+	// SignatureWindow is the maximum age of a request signature.
+	SignatureWindow Duration `yaml:"signatureWindow"`
+	// AuditFile records which API key placed which order, as JSON lines.
+	AuditFile string   `yaml:"auditFile,omitempty"`
+	APIKeys   []APIKey `yaml:"apiKeys,omitempty"`
+}
+
+// APIKey is a key of an internal caller of the order endpoint.
+type APIKey struct {
+	Key        string `yaml:"key"`
+	Secret     string `yaml:"secret,omitempty"`
+	SecretFile string `yaml:"secretFile,omitempty"`
+	// Permission is either read or trade.
+	Permission string `yaml:"permission"`
+	// Symbols and Accounts restrict the key, if not empty.
+	Symbols  []string `yaml:"symbols,omitempty"`
+	Accounts []string `yaml:"accounts,omitempty"`
+}
+
+// Duration is a time.Duration that is read and written as a string such as "15m".
+type Duration time.Duration
+
+// UnmarshalYAML parses a duration string.
+func (d *Duration) UnmarshalYAML(value *yaml.Node) (err error) {
+	var parsed time.Duration
+	if parsed, err = time.ParseDuration(value.Value); err != nil {
+		return errors.Wrapf(err, "invalid duration at line %d", value.Line)
+	}
+	*d = Duration(parsed)
+	return
+}
+
+// MarshalYAML writes the duration as a string.
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return time.Duration(d).String(), nil
+}
+
+// Default returns the configuration with all the defaults set.
+func Default() *Config {
+	options := client.DefaultConnectOptions()
+	mapping := journal.DefaultMapping()
+	return &Config{
+		ServeAddr: ":8085",
+		Failover: Failover{
+			MaxFailures:   3,
+			ProbeInterval: Duration(time.Minute),
+		},
+		Pool: Pool{
+			Connections: 1,
+		},
+		Client: Client{
+			WriteWait:        Duration(options.WriteWait),
+			PongWait:         Duration(options.PongWait),
+			PingPeriod:       Duration(options.PingPeriod),
+			MaxMessageSize:   options.MaxMessageSize,
+			HandshakeTimeout: Duration(options.HandshakeTimeout),
+
+			IncomingBufferSize: options.IncomingBufferSize,
+			OutgoingBufferSize: options.OutgoingBufferSize,
+			OverflowPolicy:     options.OverflowPolicy.String(),
+			ClockSkewThreshold: Duration(time.Second),
+		},
+		Order: Order{
+			TradeLookback:      Duration(15 * time.Minute),
+			CheckpointInterval: Duration(5 * time.Second),
+			ReconcileGrace:     Duration(30 * time.Second),
+			ReconcileRetention: Duration(24 * time.Hour),
+			MaxFailures:        10,
+			FailureWindow:      Duration(time.Minute),
+		},
+		Journal: Journal{
+			Asset:   mapping.Asset,
+			Trading: mapping.Trading,
+			Fees:    mapping.Fees,
+		},
+		Shutdown: Shutdown{
+			DrainTimeout:  Duration(30 * time.Second),
+			CancelTimeout: Duration(5 * time.Second),
+			CloseTimeout:  Duration(5 * time.Second),
+			HTTPTimeout:   Duration(5 * time.Second),
+		},
+		Endpoint: Endpoint{
+			SignatureWindow: Duration(30 * time.Second),
+		},
+	}
+}
+
+// Load reads the configuration from the given YAML file on top of the defaults. An empty path
+// returns the defaults.
+func Load(path string) (result *Config, err error) {
+	result = Default()
+	if path == "" {
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		err = errors.Wrapf(err, "unable to read config %s", path)
+		return
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err = decoder.Decode(result); err != nil {
+		err = errors.Wrapf(err, "unable to parse config %s", path)
+		return
+	}
+	return
+}
+
+// ApplyEnv overrides the configuration with environment variables:
+//   - PINTU_ADDR, a comma separated list of the primary and failover addresses, PINTU_SERVE_ADDR
+//   - PINTU_APIKEY, PINTU_APISECRET, PINTU_APISECRET_FILE for the default account
+//   - PINTU_<ACCOUNT>_APIKEY, PINTU_<ACCOUNT>_APISECRET, PINTU_<ACCOUNT>_APISECRET_FILE for
+//     the configured accounts, where <ACCOUNT> is the upper case account name
+//   - PINTU_PING_PERIOD, PINTU_PONG_WAIT, PINTU_WRITE_WAIT, PINTU_HANDSHAKE_TIMEOUT,
+//     PINTU_MAX_MESSAGE_SIZE and PINTU_TRADE_LOOKBACK
+func (c *Config) ApplyEnv(lookup func(string) (string, bool)) (err error) {
+	if value, ok := lookup(envPrefix + "ADDR"); ok {
+		c.SetAddrs(value)
+	}
+	if value, ok := lookup(envPrefix + "SERVE_ADDR"); ok {
+		c.ServeAddr = value
+	}
+
+	// the default account can be created from the environment alone
+	if _, ok := lookup(envPrefix + "APIKEY"); ok && c.Account(DefaultAccount) == nil {
+		c.Accounts = append(c.Accounts, Account{Name: DefaultAccount})
+	}
+	for i := range c.Accounts {
+		account := &c.Accounts[i]
+		prefix := envPrefix + envName(account.Name) + "_"
+		if account.Name == DefaultAccount {
+			prefix = envPrefix
+		}
+		if value, ok := lookup(prefix + "APIKEY"); ok {
+			account.APIKey = value
+		}
+		if value, ok := lookup(prefix + "APISECRET"); ok {
+			account.APISecret = value
+		}
+		if value, ok := lookup(prefix + "APISECRET_FILE"); ok {
+			account.APISecretFile = value
+		}
+	}
+
+	durations := map[string]*Duration{
+		"PING_PERIOD":       &c.Client.PingPeriod,
+		"PONG_WAIT":         &c.Client.PongWait,
+		"WRITE_WAIT":        &c.Client.WriteWait,
+		"HANDSHAKE_TIMEOUT": &c.Client.HandshakeTimeout,
+		"TRADE_LOOKBACK":    &c.Order.TradeLookback,
+	}
+	for name, target := range durations {
+		if value, ok := lookup(envPrefix + name); ok {
+			var parsed time.Duration
+			if parsed, err = time.ParseDuration(value); err != nil {
+				return errors.Wrapf(err, "invalid %s%s", envPrefix, name)
+			}
+			*target = Duration(parsed)
+		}
+	}
+	if value, ok := lookup(envPrefix + "MAX_MESSAGE_SIZE"); ok {
+		if c.Client.MaxMessageSize, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return errors.Wrapf(err, "invalid %sMAX_MESSAGE_SIZE", envPrefix)
+		}
+	}
+	return
+}
+
+// DefaultAccount is the name of the account configured without a name.
+const DefaultAccount = "default"
+
+var envNameReplacer = regexp.MustCompile(`[^A-Z0-9]+`)
+
+// envName converts an account name to its environment variable form.
+func envName(name string) string {
+	return envNameReplacer.ReplaceAllString(strings.ToUpper(name), "_")
+}
+
+// Account returns the account with the given name, or nil if it's not configured.
+func (c *Config) Account(name string) *Account {
+	for i := range c.Accounts {
+		if c.Accounts[i].Name == name {
+			return &c.Accounts[i]
+		}
+	}
+	return nil
+}
+
+// ResolveSecrets reads the secrets of accounts configured with a secret file.
+func (c *Config) ResolveSecrets() (err error) {
+	for i := range c.Accounts {
+		account := &c.Accounts[i]
+		if account.APISecretFile == "" {
+			continue
+		}
+		var data []byte
+		if data, err = os.ReadFile(account.APISecretFile); err != nil {
+			return errors.Wrapf(err, "unable to read secret for account %s", account.Name)
+		}
+		account.APISecret = strings.TrimSpace(string(data))
+	}
+	for i := range c.Endpoint.APIKeys {
+		key := &c.Endpoint.APIKeys[i]
+		if key.SecretFile == "" {
+			continue
+		}
+		var data []byte
+		if data, err = os.ReadFile(key.SecretFile); err != nil {
+			return errors.Wrapf(err, "unable to read secret for api key %s", key.Key)
+		}
+		key.Secret = strings.TrimSpace(string(data))
+	}
+	return
+}
+
+// Validate checks that the configuration is complete and consistent.
+func (c *Config) Validate() (err error) {
+	for _, addr := range c.Addrs() {
+		if addr == "" {
+			return errors.New("addr is required, and failoverAddrs must not be empty")
+		}
+	}
+	if c.Failover.MaxFailures <= 0 || c.Failover.ProbeInterval <= 0 {
+		return errors.New("failover maxFailures and probeInterval must be positive")
+	}
+	if c.Pool.Connections <= 0 {
+		return errors.New("pool connections must be positive")
+	}
+	if c.ServeAddr == "" {
+		return errors.New("serveAddr is required")
+	}
+	if len(c.Accounts) == 0 {
+		return errors.New("at least one account is required")
+	}
+	names := make(map[string]bool)
+	for _, account := range c.Accounts {
+		if account.Name == "" {
+			return errors.New("account name is required")
+		}
+		if names[account.Name] {
+			return fmt.Errorf("duplicate account %s", account.Name)
+		}
+		names[account.Name] = true
+		if account.APIKey == "" {
+			return fmt.Errorf("apiKey is required for account %s", account.Name)
+		}
+		if account.APISecret == "" {
+			return fmt.Errorf("apiSecret or apiSecretFile is required for account %s", account.Name)
+		}
+	}
+	if c.Client.ClockSkewThreshold < 0 {
+		return errors.New("client clockSkewThreshold must not be negative")
+	}
+	options, err := c.ConnectOptions()
+	if err == nil {
+		err = options.Validate()
+	}
+	if err != nil {
+		return errors.Wrap(err, "invalid client config")
+	}
+	if c.Order.TradeLookback <= 0 {
+		return errors.New("order tradeLookback must be positive")
+	}
+	if c.Order.CheckpointInterval <= 0 {
+		return errors.New("order checkpointInterval must be positive")
+	}
+	if c.Order.ReconcileGrace <= 0 || c.Order.ReconcileRetention <= c.Order.ReconcileGrace {
+		return errors.New("order reconcileGrace must be positive and less than reconcileRetention")
+	}
+	if c.Order.MaxFailures <= 0 || c.Order.FailureWindow <= 0 {
+		return errors.New("order maxFailures and failureWindow must be positive")
+	}
+	if c.Balances.RejectInsufficient && !c.Balances.Subscribe {
+		return errors.New("balances rejectInsufficient requires subscribe")
+	}
+	if err = c.JournalMapping().Validate(); err != nil {
+		return errors.Wrap(err, "invalid journal config")
+	}
+	if _, err = c.apiKeys(); err != nil {
+		return errors.Wrap(err, "invalid endpoint config")
+	}
+	if c.Shutdown.DrainTimeout < 0 || c.Shutdown.CancelTimeout < 0 ||
+		c.Shutdown.CloseTimeout < 0 || c.Shutdown.HTTPTimeout < 0 {
+		return errors.New("shutdown timeouts must not be negative")
+	}
+	return
+}
+
+// ConnectOptions returns the client connection options, loading the TLS files if any.
+func (c *Config) ConnectOptions() (options client.ConnectOptions, err error) {
+	options = client.ConnectOptions{
+		WriteWait:          time.Duration(c.Client.WriteWait),
+		PongWait:           time.Duration(c.Client.PongWait),
+		PingPeriod:         time.Duration(c.Client.PingPeriod),
+		MaxMessageSize:     c.Client.MaxMessageSize,
+		HandshakeTimeout:   time.Duration(c.Client.HandshakeTimeout),
+		RateLimits:         make(map[string]client.RateLimit),
+		ReadBufferSize:     c.Client.ReadBufferSize,
+		WriteBufferSize:    c.Client.WriteBufferSize,
+		IncomingBufferSize: c.Client.IncomingBufferSize,
+		OutgoingBufferSize: c.Client.OutgoingBufferSize,
+		Proxy:              c.Client.Proxy,
+		EnableCompression:  c.Client.EnableCompression,
+		SpillDir:           c.Client.SpillDir,
+		SpillMaxBytes:      c.Client.SpillMaxBytes,
+	}
+	if options.OverflowPolicy, err = client.ParseOverflowPolicy(c.Client.OverflowPolicy); err != nil {
+		return
+	}
+	for msgType, limit := range c.Client.RateLimits {
+		options.RateLimits[msgType] = client.RateLimit{Rate: limit.Rate, Burst: limit.Burst}
+	}
+	if len(c.Client.Headers) > 0 {
+		options.Header = make(http.Header)
+		for name, value := range c.Client.Headers {
+			options.Header.Set(name, value)
+		}
+	}
+	options.TLSConfig, err = c.Client.TLS.config()
+	return
+}
+
+// config returns the TLS config, or nil to use the default one.
+func (t TLS) config() (result *tls.Config, err error) {
+	if t == (TLS{}) {
+		return
+	}
+	result = &tls.Config{ServerName: t.ServerName, MinVersion: tls.VersionTLS12}
+	if t.CAFile != "" {
+		var pem []byte
+		if pem, err = os.ReadFile(t.CAFile); err != nil {
+			err = errors.Wrap(err, "unable to read tls caFile")
+			return
+		}
+		result.RootCAs = x509.NewCertPool()
+		if !result.RootCAs.AppendCertsFromPEM(pem) {
+			err = fmt.Errorf("no certificates in tls caFile %s", t.CAFile)
+			return
+		}
+	}
+	if t.CertFile != "" || t.KeyFile != "" {
+		var cert tls.Certificate
+		if cert, err = tls.LoadX509KeyPair(t.CertFile, t.KeyFile); err != nil {
+			err = errors.Wrap(err, "unable to load tls certFile and keyFile")
+			return
+		}
+		result.Certificates = []tls.Certificate{cert}
+	}
+	return
+}
+
+// Addrs returns the primary address followed by the failover addresses.
+func (c *Config) Addrs() []string {
+	return append([]string{c.Addr}, c.FailoverAddrs...)
+}
+
+// SetAddrs sets the addresses from a comma separated list, the first being the primary.
+func (c *Config) SetAddrs(value string) {
+	addrs := strings.Split(value, ",")
+	for i := range addrs {
+		addrs[i] = strings.TrimSpace(addrs[i])
+	}
+	c.Addr, c.FailoverAddrs = addrs[0], addrs[1:]
+}
+
+// NewFailover returns a new failover over the addresses for the named connection.
+func (c *Config) NewFailover(name string) *client.Failover {
+	return client.NewFailover(name, c.Addrs(), c.Failover.MaxFailures)
+}
+
+// Clock returns a new clock estimating the skew of the local clock for the given account.
+func (c *Config) Clock(account Account) *client.Clock {
+	return client.NewClock(account.Name, time.Duration(c.Client.ClockSkewThreshold), c.Client.CompensateClockSkew)
+}
+
+// OrderConfig returns the order handler config for the given account.
+func (c *Config) OrderConfig(account Account) order.Config {
+	return order.Config{
+		Account:            account.Name,
+		SubAccount:         account.SubAccount,
+		Group:              account.Group,
+		TradeLookback:      time.Duration(c.Order.TradeLookback),
+		CheckpointInterval: time.Duration(c.Order.CheckpointInterval),
+		MaxFailures:        c.Order.MaxFailures,
+		FailureWindow:      time.Duration(c.Order.FailureWindow),
+	}
+}
+
+// CheckpointPath returns the checkpoint file of the given account, or an empty path if the
+// checkpoints aren't persisted.
+func (c *Config) CheckpointPath(account Account) string {
+	if c.Order.CheckpointDir == "" {
+		return ""
+	}
+	return filepath.Join(c.Order.CheckpointDir, account.Name+".checkpoint.json")
+}
+
+// DeadLetterPath returns the dead letter file of the given account, or an empty path if the
+// dead letters aren't persisted.
+func (c *Config) DeadLetterPath(account Account) string {
+	if c.Order.DeadLetterDir == "" {
+		return ""
+	}
+	return filepath.Join(c.Order.DeadLetterDir, account.Name+".deadletters.jsonl")
+}
+
+// AuditLog opens the audit log of the given name, usually an account, or returns nil if the
+// connections aren't audited. Each log has a single writer, so processes use their own names.
+func (c *Config) AuditLog(name string) (*client.AuditLog, error) {
+	if c.Client.AuditDir == "" {
+		return nil, nil
+	}
+	return client.OpenAuditLog(filepath.Join(c.Client.AuditDir, name+".audit.jsonl"))
+}
+
+// RefDataCache returns the reference data cache, loaded from the file if any, or nil if the
+// orders aren't validated.
+func (c *Config) RefDataCache() (result *refdata.Cache, err error) {
+	switch {
+	case c.ReferenceData.File != "":
+		return refdata.LoadFile(c.ReferenceData.File)
+	case c.ReferenceData.Subscribe:
+		return refdata.NewCache(), nil
+	}
+	return
+}
+
+// JournalMapping returns the account names of the journal of trades.
+func (c *Config) JournalMapping() journal.Mapping {
+	mapping := journal.Mapping{Asset: c.Journal.Asset, Trading: c.Journal.Trading, Fees: c.Journal.Fees}
+	for _, account := range c.Journal.Accounts {
+		mapping.Overrides = append(mapping.Overrides, journal.Override(account))
+	}
+	return mapping
+}
+
+// AccountBalances returns the balances of the given account, or nil if they aren't kept.
+func (c *Config) AccountBalances(account Account) *order.Balances {
+	if !c.Balances.Subscribe {
+		return nil
+	}
+	return order.NewBalances(account.Name, c.Balances.RejectInsufficient)
+}
+
+// AccountQuotes returns the quotes of an account, or nil if quotes aren't enabled.
+func (c *Config) AccountQuotes() *order.Quotes {
+	if !c.Quotes.Enabled {
+		return nil
+	}
+	return order.NewQuotes()
+}
+
+// Reconciler returns a new reconciler for the given account.
+func (c *Config) Reconciler(account Account) *order.Reconciler {
+	return order.NewReconciler(account.Name, time.Duration(c.Order.ReconcileGrace),
+		time.Duration(c.Order.ReconcileRetention))
+}
+
+// DrainOptions returns the options to drain the order handlers on shutdown.
+func (c *Config) DrainOptions() order.DrainOptions {
+	return order.DrainOptions{
+		Timeout:         time.Duration(c.Shutdown.DrainTimeout),
+		CancelRemaining: c.Shutdown.CancelRemaining,
+		CancelTimeout:   time.Duration(c.Shutdown.CancelTimeout),
+	}
+}
+
+// apiKeys returns the endpoint API keys.
+func (c *Config) apiKeys() (keys []endpoint.APIKey, err error) {
+	for _, key := range c.Endpoint.APIKeys {
+		if key.Secret == "" {
+			err = fmt.Errorf("secret or secretFile is required for api key %s", key.Key)
+			return
+		}
+		var permission endpoint.Permission
+		if permission, err = endpoint.ParsePermission(key.Permission); err != nil {
+			err = errors.Wrapf(err, "api key %s", key.Key)
+			return
+		}
+		keys = append(keys, endpoint.APIKey{
+			Key:        key.Key,
+			Secret:     key.Secret,
+			Permission: permission,
+			Symbols:    key.Symbols,
+			Accounts:   key.Accounts,
+		})
+	}
+	return
+}
+
+// Authenticator returns the authenticator of the order endpoint, or nil if no API keys are
+// configured. It opens the audit file, if configured.
+func (c *Config) Authenticator() (result *endpoint.Authenticator, err error) {
+	if len(c.Endpoint.APIKeys) == 0 {
+		return
+	}
+	keys, err := c.apiKeys()
+	if err != nil {
+		return
+	}
+	var audit *endpoint.OrderAudit
+	if c.Endpoint.AuditFile != "" {
+		if audit, err = endpoint.OpenOrderAudit(c.Endpoint.AuditFile); err != nil {
+			return
+		}
+	}
+	return endpoint.NewAuthenticator(keys, time.Duration(c.Endpoint.SignatureWindow), audit)
+}
+
+// Redacted returns the configuration as YAML with all secrets redacted.
+func (c *Config) Redacted() ([]byte, error) {
+	redactedConfig := *c
+	redactedConfig.Accounts = make([]Account, len(c.Accounts))
+	for i, account := range c.Accounts {
+		if account.APISecret != "" {
+			account.APISecret = redacted
+		}
+		redactedConfig.Accounts[i] = account
+	}
+	if proxyURL, err := url.Parse(c.Client.Proxy); err == nil && proxyURL.User != nil {
+		redactedConfig.Client.Proxy = proxyURL.Redacted()
+	}
+	// header values are often tokens, so only their names are printed
+	if c.Client.Headers != nil {
+		redactedConfig.Client.Headers = make(map[string]string, len(c.Client.Headers))
+		for name := range c.Client.Headers {
+			redactedConfig.Client.Headers[name] = redacted
+		}
+	}
+	redactedConfig.Endpoint.APIKeys = make([]APIKey, len(c.Endpoint.APIKeys))
+	for i, key := range c.Endpoint.APIKeys {
+		if key.Secret != "" {
+			key.Secret = redacted
+		}
+		redactedConfig.Endpoint.APIKeys[i] = key
+	}
+	return yaml.Marshal(&redactedConfig)
+}
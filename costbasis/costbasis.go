@@ -0,0 +1,349 @@
+package costbasis
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+
+	"github.com/pintu-crypto/b2b-order/client"
+)
+
+// Method is how the lots a sale is taken from are chosen.
+type Method string
+
+// The supported cost basis methods.
+const (
+	// FIFO takes the oldest lots first.
+	FIFO Method = "fifo"
+	// LIFO takes the newest lots first.
+	LIFO Method = "lifo"
+	// Average keeps a single lot per currency at the weighted average cost.
+	Average Method = "average"
+)
+
+// ParseMethod parses the name of a cost basis method.
+func ParseMethod(value string) (method Method, err error) {
+	switch method = Method(strings.ToLower(value)); method {
+	case FIFO, LIFO, Average:
+	default:
+		err = errors.Errorf("unknown cost basis method '%s', expected fifo, lifo or average", value)
+	}
+	return
+}
+
+// Lot is a quantity of a currency acquired at once, and what it cost in the cost currency,
+// fees included.
+type Lot struct {
+	Currency     string
+	CostCurrency string
+	Acquired     client.MicrosTimestamp
+	TradeID      string
+	Quantity     decimal.Decimal
+	Cost         decimal.Decimal
+}
+
+// Disposal is a sale of a currency and the gain realized on it.
+type Disposal struct {
+	Currency     string
+	CostCurrency string
+	Disposed     client.MicrosTimestamp
+	TradeID      string
+	Quantity     decimal.Decimal
+	Proceeds     decimal.Decimal
+	Cost         decimal.Decimal
+	Gain         decimal.Decimal
+	// Lots are the parts of the lots the quantity was taken from, with their cost. With the
+	// average method, it's the single average lot.
+	Lots []Lot
+}
+
+// Fee is a fee paid in a currency that is neither side of its trade, which isn't part of the
+// cost basis of either.
+type Fee struct {
+	Currency  string
+	Amount    decimal.Decimal
+	Timestamp client.MicrosTimestamp
+	TradeID   string
+}
+
+// book is the currency and the cost currency of a set of lots.
+type book struct {
+	currency     string
+	costCurrency string
+}
+
+// Ledger books trades into tax lots, and reports the gains realized by the sales. Lots are kept
+// per currency and the quote currency of the trades, in which their cost is, so that a
+// currency bought for USDT and for IDR has lots in both. The quote currency spent on a buy
+// isn't itself disposed of, as that would need its price in another currency. It is safe for
+// concurrent use.
+type Ledger struct {
+	mu        sync.Mutex
+	method    Method
+	lots      map[book][]*Lot
+	booked    map[string]bool
+	disposals []Disposal
+	fees      []Fee
+}
+
+// NewLedger returns an empty ledger using the given method.
+func NewLedger(method Method) *Ledger {
+	return &Ledger{
+		method: method,
+		lots:   make(map[book][]*Lot),
+		booked: make(map[string]bool),
+	}
+}
+
+// AddAll books the trades in the order they happened. Only the last update of each trade is
+// kept, so that trades canceled since are left out.
+func (l *Ledger) AddAll(trades []*client.Trade) (err error) {
+	for _, trade := range client.LatestTrades(trades) {
+		if err = l.Add(trade); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Add books a trade: a buy adds a lot, and a sale takes its quantity from the lots. A trade is
+// booked once, by TradeID, and canceled trades aren't booked. A trade canceled after it was
+// booked, or a sale of more than the lots hold, is an error, and the trade isn't booked.
+func (l *Ledger) Add(trade *client.Trade) (err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if trade.TradeStatus == client.TradeStatus.Canceled {
+		if l.booked[trade.TradeID] {
+			err = errors.Errorf("trade %s was canceled after it was booked", trade.TradeID)
+		}
+		return
+	}
+	if l.booked[trade.TradeID] {
+		return
+	}
+	base, quote, quantity, amount, err := trade.Amounts()
+	if err != nil {
+		return
+	}
+	// a fee in the quote currency adds to the cost of a buy and takes from the proceeds of a
+	// sale, and a fee in the base currency reduces what's bought or adds to what's sold
+	var fee Fee
+	switch trade.FeeCurrency {
+	case "", quote:
+		if trade.Side == client.Side.Buy {
+			amount = amount.Add(trade.Fee)
+		} else {
+			amount = amount.Sub(trade.Fee)
+		}
+	case base:
+		if trade.Side == client.Side.Buy {
+			quantity = quantity.Sub(trade.Fee)
+		} else {
+			quantity = quantity.Add(trade.Fee)
+		}
+	default:
+		fee = Fee{Currency: trade.FeeCurrency, Amount: trade.Fee, Timestamp: trade.TransactTime, TradeID: trade.TradeID}
+	}
+
+	key := book{currency: base, costCurrency: quote}
+	switch trade.Side {
+	case client.Side.Buy:
+		l.acquire(key, &Lot{Currency: base, CostCurrency: quote, Acquired: trade.TransactTime,
+			TradeID: trade.TradeID, Quantity: quantity, Cost: amount})
+	case client.Side.Sell:
+		if err = l.dispose(key, trade, quantity, amount); err != nil {
+			return
+		}
+	default:
+		return errors.Errorf("trade %s has an unknown side %s", trade.TradeID, trade.Side)
+	}
+	if fee.Currency != "" && !fee.Amount.IsZero() {
+		l.fees = append(l.fees, fee)
+	}
+	l.booked[trade.TradeID] = true
+	return
+}
+
+// acquire adds a lot, merged into the average lot with the average method.
+func (l *Ledger) acquire(key book, lot *Lot) {
+	lots := l.lots[key]
+	if l.method == Average && len(lots) > 0 {
+		lots[0].Quantity = lots[0].Quantity.Add(lot.Quantity)
+		lots[0].Cost = lots[0].Cost.Add(lot.Cost)
+		return
+	}
+	l.lots[key] = append(lots, lot)
+}
+
+// dispose takes the sold quantity from the lots, oldest or newest first, and records the gain.
+func (l *Ledger) dispose(key book, trade *client.Trade, quantity decimal.Decimal, proceeds decimal.Decimal) error {
+	lots := l.lots[key]
+	held := decimal.Zero
+	for _, lot := range lots {
+		held = held.Add(lot.Quantity)
+	}
+	if held.LessThan(quantity) {
+		return errors.Errorf("trade %s sells %s %s with %s held in %s lots", trade.TradeID, quantity,
+			key.currency, held, key.costCurrency)
+	}
+	disposal := Disposal{
+		Currency:     key.currency,
+		CostCurrency: key.costCurrency,
+		Disposed:     trade.TransactTime,
+		TradeID:      trade.TradeID,
+		Quantity:     quantity,
+		Proceeds:     proceeds,
+		Cost:         decimal.Zero,
+	}
+	remaining := quantity
+	for remaining.IsPositive() {
+		i := 0
+		if l.method == LIFO {
+			i = len(lots) - 1
+		}
+		lot := lots[i]
+		taken := *lot
+		if lot.Quantity.LessThanOrEqual(remaining) {
+			// the whole lot, removed with its exact cost
+			if l.method == LIFO {
+				lots = lots[:i]
+			} else {
+				lots = lots[1:]
+			}
+		} else {
+			taken.Quantity = remaining
+			taken.Cost = lot.Cost.Mul(remaining).Div(lot.Quantity)
+			lot.Quantity = lot.Quantity.Sub(remaining)
+			lot.Cost = lot.Cost.Sub(taken.Cost)
+		}
+		remaining = remaining.Sub(taken.Quantity)
+		disposal.Cost = disposal.Cost.Add(taken.Cost)
+		disposal.Lots = append(disposal.Lots, taken)
+	}
+	disposal.Gain = disposal.Proceeds.Sub(disposal.Cost)
+	l.lots[key] = lots
+	l.disposals = append(l.disposals, disposal)
+	return nil
+}
+
+// Lots returns the open lots, by currency, cost currency and acquisition.
+func (l *Ledger) Lots() (result []Lot) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, lots := range l.lots {
+		for _, lot := range lots {
+			result = append(result, *lot)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Currency != result[j].Currency {
+			return result[i].Currency < result[j].Currency
+		}
+		if result[i].CostCurrency != result[j].CostCurrency {
+			return result[i].CostCurrency < result[j].CostCurrency
+		}
+		return time.Time(result[i].Acquired).Before(time.Time(result[j].Acquired))
+	})
+	return
+}
+
+// Period is the length of the periods of a report.
+type Period string
+
+// The supported report periods. Periods start at midnight in the time zone of the report.
+const (
+	Whole Period = ""
+	Day   Period = "day"
+	Month Period = "month"
+	Year  Period = "year"
+)
+
+// ParsePeriod parses the name of a report period, empty for the whole range of the report.
+func ParsePeriod(value string) (period Period, err error) {
+	switch period = Period(strings.ToLower(value)); period {
+	case Whole, Day, Month, Year:
+	default:
+		err = errors.Errorf("unknown period '%s', expected day, month or year", value)
+	}
+	return
+}
+
+// label returns the period of the time, such as 2026-01 for a month.
+func (p Period) label(t time.Time) string {
+	switch p {
+	case Day:
+		return t.Format("2006-01-02")
+	case Month:
+		return t.Format("2006-01")
+	case Year:
+		return t.Format("2006")
+	}
+	return ""
+}
+
+// Total is the sum of the disposals of a currency for a cost currency in a period.
+type Total struct {
+	Period       string
+	Currency     string
+	CostCurrency string
+	Disposals    int
+	Quantity     decimal.Decimal
+	Proceeds     decimal.Decimal
+	Cost         decimal.Decimal
+	Gain         decimal.Decimal
+}
+
+// Report is the gains realized from the start to the end date.
+type Report struct {
+	Method    Method
+	From      time.Time
+	To        time.Time
+	Totals    []Total
+	Disposals []Disposal
+	Fees      []Fee
+}
+
+// Report returns the gains realized from the start, included, to the end date, excluded, with
+// totals per period, starting in the time zone of the start date.
+func (l *Ledger) Report(from time.Time, to time.Time, period Period) (report Report) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	report = Report{Method: l.method, From: from, To: to}
+	totals := make(map[string]*Total)
+	var keys []string
+	for _, disposal := range l.disposals {
+		disposed := time.Time(disposal.Disposed).In(from.Location())
+		if disposed.Before(from) || !disposed.Before(to) {
+			continue
+		}
+		report.Disposals = append(report.Disposals, disposal)
+		label := period.label(disposed)
+		key := fmt.Sprintf("%s %s %s", label, disposal.Currency, disposal.CostCurrency)
+		total, ok := totals[key]
+		if !ok {
+			total = &Total{Period: label, Currency: disposal.Currency, CostCurrency: disposal.CostCurrency}
+			totals[key] = total
+			keys = append(keys, key)
+		}
+		total.Disposals++
+		total.Quantity = total.Quantity.Add(disposal.Quantity)
+		total.Proceeds = total.Proceeds.Add(disposal.Proceeds)
+		total.Cost = total.Cost.Add(disposal.Cost)
+		total.Gain = total.Gain.Add(disposal.Gain)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		report.Totals = append(report.Totals, *totals[key])
+	}
+	for _, fee := range l.fees {
+		if ts := time.Time(fee.Timestamp); !ts.Before(from) && ts.Before(to) {
+			report.Fees = append(report.Fees, fee)
+		}
+	}
+	return
+}
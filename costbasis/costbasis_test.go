@@ -0,0 +1,212 @@
+package costbasis
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/pintu-crypto/b2b-order/client"
+)
+
+// trade returns a DOGE-USDT trade on the given day of January 2026.
+func trade(id string, day int, side client.SideEnum, quantity string, price string) *client.Trade {
+	return &client.Trade{
+		TradeID:      id,
+		Symbol:       "DOGE-USDT",
+		Side:         side,
+		TransactTime: client.MicrosTimestamp(time.Date(2026, 1, day, 12, 0, 0, 0, time.UTC)),
+		Quantity:     decimal.RequireFromString(quantity),
+		Price:        decimal.RequireFromString(price),
+		Currency:     "DOGE",
+	}
+}
+
+// withFee sets the fee of the trade.
+func withFee(t *client.Trade, fee string, currency string) *client.Trade {
+	t.Fee = decimal.RequireFromString(fee)
+	t.FeeCurrency = currency
+	return t
+}
+
+func testTrades() []*client.Trade {
+	return []*client.Trade{
+		withFee(trade("T1", 1, client.Side.Buy, "100", "0.10"), "1", "USDT"),
+		trade("T2", 2, client.Side.Buy, "100", "0.20"),
+		withFee(trade("T3", 3, client.Side.Sell, "150", "0.30"), "0.5", "USDT"),
+	}
+}
+
+func TestLedgerMethods(t *testing.T) {
+	// the sale of 150 DOGE for 45 USDT less a 0.5 USDT fee, from lots of 100 DOGE costing
+	// 11 USDT with its fee, and 100 DOGE costing 20 USDT
+	tests := []struct {
+		method Method
+		cost   string
+		gain   string
+		lots   int
+		open   string
+	}{
+		{FIFO, "21", "23.5", 2, "10"},
+		{LIFO, "25.5", "19", 2, "5.5"},
+		{Average, "23.25", "21.25", 1, "7.75"},
+	}
+	for _, test := range tests {
+		ledger := NewLedger(test.method)
+		if err := ledger.AddAll(testTrades()); err != nil {
+			t.Fatal(err)
+		}
+		report := ledger.Report(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC), Whole)
+		if len(report.Disposals) != 1 {
+			t.Fatalf("%s: got %d disposals, expected 1", test.method, len(report.Disposals))
+		}
+		disposal := report.Disposals[0]
+		if !disposal.Proceeds.Equal(decimal.RequireFromString("44.5")) ||
+			!disposal.Cost.Equal(decimal.RequireFromString(test.cost)) ||
+			!disposal.Gain.Equal(decimal.RequireFromString(test.gain)) || len(disposal.Lots) != test.lots {
+			t.Errorf("%s: got proceeds %s cost %s gain %s from %d lots, expected 44.5, %s, %s from %d",
+				test.method, disposal.Proceeds, disposal.Cost, disposal.Gain, len(disposal.Lots), test.cost, test.gain, test.lots)
+		}
+		lots := ledger.Lots()
+		if len(lots) != 1 || !lots[0].Quantity.Equal(decimal.NewFromInt(50)) ||
+			!lots[0].Cost.Equal(decimal.RequireFromString(test.open)) {
+			t.Errorf("%s: got open lots %+v, expected 50 DOGE costing %s", test.method, lots, test.open)
+		}
+	}
+}
+
+func TestLedgerFees(t *testing.T) {
+	ledger := NewLedger(FIFO)
+	trades := []*client.Trade{
+		// 1 DOGE of the 100 bought is kept as the fee
+		withFee(trade("T1", 1, client.Side.Buy, "100", "0.10"), "1", "DOGE"),
+		// the sale takes its 1 DOGE fee from the lots too
+		withFee(trade("T2", 2, client.Side.Sell, "50", "0.20"), "1", "DOGE"),
+		// a fee in another currency is reported apart
+		withFee(trade("T3", 3, client.Side.Sell, "10", "0.20"), "0.01", "BNB"),
+	}
+	if err := ledger.AddAll(trades); err != nil {
+		t.Fatal(err)
+	}
+	lots := ledger.Lots()
+	if len(lots) != 1 || !lots[0].Quantity.Equal(decimal.NewFromInt(38)) {
+		t.Errorf("got lots %+v, expected 38 DOGE", lots)
+	}
+	report := ledger.Report(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC), Whole)
+	if len(report.Disposals) != 2 || !report.Disposals[0].Quantity.Equal(decimal.NewFromInt(51)) {
+		t.Errorf("got disposals %+v, expected 51 DOGE first", report.Disposals)
+	}
+	if len(report.Fees) != 1 || report.Fees[0].Currency != "BNB" {
+		t.Errorf("got fees %+v, expected the BNB fee", report.Fees)
+	}
+}
+
+func TestLedgerQuoteQuantity(t *testing.T) {
+	ledger := NewLedger(FIFO)
+	// 20 USDT worth of DOGE at 0.10, with the amount not set
+	buy := trade("T1", 1, client.Side.Buy, "20", "0.10")
+	buy.Currency = "USDT"
+	if err := ledger.Add(buy); err != nil {
+		t.Fatal(err)
+	}
+	lots := ledger.Lots()
+	if len(lots) != 1 || !lots[0].Quantity.Equal(decimal.NewFromInt(200)) || !lots[0].Cost.Equal(decimal.NewFromInt(20)) {
+		t.Errorf("got lots %+v, expected 200 DOGE costing 20 USDT", lots)
+	}
+
+	// without an amount or a price the base quantity can't be worked out
+	unpriced := trade("T2", 2, client.Side.Buy, "20", "0")
+	unpriced.Currency = "USDT"
+	if err := ledger.Add(unpriced); err == nil {
+		t.Error("added a trade without a base quantity")
+	}
+	if lots := ledger.Lots(); len(lots) != 1 {
+		t.Errorf("got lots %+v, expected only the first buy", lots)
+	}
+}
+
+func TestLedgerUpdates(t *testing.T) {
+	ledger := NewLedger(FIFO)
+	buy := trade("T1", 1, client.Side.Buy, "100", "0.10")
+	if err := ledger.Add(buy); err != nil {
+		t.Fatal(err)
+	}
+	// the confirmation of a booked trade changes nothing
+	confirmed := *buy
+	confirmed.TradeStatus = client.TradeStatus.Confirmed
+	if err := ledger.Add(&confirmed); err != nil {
+		t.Fatal(err)
+	}
+	if lots := ledger.Lots(); len(lots) != 1 {
+		t.Errorf("got %d lots, expected 1", len(lots))
+	}
+	// a sale of more than is held isn't booked
+	if err := ledger.Add(trade("T2", 2, client.Side.Sell, "150", "0.10")); err == nil ||
+		!strings.Contains(err.Error(), "sells 150 DOGE with 100 held") {
+		t.Errorf("got error %v, expected the sale to be refused", err)
+	}
+	if lots := ledger.Lots(); len(lots) != 1 || !lots[0].Quantity.Equal(decimal.NewFromInt(100)) {
+		t.Errorf("got lots %+v after the refused sale", lots)
+	}
+	// a canceled trade that was booked can't be taken back
+	canceled := *buy
+	canceled.TradeStatus = client.TradeStatus.Canceled
+	if err := ledger.Add(&canceled); err == nil {
+		t.Error("canceled a booked trade")
+	}
+
+	// in a batch, a trade canceled by a later update isn't booked
+	ledger = NewLedger(FIFO)
+	if err := ledger.AddAll([]*client.Trade{buy, trade("T2", 2, client.Side.Buy, "10", "0.1"), &canceled}); err != nil {
+		t.Fatal(err)
+	}
+	if lots := ledger.Lots(); len(lots) != 1 || lots[0].TradeID != "T2" {
+		t.Errorf("got lots %+v, expected only T2", lots)
+	}
+}
+
+func TestLedgerReportPeriods(t *testing.T) {
+	ledger := NewLedger(FIFO)
+	trades := []*client.Trade{
+		trade("T1", 1, client.Side.Buy, "300", "0.10"),
+		trade("T2", 2, client.Side.Sell, "100", "0.20"),
+		trade("T3", 2, client.Side.Sell, "100", "0.30"),
+		trade("T4", 31, client.Side.Sell, "100", "0.05"),
+	}
+	// the last sale is in February in Jakarta
+	trades[3].TransactTime = client.MicrosTimestamp(time.Date(2026, 1, 31, 18, 0, 0, 0, time.UTC))
+	if err := ledger.AddAll(trades); err != nil {
+		t.Fatal(err)
+	}
+	jakarta := time.FixedZone("WIB", 7*60*60)
+	report := ledger.Report(time.Date(2026, 1, 1, 0, 0, 0, 0, jakarta), time.Date(2027, 1, 1, 0, 0, 0, 0, jakarta), Month)
+	if len(report.Totals) != 2 {
+		t.Fatalf("got totals %+v, expected January and February", report.Totals)
+	}
+	january, february := report.Totals[0], report.Totals[1]
+	if january.Period != "2026-01" || january.Disposals != 2 || !january.Gain.Equal(decimal.NewFromInt(30)) {
+		t.Errorf("got %+v, expected 2 disposals gaining 30 USDT in 2026-01", january)
+	}
+	if february.Period != "2026-02" || !february.Gain.Equal(decimal.NewFromInt(-5)) {
+		t.Errorf("got %+v, expected a loss of 5 USDT in 2026-02", february)
+	}
+
+	// disposals outside the range aren't reported
+	report = ledger.Report(time.Date(2026, 1, 3, 0, 0, 0, 0, time.UTC), time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC), Whole)
+	if len(report.Disposals) != 1 || report.Disposals[0].TradeID != "T4" {
+		t.Errorf("got disposals %+v, expected T4", report.Disposals)
+	}
+}
+
+func TestParse(t *testing.T) {
+	if method, err := ParseMethod("FIFO"); err != nil || method != FIFO {
+		t.Errorf("got %s, %v", method, err)
+	}
+	if _, err := ParseMethod("hifo"); err == nil {
+		t.Error("parsed an unknown method")
+	}
+	if _, err := ParsePeriod("week"); err == nil {
+		t.Error("parsed an unknown period")
+	}
+}
@@ -0,0 +1,77 @@
+package endpoint
+
+import (
+	"encoding/json"
+	"expvar"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/pintu-crypto/b2b-order/client"
+)
+
+// auditErrors counts the orders that were placed without an audit record.
+var auditErrors = expvar.NewInt("order_audit_errors")
+
+// OrderAuditRecord records which API key placed which order.
+type OrderAuditRecord struct {
+	Timestamp  client.MicrosTimestamp
+	APIKey     string
+	RemoteAddr string
+	Account    string
+	ClOrdID    string
+	Symbol     string
+	Side       client.SideEnum
+	OrderQty   string
+}
+
+// OrderAudit appends a record of every order placed through the endpoint to a JSON lines file.
+type OrderAudit struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// OpenOrderAudit opens the audit file for appending, creating it if needed.
+func OpenOrderAudit(path string) (result *OrderAudit, err error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		err = errors.Wrapf(err, "unable to open order audit %s", path)
+		return
+	}
+	result = &OrderAudit{file: file}
+	return
+}
+
+// Record appends a record for the given order.
+func (a *OrderAudit) Record(key string, remoteAddr string, account string, order *client.NewOrderSingle) (err error) {
+	record := OrderAuditRecord{
+		Timestamp:  client.MicrosTimestamp(time.Now()),
+		APIKey:     key,
+		RemoteAddr: remoteAddr,
+		Account:    account,
+		ClOrdID:    order.ClOrdID,
+		Symbol:     order.Symbol,
+		Side:       order.Side,
+		OrderQty:   order.OrderQty.String(),
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		err = errors.Wrap(err, "unable to encode order audit record")
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, err = a.file.Write(append(data, '\n')); err != nil {
+		err = errors.Wrap(err, "unable to write order audit record")
+	}
+	return
+}
+
+// Close closes the audit file.
+func (a *OrderAudit) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.file.Close()
+}
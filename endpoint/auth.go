@@ -0,0 +1,240 @@
+package endpoint
+
+import (
+	"context"
+	"crypto/hmac"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/pintu-crypto/b2b-order/client"
+)
+
+// The headers of a signed request, the same as used to connect to the Pintu API.
+const (
+	apiKeyHeader       = "ApiKey"
+	apiSignHeader      = "ApiSign"
+	apiTimestampHeader = "ApiTimestamp"
+)
+
+// defaultSignatureWindow is the maximum age of a request signature if no window is set.
+const defaultSignatureWindow = 30 * time.Second
+
+// Permission is what an API key is allowed to do.
+type Permission uint8
+
+const (
+	// PermissionRead allows reading, but not placing orders.
+	PermissionRead Permission = iota + 1
+	// PermissionTrade allows reading and placing orders.
+	PermissionTrade
+)
+
+// ParsePermission parses a permission name, either read or trade.
+func ParsePermission(str string) (p Permission, err error) {
+	switch strings.ToLower(str) {
+	case "read":
+		p = PermissionRead
+	case "trade":
+		p = PermissionTrade
+	default:
+		err = fmt.Errorf("invalid permission %s", str)
+	}
+	return
+}
+
+func (p Permission) String() string {
+	switch p {
+	case PermissionRead:
+		return "read"
+	case PermissionTrade:
+		return "trade"
+	default:
+		return "none"
+	}
+}
+
+// APIKey is a key of an internal caller of the endpoint.
+type APIKey struct {
+	Key        string
+	Secret     string
+	Permission Permission
+	// Symbols the key may trade. If empty, all symbols are allowed.
+	Symbols []string
+	// Accounts the key may use. If empty, all accounts are allowed.
+	Accounts []string
+}
+
+// allowsSymbol returns true if the key may trade the given symbol.
+func (k *APIKey) allowsSymbol(symbol string) bool {
+	return allows(k.Symbols, symbol)
+}
+
+// allowsAccount returns true if the key may use the given account.
+func (k *APIKey) allowsAccount(account string) bool {
+	return allows(k.Accounts, account)
+}
+
+func allows(allowed []string, value string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, a := range allowed {
+		if strings.EqualFold(a, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticator verifies that requests are signed by a known API key. Requests are signed the
+// same way as the Pintu API connection: the ApiSign header is the HMAC-SHA256 of the method,
+// the ApiTimestamp header, the host and the request URI including the query, joined by new
+// lines, see client.Sign. A signature is only accepted once, so that a captured request can't
+// be replayed within the window.
+type Authenticator struct {
+	keys   map[string]*APIKey
+	window time.Duration
+	audit  *OrderAudit
+
+	mu        sync.Mutex
+	seen      map[string]time.Time
+	nextPrune time.Time
+}
+
+// NewAuthenticator returns an authenticator for the given keys. Signatures older than the
+// window are rejected. Placed orders are recorded to the audit, if not nil.
+func NewAuthenticator(keys []APIKey, window time.Duration, audit *OrderAudit) (result *Authenticator, err error) {
+	if window <= 0 {
+		window = defaultSignatureWindow
+	}
+	result = &Authenticator{
+		keys:   make(map[string]*APIKey),
+		window: window,
+		audit:  audit,
+		seen:   make(map[string]time.Time),
+	}
+	for i := range keys {
+		key := keys[i]
+		if key.Key == "" || key.Secret == "" {
+			err = errors.New("api key and secret are required")
+			return
+		}
+		if key.Permission != PermissionRead && key.Permission != PermissionTrade {
+			err = fmt.Errorf("invalid permission for api key %s", key.Key)
+			return
+		}
+		if _, ok := result.keys[key.Key]; ok {
+			err = fmt.Errorf("duplicate api key %s", key.Key)
+			return
+		}
+		result.keys[key.Key] = &key
+	}
+	return
+}
+
+// SignRequest adds the authentication headers to a request for the endpoint.
+func SignRequest(r *http.Request, key string, secret string, now time.Time) {
+	r.Header.Set(apiKeyHeader, key)
+	r.Header.Set(apiTimestampHeader, client.MicrosTimestamp(now).String())
+	host := r.Host
+	if host == "" {
+		host = r.URL.Host
+	}
+	r.Header.Set(apiSignHeader, client.Sign(secret, r.Method, now, host, r.URL.RequestURI()))
+}
+
+type apiKeyContextKey struct{}
+
+func withAPIKey(ctx context.Context, key *APIKey) context.Context {
+	return context.WithValue(ctx, apiKeyContextKey{}, key)
+}
+
+// apiKeyFromContext returns the key that signed the request, or nil without authentication.
+func apiKeyFromContext(ctx context.Context) *APIKey {
+	key, _ := ctx.Value(apiKeyContextKey{}).(*APIKey)
+	return key
+}
+
+// authenticate returns the key that signed the request.
+func (a *Authenticator) authenticate(r *http.Request, now time.Time) (key *APIKey, err error) {
+	keyID := r.Header.Get(apiKeyHeader)
+	signature := r.Header.Get(apiSignHeader)
+	timestamp := r.Header.Get(apiTimestampHeader)
+	if keyID == "" || signature == "" || timestamp == "" {
+		err = errors.New("missing authentication headers")
+		return
+	}
+	key, ok := a.keys[keyID]
+	if !ok {
+		err = errors.New("unknown api key")
+		return
+	}
+	ts, err := client.ParseMicrosTimestamp(timestamp)
+	if err != nil {
+		err = errors.Wrap(err, "invalid timestamp")
+		return
+	}
+	if age := now.Sub(time.Time(ts)); age > a.window || age < -a.window {
+		err = fmt.Errorf("timestamp outside the %s window", a.window)
+		return
+	}
+	expected := client.Sign(key.Secret, r.Method, time.Time(ts), r.Host, r.URL.RequestURI())
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		err = errors.New("invalid signature")
+		return
+	}
+	if !a.firstUse(keyID, signature, time.Time(ts), now) {
+		err = errors.New("signature already used")
+		return
+	}
+	return
+}
+
+// firstUse records a valid signature and returns false if it was already used. Signatures are
+// remembered until their timestamp leaves the window, after which they are rejected anyway.
+func (a *Authenticator) firstUse(keyID string, signature string, ts time.Time, now time.Time) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !now.Before(a.nextPrune) {
+		for used, expiry := range a.seen {
+			if now.After(expiry) {
+				delete(a.seen, used)
+			}
+		}
+		a.nextPrune = now.Add(a.window)
+	}
+	used := keyID + "\n" + signature
+	if _, ok := a.seen[used]; ok {
+		return false
+	}
+	a.seen[used] = ts.Add(a.window)
+	return true
+}
+
+// authorize wraps a handler so that it's only served to requests signed by a key with the
+// given permission. A nil authenticator allows every request.
+func (a *Authenticator) authorize(permission Permission, handler http.HandlerFunc) http.HandlerFunc {
+	if a == nil {
+		return handler
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, err := a.authenticate(r, time.Now())
+		if err != nil {
+			log.Printf("unauthorized request %s from %s: %s", r.URL.Path, r.RemoteAddr, err)
+			http.Error(w, "unauthorized: "+err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if key.Permission < permission {
+			log.Printf("forbidden request %s from api key %s", r.URL.Path, key.Key)
+			http.Error(w, fmt.Sprintf("forbidden: api key has %s permission", key.Permission), http.StatusForbidden)
+			return
+		}
+		handler(w, r.WithContext(withAPIKey(r.Context(), key)))
+	}
+}
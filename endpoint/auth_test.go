@@ -0,0 +1,182 @@
+package endpoint
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testAuthenticator(t *testing.T) *Authenticator {
+	auth, err := NewAuthenticator([]APIKey{
+		{Key: "trader", Secret: "trader-secret", Permission: PermissionTrade},
+		{Key: "reader", Secret: "reader-secret", Permission: PermissionRead},
+		{Key: "doge", Secret: "doge-secret", Permission: PermissionTrade,
+			Symbols: []string{"DOGE-USDT"}, Accounts: []string{"entity-a"}},
+	}, 30*time.Second, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return auth
+}
+
+func signedRequest(target string, key string, secret string, ts time.Time) *http.Request {
+	r := httptest.NewRequest("GET", target, nil)
+	SignRequest(r, key, secret, ts)
+	return r
+}
+
+func TestAuthenticate(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name    string
+		request func() *http.Request
+		valid   bool
+	}{
+		{"signed", func() *http.Request {
+			return signedRequest("/order?symbol=DOGE-USDT", "trader", "trader-secret", now)
+		}, true},
+		{"unsigned", func() *http.Request {
+			return httptest.NewRequest("GET", "/order?symbol=DOGE-USDT", nil)
+		}, false},
+		{"unknown key", func() *http.Request {
+			return signedRequest("/order?symbol=DOGE-USDT", "unknown", "trader-secret", now)
+		}, false},
+		{"wrong secret", func() *http.Request {
+			return signedRequest("/order?symbol=DOGE-USDT", "trader", "reader-secret", now)
+		}, false},
+		{"tampered query", func() *http.Request {
+			r := signedRequest("/order?symbol=DOGE-USDT", "trader", "trader-secret", now)
+			r.URL.RawQuery = "symbol=BTC-USDT"
+			r.RequestURI = r.URL.RequestURI()
+			return r
+		}, false},
+		{"tampered method", func() *http.Request {
+			r := signedRequest("/order?symbol=DOGE-USDT", "trader", "trader-secret", now)
+			r.Method = "POST"
+			return r
+		}, false},
+		{"expired", func() *http.Request {
+			return signedRequest("/order?symbol=DOGE-USDT", "trader", "trader-secret", now.Add(-time.Minute))
+		}, false},
+		{"future", func() *http.Request {
+			return signedRequest("/order?symbol=DOGE-USDT", "trader", "trader-secret", now.Add(time.Minute))
+		}, false},
+		{"within window", func() *http.Request {
+			return signedRequest("/order?symbol=DOGE-USDT", "trader", "trader-secret", now.Add(-20*time.Second))
+		}, true},
+	}
+	auth := testAuthenticator(t)
+	for _, test := range tests {
+		key, err := auth.authenticate(test.request(), now)
+		if (err == nil) != test.valid {
+			t.Errorf("%s: got error %v, expected valid %t", test.name, err, test.valid)
+			continue
+		}
+		if test.valid && key.Key != "trader" {
+			t.Errorf("%s: got key %s, expected trader", test.name, key.Key)
+		}
+	}
+}
+
+func TestAuthenticateReplay(t *testing.T) {
+	now := time.Now()
+	auth := testAuthenticator(t)
+	r := signedRequest("/order?symbol=DOGE-USDT", "trader", "trader-secret", now)
+	if _, err := auth.authenticate(r, now); err != nil {
+		t.Fatalf("first use: %s", err)
+	}
+	if _, err := auth.authenticate(r, now.Add(time.Second)); err == nil {
+		t.Errorf("replay within the window was accepted")
+	}
+	// a new signature for the same request is fine
+	r = signedRequest("/order?symbol=DOGE-USDT", "trader", "trader-secret", now.Add(time.Millisecond))
+	if _, err := auth.authenticate(r, now.Add(time.Second)); err != nil {
+		t.Errorf("new signature: %s", err)
+	}
+	// used signatures are forgotten once they expire
+	auth.authenticate(signedRequest("/ping", "trader", "trader-secret", now.Add(time.Minute)), now.Add(time.Minute))
+	if len(auth.seen) != 1 {
+		t.Errorf("got %d used signatures after expiry, expected 1", len(auth.seen))
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	auth := testAuthenticator(t)
+	served := func(w http.ResponseWriter, r *http.Request) {
+		if apiKeyFromContext(r.Context()) == nil {
+			t.Errorf("no api key in the context")
+		}
+	}
+	tests := []struct {
+		name       string
+		key        string
+		secret     string
+		permission Permission
+		status     int
+	}{
+		{"trader trades", "trader", "trader-secret", PermissionTrade, http.StatusOK},
+		{"trader reads", "trader", "trader-secret", PermissionRead, http.StatusOK},
+		{"reader reads", "reader", "reader-secret", PermissionRead, http.StatusOK},
+		{"reader trades", "reader", "reader-secret", PermissionTrade, http.StatusForbidden},
+		{"bad signature", "reader", "trader-secret", PermissionRead, http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r := signedRequest("/test", test.key, test.secret, time.Now())
+		auth.authorize(test.permission, served)(w, r)
+		if w.Code != test.status {
+			t.Errorf("%s: got status %d, expected %d", test.name, w.Code, test.status)
+		}
+	}
+
+	// without an authenticator everything is allowed
+	w := httptest.NewRecorder()
+	var none *Authenticator
+	none.authorize(PermissionTrade, func(w http.ResponseWriter, r *http.Request) {})(w,
+		httptest.NewRequest("GET", "/order", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("unauthenticated: got status %d, expected 200", w.Code)
+	}
+}
+
+func TestOrderRestrictions(t *testing.T) {
+	e, err := Serve("127.0.0.1:0", testAuthenticator(t), "entity-a", "entity-b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Shutdown(context.Background())
+
+	// answer every order that reaches an account
+	for _, account := range []string{"entity-a", "entity-b"} {
+		go func(requests RequestsChannel) {
+			for request := range requests {
+				request.Respond("filled")
+			}
+		}(e.RequestsChannel(account))
+	}
+
+	tests := []struct {
+		name   string
+		key    string
+		secret string
+		query  string
+		status int
+	}{
+		{"allowed", "doge", "doge-secret", "account=entity-a&symbol=DOGE-USDT", http.StatusOK},
+		{"other symbol", "doge", "doge-secret", "account=entity-a&symbol=BTC-USDT", http.StatusForbidden},
+		{"other account", "doge", "doge-secret", "account=entity-b&symbol=DOGE-USDT", http.StatusForbidden},
+		{"unrestricted", "trader", "trader-secret", "account=entity-b&symbol=BTC-USDT", http.StatusOK},
+		{"read only", "reader", "reader-secret", "account=entity-a&symbol=DOGE-USDT", http.StatusForbidden},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r := signedRequest("/order?side=Buy&quantity=1&"+test.query, test.key, test.secret, time.Now())
+		e.mux.ServeHTTP(w, r)
+		if w.Code != test.status {
+			t.Errorf("%s: got status %d %s, expected %d", test.name, w.Code, strings.TrimSpace(w.Body.String()), test.status)
+		}
+	}
+}
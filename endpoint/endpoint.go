@@ -1,9 +1,12 @@
 package endpoint
 
 import (
+	"context"
+	"expvar"
 	"fmt"
 	"log"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -16,41 +19,170 @@ import (
 // RequestsChannel is a channel of http requests.
 type RequestsChannel <-chan *Request
 
+// Validator checks an order before it is handed to its account, and may adjust it, for example
+// to round its quantity. An error rejects the order with the error as the reason.
+type Validator interface {
+	Validate(order *client.NewOrderSingle) error
+}
+
+// localRejects counts the orders rejected by the validator, per account.
+var localRejects = expvar.NewMap("local_rejects")
+
 // Endpoint is the REST endpoint for the order API.
 type Endpoint struct {
-	requests chan *Request
+	requests map[string]chan *Request
 	addr     string
+	auth     *Authenticator
+	mux      *http.ServeMux
+	server   *http.Server
+
+	// drainC is closed once the endpoint stops accepting new orders
+	drainC    chan interface{}
+	drainOnce sync.Once
+
+	mu        sync.RWMutex
+	validator Validator
 }
 
-// Serve returns an http endpoint, which provides the client facing order REST API.
-func Serve(addr string) (result *Endpoint, err error) {
+// Serve returns an http endpoint, which provides the client facing order REST API. Requests are
+// routed to the named accounts by the 'account' parameter, which may be omitted when there is
+// only one account. If auth is nil, requests are not authenticated.
+func Serve(addr string, auth *Authenticator, accounts ...string) (result *Endpoint, err error) {
+	if len(accounts) == 0 {
+		err = errors.New("at least one account is required")
+		return
+	}
 	result = &Endpoint{
 		addr:     addr,
-		requests: make(chan *Request),
+		auth:     auth,
+		requests: make(map[string]chan *Request),
+		drainC:   make(chan interface{}),
+	}
+	if auth == nil {
+		log.Printf("warning: the endpoint on %s is not authenticated", addr)
+	}
+	for _, account := range accounts {
+		if _, ok := result.requests[account]; ok {
+			err = fmt.Errorf("duplicate account %s", account)
+			return
+		}
+		result.requests[account] = make(chan *Request)
 	}
 
-	go result.runServe()
+	result.runServe()
 	return
 }
 
-// RequestsChannel returns a channel that incoming http requests are dispatched to.
-func (e *Endpoint) RequestsChannel() RequestsChannel {
-	return e.requests
+// RequestsChannel returns a channel that incoming http requests for the given account are
+// dispatched to.
+func (e *Endpoint) RequestsChannel(account string) RequestsChannel {
+	return e.requests[account]
+}
+
+// SetValidator sets the validator of the orders, or removes it if nil.
+func (e *Endpoint) SetValidator(validator Validator) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.validator = validator
+}
+
+// validate checks the order with the validator, if any.
+func (e *Endpoint) validate(order *client.NewOrderSingle) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.validator == nil {
+		return nil
+	}
+	return e.validator.Validate(order)
+}
+
+// Drain stops accepting new orders. Order requests received after the call, or still waiting
+// to be picked up by their account, are answered with 503 Service Unavailable.
+func (e *Endpoint) Drain() {
+	e.drainOnce.Do(func() {
+		log.Printf("draining, no longer accepting orders")
+		close(e.drainC)
+	})
+}
+
+// Shutdown stops accepting orders and gracefully shuts down the http server, waiting for the
+// active requests to be answered until the context is done.
+func (e *Endpoint) Shutdown(ctx context.Context) error {
+	e.Drain()
+	return e.server.Shutdown(ctx)
+}
+
+// Handle registers an additional handler, such as an admin or monitoring page, served to
+// requests with the given permission.
+func (e *Endpoint) Handle(pattern string, permission Permission, handler http.Handler) {
+	e.mux.HandleFunc(pattern, e.auth.authorize(permission, handler.ServeHTTP))
+}
+
+// HandleAccounts registers a handler per account, routed by the 'account' parameter in the
+// same way as orders.
+func (e *Endpoint) HandleAccounts(pattern string, permission Permission, handlers map[string]http.Handler) {
+	e.Handle(pattern, permission, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		account, _, err := e.accountRequests(r)
+		if err == nil {
+			key := apiKeyFromContext(r.Context())
+			if key != nil && !key.allowsAccount(account) {
+				http.Error(w, fmt.Sprintf("api key %s may not use account %s", key.Key, account), http.StatusForbidden)
+				return
+			}
+			if handler, ok := handlers[account]; ok {
+				handler.ServeHTTP(w, r)
+				return
+			}
+			err = fmt.Errorf("no %s for account '%s'", pattern, account)
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}))
 }
 
 func (e *Endpoint) runServe() {
-	http.HandleFunc("/order", e.handleClientRequest)
-	http.HandleFunc("/ping", func(w http.ResponseWriter, r *http.Request) {
+	e.mux = http.NewServeMux()
+	e.mux.HandleFunc("/order", e.auth.authorize(PermissionTrade, e.handleClientRequest))
+	e.mux.HandleFunc("/quote", e.auth.authorize(PermissionTrade, e.handleQuoteRequest))
+	e.mux.HandleFunc("/ping", func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprintf(w, "pong")
 	})
+	e.mux.HandleFunc("/debug/vars", e.auth.authorize(PermissionRead, expvar.Handler().ServeHTTP))
+	e.server = &http.Server{
+		Addr:    e.addr,
+		Handler: e.mux,
+	}
 	go func() {
-		if err := http.ListenAndServe(e.addr, nil); err != nil {
+		if err := e.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("error listening: %s", err)
 		}
 	}()
 
 }
 
+// auditOrder records an order that was handed to its account. The order is already on its way
+// by then, so a failure to write the record doesn't fail the order: it's logged and counted in
+// the order_audit_errors metric instead, which should be alerted on.
+func (e *Endpoint) auditOrder(key *APIKey, remoteAddr string, account string, order *client.NewOrderSingle) {
+	log.Printf("api key %s placed order %s on account %s", key.Key, order.ClOrdID, account)
+	if e.auth.audit == nil {
+		return
+	}
+	if err := e.auth.audit.Record(key.Key, remoteAddr, account, order); err != nil {
+		log.Printf("error: order %s placed without an audit record: %s", order.ClOrdID, err)
+		auditErrors.Add(1)
+	}
+}
+
+// draining returns true once the endpoint has stopped accepting orders.
+func (e *Endpoint) draining() bool {
+	select {
+	case <-e.drainC:
+		return true
+	default:
+		return false
+	}
+}
+
 func getQueryKeyValue(r *http.Request, key string, required bool) (value string, err error) {
 	keys, ok := r.URL.Query()[key]
 	if !ok || len(keys[0]) < 1 {
@@ -64,67 +196,162 @@ func getQueryKeyValue(r *http.Request, key string, required bool) (value string,
 	return
 }
 
-func (e *Endpoint) handleClientRequest(w http.ResponseWriter, r *http.Request) {
-	log.Printf("received client request %s", r.URL)
-	symbol, err := getQueryKeyValue(r, "symbol", true)
-	if err != nil {
-		log.Print(err.Error())
-		http.Error(w, err.Error(), http.StatusBadRequest)
+// accountRequests returns the requests channel for the account in the request.
+func (e *Endpoint) accountRequests(r *http.Request) (account string, requests chan *Request, err error) {
+	if account, err = getQueryKeyValue(r, "account", len(e.requests) > 1); err != nil {
 		return
 	}
-	currency, err := getQueryKeyValue(r, "currency", false)
-	if err != nil {
-		log.Print(err.Error())
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if account == "" {
+		// only one account, so use it
+		for account, requests = range e.requests {
+		}
+		return
+	}
+	var ok bool
+	if requests, ok = e.requests[account]; !ok {
+		err = fmt.Errorf("unknown account '%s'", account)
+	}
+	return
+}
+
+// orderParameters are the parameters common to orders and quote requests.
+type orderParameters struct {
+	account  string
+	requests chan *Request
+	key      *APIKey
+	symbol   string
+	currency string
+	side     client.SideEnum
+	quantity decimal.Decimal
+}
+
+// parseOrderParameters reads the order parameters of the request, and checks that its API key
+// may trade the symbol on the account. It returns the http status of the error, if any.
+func (e *Endpoint) parseOrderParameters(r *http.Request) (p orderParameters, status int, err error) {
+	status = http.StatusBadRequest
+	if p.account, p.requests, err = e.accountRequests(r); err != nil {
+		return
+	}
+	if p.symbol, err = getQueryKeyValue(r, "symbol", true); err != nil {
+		return
+	}
+	p.key = apiKeyFromContext(r.Context())
+	if p.key != nil && (!p.key.allowsAccount(p.account) || !p.key.allowsSymbol(p.symbol)) {
+		err = fmt.Errorf("api key %s may not trade %s on account %s", p.key.Key, p.symbol, p.account)
+		status = http.StatusForbidden
+		return
+	}
+	if p.currency, err = getQueryKeyValue(r, "currency", false); err != nil {
 		return
 	}
 	sideString, err := getQueryKeyValue(r, "side", true)
 	if err != nil {
-		log.Print(err.Error())
-		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	side, err := client.ParseSide(sideString)
-	if err != nil {
-		log.Print(err.Error())
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if p.side, err = client.ParseSide(sideString); err != nil {
 		return
 	}
 	quantityString, err := getQueryKeyValue(r, "quantity", true)
 	if err != nil {
-		log.Print(err.Error())
-		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	quantity, err := decimal.NewFromString(quantityString)
-	if err != nil {
+	if p.quantity, err = decimal.NewFromString(quantityString); err != nil {
 		err = errors.Wrapf(err, "invalid quantity %s", quantityString)
+	}
+	return
+}
+
+// submit hands the request to its account, audits it if it's an order, and responds with the
+// outcome once it's resolved.
+func (e *Endpoint) submit(w http.ResponseWriter, r *http.Request, p orderParameters, request *Request) {
+	select {
+	case p.requests <- request:
+	case <-e.drainC:
+		http.Error(w, "shutting down, not accepting orders", http.StatusServiceUnavailable)
+		return
+	}
+	if p.key != nil && request.message != nil {
+		e.auditOrder(p.key, r.RemoteAddr, p.account, request.message)
+	}
+
+	// block on the response channel until we get a response
+	response := <-request.response
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	log.Printf("sending client response %s", response)
+	_, _ = fmt.Fprintf(w, response)
+}
+
+func (e *Endpoint) handleClientRequest(w http.ResponseWriter, r *http.Request) {
+	log.Printf("received client request %s", r.URL)
+	if e.draining() {
+		http.Error(w, "shutting down, not accepting orders", http.StatusServiceUnavailable)
+		return
+	}
+	p, status, err := e.parseOrderParameters(r)
+	if err != nil {
 		log.Print(err.Error())
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		http.Error(w, err.Error(), status)
 		return
 	}
+	// an order with an rfqid accepts the quote, for the quoted side and quantity
+	rfqID, _ := getQueryKeyValue(r, "rfqid", false)
 
 	// generate a NewOrderSingle structure that will be used to submit a market order
 	clOrdID := uuid.New().String()
 	newOrderRequest := &Request{
+		account: p.account,
 		message: &client.NewOrderSingle{
-			Symbol:       symbol,
-			Currency:     currency,
+			Symbol:       p.symbol,
+			Currency:     p.currency,
 			ClOrdID:      clOrdID,
-			Side:         side,
-			OrderQty:     quantity,
+			Side:         p.side,
+			OrderQty:     p.quantity,
 			OrdType:      client.OrdType.Market,
 			TimeInForce:  client.TimeInForce.FillOrKill,
 			TransactTime: client.MicrosTimestamp(time.Now()),
 		},
 		response: make(chan string),
 	}
-	e.requests <- newOrderRequest
+	if rfqID != "" {
+		newOrderRequest.message.OrdType = client.OrdType.RFQ
+		newOrderRequest.message.RFQID = rfqID
+	}
+	// reject orders the server would reject, with the reason
+	if err = e.validate(newOrderRequest.message); err != nil {
+		log.Printf("rejected order %s locally: %s", clOrdID, err)
+		localRejects.Add(p.account, 1)
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = fmt.Fprintf(w, "rejected(%s)", err)
+		return
+	}
+	e.submit(w, r, p, newOrderRequest)
+}
 
-	// block on the response channel until we get a response
-	response := <-newOrderRequest.response
-	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-	log.Printf("sending client response %s", response)
-	_, _ = fmt.Fprintf(w, response)
+// handleQuoteRequest requests a quote for the account, and responds with the quote once it's
+// received: quoted(<rfqid> <quantity> @ <price> until <time>), or rejected(<reason>).
+func (e *Endpoint) handleQuoteRequest(w http.ResponseWriter, r *http.Request) {
+	log.Printf("received quote request %s", r.URL)
+	if e.draining() {
+		http.Error(w, "shutting down, not accepting orders", http.StatusServiceUnavailable)
+		return
+	}
+	p, status, err := e.parseOrderParameters(r)
+	if err != nil {
+		log.Print(err.Error())
+		http.Error(w, err.Error(), status)
+		return
+	}
+	e.submit(w, r, p, &Request{
+		account: p.account,
+		quoteRequest: &client.QuoteRequest{
+			QuoteReqID:   uuid.New().String(),
+			Symbol:       p.symbol,
+			Currency:     p.currency,
+			Side:         p.side,
+			OrderQty:     p.quantity,
+			TransactTime: client.MicrosTimestamp(time.Now()),
+		},
+		response: make(chan string),
+	})
 }
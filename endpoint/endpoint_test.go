@@ -0,0 +1,181 @@
+package endpoint
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/pintu-crypto/b2b-order/client"
+)
+
+// minSize is a validator rounding the quantity to a whole number, and rejecting less than 200.
+type minSize struct{}
+
+func (minSize) Validate(order *client.NewOrderSingle) error {
+	order.OrderQty = order.OrderQty.Floor()
+	if order.OrderQty.LessThan(decimal.NewFromInt(200)) {
+		return errors.New("below min size 200 DOGE")
+	}
+	return nil
+}
+
+func TestEndpointValidator(t *testing.T) {
+	requests := make(chan *Request, 1)
+	e := &Endpoint{
+		requests: map[string]chan *Request{"test": requests},
+		drainC:   make(chan interface{}),
+	}
+	e.SetValidator(minSize{})
+
+	// an invalid order is rejected with the reason, without reaching the account
+	w := httptest.NewRecorder()
+	e.handleClientRequest(w, httptest.NewRequest("GET", "/order?symbol=DOGE-USDT&side=Buy&quantity=150", nil))
+	if body := w.Body.String(); body != "rejected(below min size 200 DOGE)" {
+		t.Errorf("got %s, expected a local reject", body)
+	}
+	if len(requests) != 0 {
+		t.Error("the rejected order was handed to the account")
+	}
+
+	// a valid order is handed to the account as adjusted by the validator
+	w = httptest.NewRecorder()
+	go func() {
+		request := <-requests
+		request.Respond("filled(" + request.Message().OrderQty.String() + " @ 0.2)")
+	}()
+	e.handleClientRequest(w, httptest.NewRequest("GET", "/order?symbol=DOGE-USDT&side=Buy&quantity=210.5", nil))
+	if body := w.Body.String(); body != "filled(210 @ 0.2)" {
+		t.Errorf("got %s, expected the rounded order to be filled", body)
+	}
+}
+
+func TestEndpointQuote(t *testing.T) {
+	requests := make(chan *Request, 1)
+	e := &Endpoint{
+		requests: map[string]chan *Request{"test": requests},
+		drainC:   make(chan interface{}),
+	}
+
+	// a quote request is answered with the quote
+	go func() {
+		request := <-requests
+		quoteRequest := request.QuoteRequest()
+		if request.Message() != nil || quoteRequest == nil || request.Symbol() != "DOGE-USDT" ||
+			quoteRequest.Side != client.Side.Buy || quoteRequest.QuoteReqID == "" {
+			request.Respond("rejected(not a quote request)")
+			return
+		}
+		request.Respond("quoted(Q1 1000 @ 0.21 until 2026-01-01T00:00:10Z)")
+	}()
+	w := httptest.NewRecorder()
+	e.handleQuoteRequest(w, httptest.NewRequest("GET", "/quote?symbol=DOGE-USDT&side=Buy&quantity=1000", nil))
+	if body := w.Body.String(); body != "quoted(Q1 1000 @ 0.21 until 2026-01-01T00:00:10Z)" {
+		t.Errorf("got %s, expected a quote", body)
+	}
+
+	// an order with an rfqid accepts the quote
+	go func() {
+		request := <-requests
+		order := request.Message()
+		request.Respond("filled(" + order.OrdType.String() + " " + order.RFQID + ")")
+	}()
+	w = httptest.NewRecorder()
+	e.handleClientRequest(w, httptest.NewRequest("GET", "/order?symbol=DOGE-USDT&side=Buy&quantity=1000&rfqid=Q1", nil))
+	if body := w.Body.String(); body != "filled(RFQ Q1)" {
+		t.Errorf("got %s, expected an RFQ order", body)
+	}
+}
+
+func TestEndpointAccounts(t *testing.T) {
+	single := &Endpoint{
+		requests: map[string]chan *Request{"entity-a": make(chan *Request)},
+		drainC:   make(chan interface{}),
+	}
+	multiple := &Endpoint{
+		requests: map[string]chan *Request{
+			"entity-a": make(chan *Request),
+			"entity-b": make(chan *Request),
+		},
+		drainC: make(chan interface{}),
+	}
+	tests := []struct {
+		name     string
+		endpoint *Endpoint
+		query    string
+		account  string
+		valid    bool
+	}{
+		{"single account omitted", single, "", "entity-a", true},
+		{"single account named", single, "account=entity-a", "entity-a", true},
+		{"single account unknown", single, "account=entity-b", "", false},
+		{"multiple accounts omitted", multiple, "", "", false},
+		{"multiple accounts named", multiple, "account=entity-b", "entity-b", true},
+		{"multiple accounts unknown", multiple, "account=entity-c", "", false},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/order?"+test.query, nil)
+		account, requests, err := test.endpoint.accountRequests(r)
+		if (err == nil) != test.valid {
+			t.Errorf("%s: got error %v, expected valid %t", test.name, err, test.valid)
+			continue
+		}
+		if test.valid && (account != test.account || requests != test.endpoint.requests[test.account]) {
+			t.Errorf("%s: got account %s, expected %s", test.name, account, test.account)
+		}
+	}
+
+	// with several accounts, an order without one is rejected
+	w := httptest.NewRecorder()
+	multiple.handleClientRequest(w, httptest.NewRequest("GET", "/order?symbol=DOGE-USDT&side=Buy&quantity=1", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("got status %d, expected the order without an account to be rejected", w.Code)
+	}
+}
+
+func TestHandleAccounts(t *testing.T) {
+	e := &Endpoint{
+		auth: testAuthenticator(t),
+		mux:  http.NewServeMux(),
+		requests: map[string]chan *Request{
+			"entity-a": make(chan *Request),
+			"entity-b": make(chan *Request),
+		},
+		drainC: make(chan interface{}),
+	}
+	handler := func(account string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, account)
+		})
+	}
+	e.HandleAccounts("/positions", PermissionRead, map[string]http.Handler{
+		"entity-a": handler("entity-a"),
+		"entity-b": handler("entity-b"),
+	})
+
+	tests := []struct {
+		name   string
+		key    string
+		secret string
+		query  string
+		status int
+	}{
+		{"allowed", "doge", "doge-secret", "account=entity-a", http.StatusOK},
+		{"other account", "doge", "doge-secret", "account=entity-b", http.StatusForbidden},
+		{"unrestricted", "reader", "reader-secret", "account=entity-b", http.StatusOK},
+		{"missing account", "reader", "reader-secret", "", http.StatusBadRequest},
+		{"unknown account", "reader", "reader-secret", "account=entity-c", http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		e.mux.ServeHTTP(w, signedRequest("/positions?"+test.query, test.key, test.secret, time.Now()))
+		if w.Code != test.status {
+			t.Errorf("%s: got status %d %s, expected %d", test.name, w.Code, strings.TrimSpace(w.Body.String()), test.status)
+		}
+	}
+}
@@ -5,17 +5,44 @@ import "github.com/pintu-crypto/b2b-order/client"
 // Request is an incoming client request to order, for example. It has a message that represents the incoming
 // request, and a channel to respond to the request.
 type Request struct {
-	message  *client.NewOrderSingle
-	response chan string
+	account      string
+	message      *client.NewOrderSingle
+	quoteRequest *client.QuoteRequest
+	response     chan string
 }
 
-// Message returns the client request data.
+// Account returns the name of the account the request should be placed on.
+func (r *Request) Account() string {
+	return r.account
+}
+
+// Message returns the client request data, or nil for a quote request.
 func (r *Request) Message() *client.NewOrderSingle {
 	return r.message
 }
 
+// QuoteRequest returns the quote request, or nil for an order.
+func (r *Request) QuoteRequest() *client.QuoteRequest {
+	return r.quoteRequest
+}
+
+// Symbol returns the symbol of the order or quote request.
+func (r *Request) Symbol() string {
+	if r.quoteRequest != nil {
+		return r.quoteRequest.Symbol
+	}
+	return r.message.Symbol
+}
+
 // Respond should be called to send back a message with the outcome of this request. Must be called once the
 // request has been resolved.
 func (r *Request) Respond(message string) {
 	r.response <- message
 }
+
+// NewRequest returns a request to place the order on the account, for callers other than the
+// http endpoint, and the channel the outcome is sent on once the request is resolved.
+func NewRequest(account string, message *client.NewOrderSingle) (*Request, <-chan string) {
+	response := make(chan string, 1)
+	return &Request{account: account, message: message, response: response}, response
+}
@@ -0,0 +1,216 @@
+package export
+
+import (
+	"bufio"
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"reflect"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+
+	"github.com/pintu-crypto/b2b-order/client"
+)
+
+// Format is the file format of an export.
+type Format string
+
+// The supported export formats.
+const (
+	CSV     Format = "csv"
+	JSONL   Format = "jsonl"
+	Parquet Format = "parquet"
+)
+
+// ParseFormat parses the name of an export format.
+func ParseFormat(value string) (format Format, err error) {
+	switch format = Format(value); format {
+	case CSV, JSONL, Parquet:
+	default:
+		err = errors.Errorf("unknown export format '%s', expected csv, jsonl or parquet", value)
+	}
+	return
+}
+
+// ContentType returns the MIME type of the format.
+func (f Format) ContentType() string {
+	switch f {
+	case CSV:
+		return "text/csv"
+	case JSONL:
+		return "application/x-ndjson"
+	}
+	return "application/octet-stream"
+}
+
+// column is an exported field of the records.
+type column struct {
+	name      string
+	index     int
+	timestamp bool
+}
+
+var (
+	microsTimestampType = reflect.TypeOf(client.MicrosTimestamp{})
+	decimalType         = reflect.TypeOf(decimal.Decimal{})
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// columns returns the columns of the records of the given struct type, one per field in
+// declaration order, so that the column order only changes with the messages.
+func columns(recordType reflect.Type) (result []column, err error) {
+	for i := 0; i < recordType.NumField(); i++ {
+		field := recordType.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		switch {
+		case field.Type == microsTimestampType:
+			result = append(result, column{name: field.Name, index: i, timestamp: true})
+		case field.Type == decimalType, field.Type.Implements(textMarshalerType), field.Type.Kind() == reflect.String:
+			result = append(result, column{name: field.Name, index: i})
+		default:
+			err = errors.Errorf("unable to export %s field %s of type %s", recordType.Name(), field.Name, field.Type)
+			return
+		}
+	}
+	return
+}
+
+// value returns the text of the column of a record. A zero timestamp is empty, and a decimal
+// keeps the number of decimal places it was received with.
+func (c column) value(record reflect.Value) string {
+	field := record.Field(c.index)
+	switch value := field.Interface().(type) {
+	case client.MicrosTimestamp:
+		if time.Time(value).IsZero() {
+			return ""
+		}
+		return value.String()
+	case decimal.Decimal:
+		if value.Exponent() < 0 {
+			return value.StringFixed(-value.Exponent())
+		}
+		return value.String()
+	case encoding.TextMarshaler:
+		text, _ := value.MarshalText()
+		return string(text)
+	}
+	return field.String()
+}
+
+// recordWriter writes the rows of an export in a format.
+type recordWriter interface {
+	write(row []string) error
+	close() error
+}
+
+// Write writes the records, a slice of structs or of pointers to structs such as trades or
+// execution reports, in the given format, with a column per field in declaration order.
+func Write(w io.Writer, format Format, records interface{}) (err error) {
+	slice := reflect.ValueOf(records)
+	if slice.Kind() != reflect.Slice {
+		return errors.Errorf("unable to export %T, expected a slice", records)
+	}
+	recordType := slice.Type().Elem()
+	if recordType.Kind() == reflect.Ptr {
+		recordType = recordType.Elem()
+	}
+	if recordType.Kind() != reflect.Struct {
+		return errors.Errorf("unable to export %T, expected a slice of structs", records)
+	}
+	cols, err := columns(recordType)
+	if err != nil {
+		return
+	}
+
+	var out recordWriter
+	switch format {
+	case CSV:
+		out, err = newCSVWriter(w, cols)
+	case JSONL:
+		out = newJSONLWriter(w, cols)
+	case Parquet:
+		out = newParquetWriter(w, cols)
+	default:
+		_, err = ParseFormat(string(format))
+	}
+	if err != nil {
+		return
+	}
+	row := make([]string, len(cols))
+	for i := 0; i < slice.Len(); i++ {
+		record := reflect.Indirect(slice.Index(i))
+		for j, col := range cols {
+			row[j] = col.value(record)
+		}
+		if err = out.write(row); err != nil {
+			return errors.Wrapf(err, "unable to write %s record %d", format, i+1)
+		}
+	}
+	if err = out.close(); err != nil {
+		err = errors.Wrapf(err, "unable to write %s export", format)
+	}
+	return
+}
+
+// csvWriter writes a header and a line per record.
+type csvWriter struct {
+	out *csv.Writer
+}
+
+func newCSVWriter(w io.Writer, cols []column) (result *csvWriter, err error) {
+	result = &csvWriter{out: csv.NewWriter(w)}
+	header := make([]string, len(cols))
+	for i, col := range cols {
+		header[i] = col.name
+	}
+	err = result.out.Write(header)
+	return
+}
+
+func (c *csvWriter) write(row []string) error {
+	return c.out.Write(row)
+}
+
+func (c *csvWriter) close() error {
+	c.out.Flush()
+	return c.out.Error()
+}
+
+// jsonlWriter writes a JSON object per line, with the fields in column order. Values are
+// strings, so that decimals keep their precision, and an empty timestamp is null.
+type jsonlWriter struct {
+	out  *bufio.Writer
+	cols []column
+}
+
+func newJSONLWriter(w io.Writer, cols []column) *jsonlWriter {
+	return &jsonlWriter{out: bufio.NewWriter(w), cols: cols}
+}
+
+func (j *jsonlWriter) write(row []string) (err error) {
+	line := []byte{'{'}
+	for i, col := range j.cols {
+		if i > 0 {
+			line = append(line, ',')
+		}
+		name, _ := json.Marshal(col.name)
+		line = append(append(line, name...), ':')
+		if col.timestamp && row[i] == "" {
+			line = append(line, "null"...)
+			continue
+		}
+		value, _ := json.Marshal(row[i])
+		line = append(line, value...)
+	}
+	_, err = j.out.Write(append(line, '}', '\n'))
+	return
+}
+
+func (j *jsonlWriter) close() error {
+	return j.out.Flush()
+}
@@ -0,0 +1,110 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/pintu-crypto/b2b-order/client"
+)
+
+func testTrades() []*client.Trade {
+	return []*client.Trade{{
+		Timestamp:    client.MicrosTimestamp(time.Date(2026, 1, 1, 10, 0, 0, 1000, time.UTC)),
+		Symbol:       "DOGE-USDT",
+		TradeID:      "T1",
+		Side:         client.Side.Buy,
+		TransactTime: client.MicrosTimestamp(time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)),
+		Price:        decimal.RequireFromString("0.21050"),
+		Quantity:     decimal.RequireFromString("1000"),
+		Fee:          decimal.RequireFromString("0.000000000000000001"),
+	}, {
+		Symbol:  "BTC-USDT",
+		TradeID: "T2, \"quoted\"",
+		Side:    client.Side.Sell,
+	}}
+}
+
+func TestWriteCSV(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := Write(buf, CSV, testTrades()); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(buf.String(), "\n")
+	if !strings.HasPrefix(lines[0], "Timestamp,User,Symbol,OrderID,TradeID,Side,TransactTime,Price,Quantity,") {
+		t.Errorf("got header %s, expected the fields in order", lines[0])
+	}
+	// decimals keep their places, and a zero timestamp is empty
+	expected := "2026-01-01T10:00:00.000001Z,,DOGE-USDT,,T1,Buy,2026-01-01T10:00:00.000000Z,0.21050,1000,,,0,0.000000000000000001,"
+	if !strings.HasPrefix(lines[1], expected) {
+		t.Errorf("got %s, expected %s...", lines[1], expected)
+	}
+	if !strings.HasPrefix(lines[2], `,,BTC-USDT,,"T2, ""quoted""",Sell,,0,0,`) {
+		t.Errorf("got %s, expected the second trade quoted", lines[2])
+	}
+}
+
+func TestWriteJSONL(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := Write(buf, JSONL, testTrades()); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, expected 2", len(lines))
+	}
+	expected := `{"Timestamp":"2026-01-01T10:00:00.000001Z","User":"","Symbol":"DOGE-USDT","OrderID":"","TradeID":"T1",` +
+		`"Side":"Buy","TransactTime":"2026-01-01T10:00:00.000000Z","Price":"0.21050","Quantity":"1000",`
+	if !strings.HasPrefix(lines[0], expected) {
+		t.Errorf("got %s, expected %s...", lines[0], expected)
+	}
+	if !strings.HasPrefix(lines[1], `{"Timestamp":null,`) {
+		t.Errorf("got %s, expected a null timestamp", lines[1])
+	}
+}
+
+func TestWriteParquet(t *testing.T) {
+	buf := &bytes.Buffer{}
+	reports := []client.ExecutionReport{{ClOrdID: "C1", OrderQty: decimal.RequireFromString("1.50")}}
+	if err := Write(buf, Parquet, reports); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if !bytes.HasPrefix(data, []byte(parquetMagic)) || !bytes.HasSuffix(data, []byte(parquetMagic)) {
+		t.Fatalf("missing the parquet magic")
+	}
+	footerLength := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footer := data[len(data)-8-footerLength : len(data)-8]
+	// the footer starts with the version, then the schema of the 38 columns and the root
+	if !bytes.HasPrefix(footer, []byte{0x15, 0x02, 0x19, 0xfc, 39}) {
+		t.Errorf("got footer % x..., expected version 1 and 39 schema elements", footer[:5])
+	}
+	for _, value := range []string{"schema", "ClOrdID", "OrderQty", "C1", "1.50"} {
+		if !bytes.Contains(data, []byte(value)) {
+			t.Errorf("%s not in the file", value)
+		}
+	}
+}
+
+func TestWriteErrors(t *testing.T) {
+	if _, err := ParseFormat("xlsx"); err == nil {
+		t.Error("parsed an unknown format")
+	}
+	if err := Write(&bytes.Buffer{}, CSV, "not a slice"); err == nil {
+		t.Error("exported a string")
+	}
+	if err := Write(&bytes.Buffer{}, CSV, []struct{ Values []int }{{}}); err == nil {
+		t.Error("exported a slice field")
+	}
+}
+
+func TestRLELevels(t *testing.T) {
+	levels := rleLevels([]bool{true, true, false, true})
+	if expected := []byte{4, 1, 2, 0, 2, 1}; !bytes.Equal(levels, expected) {
+		t.Errorf("got % x, expected % x", levels, expected)
+	}
+}
@@ -0,0 +1,199 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/pintu-crypto/b2b-order/client"
+	"github.com/pintu-crypto/b2b-order/order"
+)
+
+// DefaultIdle is how long a history waits for more elements before taking the window as
+// exhausted.
+const DefaultIdle = 5 * time.Second
+
+// FetchTrades returns the trades from the start to the end date, replayed by a historical
+// subscription on the connection.
+func FetchTrades(conn client.Connection, requestID int64, from time.Time, to time.Time,
+	idle time.Duration) (trades []*client.Trade, err error) {
+	err = fetch(conn, requestID, "Trade", from, to, idle, func(data json.RawMessage) (err error) {
+		trade := &client.Trade{}
+		if err = json.Unmarshal(data, trade); err != nil {
+			return errors.Wrap(err, "unable to decode trade")
+		}
+		if !time.Time(trade.Timestamp).Before(from) {
+			trades = append(trades, trade)
+		}
+		return
+	})
+	return
+}
+
+// FetchExecutionReports returns the execution reports from the start to the end date, replayed
+// by a historical subscription on the connection.
+func FetchExecutionReports(conn client.Connection, requestID int64, from time.Time, to time.Time,
+	idle time.Duration) (reports []*client.ExecutionReport, err error) {
+	err = fetch(conn, requestID, "ExecutionReport", from, to, idle, func(data json.RawMessage) (err error) {
+		report := &client.ExecutionReport{}
+		if err = json.Unmarshal(data, report); err != nil {
+			return errors.Wrap(err, "unable to decode execution report")
+		}
+		// the open orders are sent first, whenever they were last updated
+		if !time.Time(report.Timestamp).Before(from) {
+			reports = append(reports, report)
+		}
+		return
+	})
+	return
+}
+
+// fetch decodes the elements of the history of the stream, which keeps those after the start.
+func fetch(conn client.Connection, requestID int64, stream string, from time.Time, to time.Time,
+	idle time.Duration, decode func(data json.RawMessage) error) (err error) {
+	history, err := client.NewHistory(conn, requestID, stream, from, to, idle)
+	if err != nil {
+		return
+	}
+	defer history.Close()
+	for history.Next() {
+		if err = decode(history.Data()); err != nil {
+			return
+		}
+	}
+	return history.Err()
+}
+
+// Connection is a connection opened for an export, closed once it's written.
+type Connection interface {
+	client.Connection
+	Close()
+}
+
+// Dialer opens a connection of the account for an export.
+type Dialer func() (conn Connection, err error)
+
+// Exporter serves the exports of an account: its execution reports from the local store of the
+// server, and its trades from a historical subscription on a connection of their own. It is safe
+// for concurrent use.
+type Exporter struct {
+	merger *order.Merger
+	dial   Dialer
+	idle   time.Duration
+}
+
+// NewExporter returns an exporter of the reports of the merger, and of the trades fetched on
+// connections opened with the dialer.
+func NewExporter(merger *order.Merger, dial Dialer) *Exporter {
+	return &Exporter{merger: merger, dial: dial, idle: DefaultIdle}
+}
+
+// ServeHTTP responds with an export of the trades or execution reports, selected by the kind
+// parameter, between the from and to parameters, in the format parameter, csv by default.
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "expected GET", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	format := CSV
+	if value := query.Get("format"); value != "" {
+		var err error
+		if format, err = ParseFormat(value); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	from, to, err := parseRange(query.Get("from"), query.Get("to"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var records interface{}
+	switch kind := query.Get("kind"); kind {
+	case "executions":
+		records = e.executionReports(from, to)
+	case "trades":
+		if records, err = e.trades(from, to); err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+	default:
+		http.Error(w, fmt.Sprintf("unknown kind '%s', expected trades or executions", kind), http.StatusBadRequest)
+		return
+	}
+	// the export is written in full first, so that a failure isn't a truncated file
+	buf := &bytes.Buffer{}
+	if err = Write(buf, format, records); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s-%s-%s.%s", query.Get("kind"),
+		from.UTC().Format("20060102T150405Z"), to.UTC().Format("20060102T150405Z"), format))
+	_, _ = w.Write(buf.Bytes())
+}
+
+// executionReports returns the reports of the local store between the start and the end date.
+func (e *Exporter) executionReports(from time.Time, to time.Time) (reports []client.ExecutionReport) {
+	for _, report := range e.merger.List(from.Add(-time.Microsecond)) {
+		if time.Time(report.Timestamp).After(to) {
+			break
+		}
+		reports = append(reports, report)
+	}
+	return
+}
+
+// trades fetches the trades between the start and the end date on a new connection.
+func (e *Exporter) trades(from time.Time, to time.Time) (trades []*client.Trade, err error) {
+	conn, err := e.dial()
+	if err != nil {
+		err = errors.Wrap(err, "unable to connect for the export")
+		return
+	}
+	defer conn.Close()
+	return FetchTrades(conn, 1, from, to, e.idle)
+}
+
+// parseRange parses the start and end of an export, each an RFC-3339 time or a YYYY-MM-DD date
+// in UTC. The end defaults to now.
+func parseRange(fromValue string, toValue string) (from time.Time, to time.Time, err error) {
+	if fromValue == "" {
+		err = errors.New("missing required parameter 'from'")
+		return
+	}
+	if from, err = parseTime(fromValue); err != nil {
+		return
+	}
+	to = time.Now()
+	if toValue != "" {
+		if to, err = parseTime(toValue); err != nil {
+			return
+		}
+	}
+	if !from.Before(to) {
+		err = errors.Errorf("from %s is not before to %s", from.Format(time.RFC3339), to.Format(time.RFC3339))
+	}
+	return
+}
+
+// parseTime parses an RFC-3339 time or a YYYY-MM-DD date in UTC.
+func parseTime(value string) (result time.Time, err error) {
+	if !strings.Contains(value, "T") {
+		if result, err = time.Parse("2006-01-02", value); err != nil {
+			err = errors.Wrapf(err, "invalid date %s", value)
+		}
+		return
+	}
+	if result, err = time.Parse(time.RFC3339Nano, value); err != nil {
+		err = errors.Wrapf(err, "invalid time %s", value)
+	}
+	return
+}
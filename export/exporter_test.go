@@ -0,0 +1,117 @@
+package export
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/pintu-crypto/b2b-order/client"
+	"github.com/pintu-crypto/b2b-order/order"
+)
+
+// fakeConnection replays the given messages, and accepts the requests sent to it.
+type fakeConnection struct {
+	incoming chan []byte
+	outgoing chan []byte
+	errors   chan error
+	closed   bool
+}
+
+func newFakeConnection(messages ...string) *fakeConnection {
+	conn := &fakeConnection{
+		incoming: make(chan []byte, len(messages)),
+		outgoing: make(chan []byte, 10),
+		errors:   make(chan error),
+	}
+	for _, msg := range messages {
+		conn.incoming <- []byte(msg)
+	}
+	return conn
+}
+
+func (c *fakeConnection) IncomingChannel() client.IncomingChannel { return c.incoming }
+func (c *fakeConnection) OutgoingChannel() client.OutgoingChannel { return c.outgoing }
+func (c *fakeConnection) ErrorChannel() client.ErrorChannel       { return c.errors }
+func (c *fakeConnection) Close()                                  { c.closed = true }
+
+func TestFetchExecutionReports(t *testing.T) {
+	conn := newFakeConnection(
+		// the open order is sent first, although it's older than the range
+		`{"reqid":1,"seq":1,"type":"ExecutionReport","data":[
+			{"Timestamp":"2025-12-31T09:00:00.000000Z","ClOrdID":"C0","OrdStatus":"New"},
+			{"Timestamp":"2026-01-01T09:00:00.000000Z","ClOrdID":"C1","OrdStatus":"Filled"},
+			{"Timestamp":"2026-01-02T09:00:00.000000Z","ClOrdID":"C2","OrdStatus":"New"}]}`)
+	reports, err := FetchExecutionReports(conn, 1, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 1 || reports[0].ClOrdID != "C1" {
+		t.Errorf("got %+v, expected C1", reports)
+	}
+}
+
+func TestExporter(t *testing.T) {
+	merger := order.NewMerger("test")
+	for i, clOrdID := range []string{"C1", "C2", "C3"} {
+		merger.Add(&client.ExecutionReport{
+			Timestamp: client.MicrosTimestamp(time.Date(2026, 1, i+1, 12, 0, 0, 0, time.UTC)),
+			ExecID:    clOrdID,
+			ClOrdID:   clOrdID,
+		})
+	}
+	conn := newFakeConnection(
+		`{"type":"hello","session_id":"S1"}`,
+		`{"reqid":1,"seq":1,"type":"Trade","data":[
+			{"Timestamp":"2026-01-01T10:00:00.000000Z","TradeID":"T1","Price":"0.2100"},
+			{"Timestamp":"2026-01-02T10:00:00.000000Z","TradeID":"T2"}]}`)
+	dialed := 0
+	exporter := NewExporter(merger, func() (Connection, error) {
+		if dialed++; dialed > 1 {
+			return nil, errors.New("connection refused")
+		}
+		return conn, nil
+	})
+
+	tests := []struct {
+		target   string
+		code     int
+		contains []string
+		excludes []string
+	}{
+		{"/exports?kind=executions&from=2026-01-02&to=2026-01-03", http.StatusOK, []string{"ClOrdID", "C2"}, []string{"C1", "C3"}},
+		{"/exports?kind=executions&from=2026-01-01", http.StatusOK, []string{"C1", "C2", "C3"}, nil},
+		{"/exports?kind=trades&from=2026-01-01&to=2026-01-02&format=jsonl", http.StatusOK,
+			[]string{`"TradeID":"T1"`, `"Price":"0.2100"`}, []string{"T2"}},
+		{"/exports?kind=trades&from=2026-01-01&to=2026-01-02", http.StatusBadGateway, []string{"connection refused"}, nil},
+		{"/exports?kind=orders&from=2026-01-01", http.StatusBadRequest, []string{"unknown kind"}, nil},
+		{"/exports?kind=trades&from=2026-01-02&to=2026-01-01", http.StatusBadRequest, []string{"is not before"}, nil},
+		{"/exports?kind=trades", http.StatusBadRequest, []string{"missing required parameter 'from'"}, nil},
+		{"/exports?kind=trades&from=2026-01-01&format=xml", http.StatusBadRequest, []string{"unknown export format"}, nil},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		exporter.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.target, nil))
+		if w.Code != test.code {
+			t.Errorf("%s: got status %d, expected %d: %s", test.target, w.Code, test.code, w.Body)
+			continue
+		}
+		for _, value := range test.contains {
+			if !strings.Contains(w.Body.String(), value) {
+				t.Errorf("%s: %s not in %s", test.target, value, w.Body)
+			}
+		}
+		for _, value := range test.excludes {
+			if strings.Contains(w.Body.String(), value) {
+				t.Errorf("%s: %s in %s", test.target, value, w.Body)
+			}
+		}
+	}
+	if !conn.closed {
+		t.Error("the export connection wasn't closed")
+	}
+}
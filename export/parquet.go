@@ -0,0 +1,291 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// parquetMagic starts and ends a Parquet file.
+const parquetMagic = "PAR1"
+
+// Parquet physical types, repetitions, converted types, encodings and page types, as numbered
+// in the Parquet thrift definitions.
+const (
+	parquetInt64     = 2
+	parquetByteArray = 6
+
+	parquetRequired = 0
+	parquetOptional = 1
+
+	parquetUTF8            = 0
+	parquetTimestampMicros = 10
+
+	parquetPlain = 0
+	parquetRLE   = 3
+
+	parquetDataPage = 0
+)
+
+// parquetWriter writes a Parquet file with a single row group, once all the rows are known.
+// Timestamps are optional INT64 TIMESTAMP_MICROS columns, null when empty, and every other
+// column, decimals included, a required UTF8 string so that no precision is lost. Pages are
+// plain encoded and uncompressed.
+type parquetWriter struct {
+	out  io.Writer
+	cols []column
+	rows [][]string
+}
+
+// parquetChunk is the position of a column chunk in the file.
+type parquetChunk struct {
+	offset int64
+	size   int64
+}
+
+func newParquetWriter(w io.Writer, cols []column) *parquetWriter {
+	return &parquetWriter{out: w, cols: cols}
+}
+
+func (p *parquetWriter) write(row []string) error {
+	p.rows = append(p.rows, append([]string(nil), row...))
+	return nil
+}
+
+func (p *parquetWriter) close() (err error) {
+	file := &bytes.Buffer{}
+	file.WriteString(parquetMagic)
+	chunks := make([]parquetChunk, len(p.cols))
+	for i, col := range p.cols {
+		var page []byte
+		if page, err = p.page(i, col); err != nil {
+			return
+		}
+		header := newThriftWriter()
+		header.i32(1, parquetDataPage)
+		header.i32(2, int32(len(page)))
+		header.i32(3, int32(len(page)))
+		header.beginStruct(5)
+		header.i32(1, int32(len(p.rows)))
+		header.i32(2, parquetPlain)
+		header.i32(3, parquetRLE)
+		header.i32(4, parquetRLE)
+		header.endStruct()
+		header.endStruct()
+		chunks[i] = parquetChunk{offset: int64(file.Len()), size: int64(header.buf.Len() + len(page))}
+		file.Write(header.buf.Bytes())
+		file.Write(page)
+	}
+	footer := p.footer(chunks)
+	file.Write(footer)
+	_ = binary.Write(file, binary.LittleEndian, uint32(len(footer)))
+	file.WriteString(parquetMagic)
+	_, err = p.out.Write(file.Bytes())
+	return
+}
+
+// page returns the data page of the column, with its definition levels if it's optional.
+func (p *parquetWriter) page(index int, col column) (page []byte, err error) {
+	buf := &bytes.Buffer{}
+	if col.timestamp {
+		defined := make([]bool, len(p.rows))
+		for i, row := range p.rows {
+			defined[i] = row[index] != ""
+		}
+		levels := rleLevels(defined)
+		_ = binary.Write(buf, binary.LittleEndian, uint32(len(levels)))
+		buf.Write(levels)
+	}
+	for _, row := range p.rows {
+		value := row[index]
+		switch {
+		case !col.timestamp:
+			_ = binary.Write(buf, binary.LittleEndian, uint32(len(value)))
+			buf.WriteString(value)
+		case value != "":
+			var ts time.Time
+			if ts, err = time.Parse(time.RFC3339Nano, value); err != nil {
+				err = errors.Wrapf(err, "invalid %s", col.name)
+				return
+			}
+			_ = binary.Write(buf, binary.LittleEndian, ts.UnixMicro())
+		}
+	}
+	page = buf.Bytes()
+	return
+}
+
+// footer returns the file metadata: the schema, and the row group made of the given chunks.
+func (p *parquetWriter) footer(chunks []parquetChunk) []byte {
+	t := newThriftWriter()
+	t.i32(1, 1)
+	t.beginList(2, thriftStruct, len(p.cols)+1)
+	t.beginElement()
+	t.binary(4, "schema")
+	t.i32(5, int32(len(p.cols)))
+	t.endStruct()
+	for _, col := range p.cols {
+		t.beginElement()
+		if col.timestamp {
+			t.i32(1, parquetInt64)
+			t.i32(3, parquetOptional)
+			t.binary(4, col.name)
+			t.i32(6, parquetTimestampMicros)
+		} else {
+			t.i32(1, parquetByteArray)
+			t.i32(3, parquetRequired)
+			t.binary(4, col.name)
+			t.i32(6, parquetUTF8)
+		}
+		t.endStruct()
+	}
+	t.i64(3, int64(len(p.rows)))
+
+	var total int64
+	t.beginList(4, thriftStruct, 1)
+	t.beginElement()
+	t.beginList(1, thriftStruct, len(p.cols))
+	for i, col := range p.cols {
+		chunk := chunks[i]
+		total += chunk.size
+		t.beginElement()
+		t.i64(2, chunk.offset)
+		t.beginStruct(3)
+		if col.timestamp {
+			t.i32(1, parquetInt64)
+		} else {
+			t.i32(1, parquetByteArray)
+		}
+		t.beginList(2, thriftI32, 2)
+		t.listI32(parquetPlain)
+		t.listI32(parquetRLE)
+		t.beginList(3, thriftBinary, 1)
+		t.listBinary(col.name)
+		t.i32(4, 0)
+		t.i64(5, int64(len(p.rows)))
+		t.i64(6, chunk.size)
+		t.i64(7, chunk.size)
+		t.i64(9, chunk.offset)
+		t.endStruct()
+		t.endStruct()
+	}
+	t.i64(2, total)
+	t.i64(3, int64(len(p.rows)))
+	t.endStruct()
+	t.binary(6, "b2b-order")
+	t.endStruct()
+	return t.buf.Bytes()
+}
+
+// rleLevels encodes definition levels of bit width 1 as runs of the RLE/bit-packed hybrid
+// encoding.
+func rleLevels(defined []bool) []byte {
+	buf := &bytes.Buffer{}
+	for i := 0; i < len(defined); {
+		run := 1
+		for i+run < len(defined) && defined[i+run] == defined[i] {
+			run++
+		}
+		writeUvarint(buf, uint64(run)<<1)
+		if defined[i] {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+		i += run
+	}
+	return buf.Bytes()
+}
+
+// Thrift compact protocol types.
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes structs in the thrift compact protocol, which is how Parquet encodes its
+// metadata. It starts in a struct, ended by the last endStruct.
+type thriftWriter struct {
+	buf bytes.Buffer
+	// last is the last field ID written in each of the open structs
+	last []int16
+}
+
+func newThriftWriter() *thriftWriter {
+	return &thriftWriter{last: []int16{0}}
+}
+
+func (t *thriftWriter) field(id int16, fieldType byte) {
+	last := &t.last[len(t.last)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | fieldType)
+	} else {
+		t.buf.WriteByte(fieldType)
+		writeUvarint(&t.buf, zigzag(int64(id)))
+	}
+	*last = id
+}
+
+func (t *thriftWriter) i32(id int16, value int32) {
+	t.field(id, thriftI32)
+	writeUvarint(&t.buf, zigzag(int64(value)))
+}
+
+func (t *thriftWriter) i64(id int16, value int64) {
+	t.field(id, thriftI64)
+	writeUvarint(&t.buf, zigzag(value))
+}
+
+func (t *thriftWriter) binary(id int16, value string) {
+	t.field(id, thriftBinary)
+	t.listBinary(value)
+}
+
+func (t *thriftWriter) beginStruct(id int16) {
+	t.field(id, thriftStruct)
+	t.beginElement()
+}
+
+// beginElement starts a struct that is an element of a list.
+func (t *thriftWriter) beginElement() {
+	t.last = append(t.last, 0)
+}
+
+func (t *thriftWriter) endStruct() {
+	t.buf.WriteByte(0)
+	t.last = t.last[:len(t.last)-1]
+}
+
+func (t *thriftWriter) beginList(id int16, elementType byte, size int) {
+	t.field(id, thriftList)
+	if size < 15 {
+		t.buf.WriteByte(byte(size)<<4 | elementType)
+		return
+	}
+	t.buf.WriteByte(0xf0 | elementType)
+	writeUvarint(&t.buf, uint64(size))
+}
+
+func (t *thriftWriter) listI32(value int32) {
+	writeUvarint(&t.buf, zigzag(int64(value)))
+}
+
+func (t *thriftWriter) listBinary(value string) {
+	writeUvarint(&t.buf, uint64(len(value)))
+	t.buf.WriteString(value)
+}
+
+func zigzag(value int64) uint64 {
+	return uint64(value<<1) ^ uint64(value>>63)
+}
+
+func writeUvarint(buf *bytes.Buffer, value uint64) {
+	var data [binary.MaxVarintLen64]byte
+	buf.Write(data[:binary.PutUvarint(data[:], value)])
+}
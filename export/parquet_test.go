@@ -0,0 +1,257 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pintu-crypto/b2b-order/client"
+)
+
+// thriftReader decodes the thrift compact protocol into maps of field IDs to values: int64 for
+// integers, string for binaries, []interface{} for lists and map[int16]interface{} for structs.
+type thriftReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *thriftReader) byte() byte {
+	b := r.data[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	value, n := binary.Uvarint(r.data[r.pos:])
+	if n <= 0 {
+		panic(fmt.Sprintf("invalid varint at %d", r.pos))
+	}
+	r.pos += n
+	return value
+}
+
+func (r *thriftReader) varint() int64 {
+	value := r.uvarint()
+	return int64(value>>1) ^ -int64(value&1)
+}
+
+func (r *thriftReader) value(fieldType byte) interface{} {
+	switch fieldType {
+	case thriftI32, thriftI64:
+		return r.varint()
+	case thriftBinary:
+		size := int(r.uvarint())
+		value := string(r.data[r.pos : r.pos+size])
+		r.pos += size
+		return value
+	case thriftList:
+		header := r.byte()
+		size := int(header >> 4)
+		if size == 15 {
+			size = int(r.uvarint())
+		}
+		list := make([]interface{}, size)
+		for i := range list {
+			list[i] = r.value(header & 0x0f)
+		}
+		return list
+	case thriftStruct:
+		return r.readStruct()
+	}
+	panic(fmt.Sprintf("unexpected thrift type %d at %d", fieldType, r.pos))
+}
+
+func (r *thriftReader) readStruct() map[int16]interface{} {
+	fields := make(map[int16]interface{})
+	var id int16
+	for {
+		header := r.byte()
+		if header == 0 {
+			return fields
+		}
+		if delta := int16(header >> 4); delta != 0 {
+			id += delta
+		} else {
+			id = int16(r.varint())
+		}
+		fields[id] = r.value(header & 0x0f)
+	}
+}
+
+// parquetColumn is a column decoded from a file: its schema and its values, "" for nulls.
+type parquetColumn struct {
+	name      string
+	physical  int64
+	optional  bool
+	converted int64
+	values    []string
+}
+
+// readParquet decodes a file written by parquetWriter, checking the page headers and the
+// column chunk offsets and sizes against the footer.
+func readParquet(t *testing.T, data []byte) (columns []parquetColumn, rows int) {
+	t.Helper()
+	if !bytes.HasPrefix(data, []byte(parquetMagic)) || !bytes.HasSuffix(data, []byte(parquetMagic)) {
+		t.Fatal("missing the parquet magic")
+	}
+	footerLength := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footerStart := len(data) - 8 - footerLength
+	footer := (&thriftReader{data: data[footerStart : len(data)-8]}).readStruct()
+	rows = int(footer[3].(int64))
+
+	schema := footer[2].([]interface{})
+	if root := schema[0].(map[int16]interface{}); int(root[5].(int64)) != len(schema)-1 {
+		t.Fatalf("got root %v, expected %d children", root, len(schema)-1)
+	}
+	for _, element := range schema[1:] {
+		element := element.(map[int16]interface{})
+		columns = append(columns, parquetColumn{
+			name:      element[4].(string),
+			physical:  element[1].(int64),
+			optional:  element[3].(int64) == parquetOptional,
+			converted: element[6].(int64),
+		})
+	}
+
+	rowGroups := footer[4].([]interface{})
+	if len(rowGroups) != 1 {
+		t.Fatalf("got %d row groups, expected 1", len(rowGroups))
+	}
+	rowGroup := rowGroups[0].(map[int16]interface{})
+	chunks := rowGroup[1].([]interface{})
+	if len(chunks) != len(columns) || int(rowGroup[3].(int64)) != rows {
+		t.Fatalf("got %d chunks of %d rows, expected %d of %d", len(chunks), rowGroup[3], len(columns), rows)
+	}
+	// the chunks follow each other from the magic to the footer
+	offset, total := int64(len(parquetMagic)), int64(0)
+	for i, chunk := range chunks {
+		chunk := chunk.(map[int16]interface{})
+		meta := chunk[3].(map[int16]interface{})
+		col := &columns[i]
+		size := meta[7].(int64)
+		if chunk[2].(int64) != offset || meta[9].(int64) != offset || meta[6].(int64) != size {
+			t.Fatalf("%s: got chunk at %d, %d of %d bytes, expected at %d", col.name, chunk[2], meta[9], size, offset)
+		}
+		if path := meta[3].([]interface{}); len(path) != 1 || path[0] != col.name || meta[1].(int64) != col.physical ||
+			int(meta[5].(int64)) != rows || meta[4].(int64) != 0 {
+			t.Fatalf("%s: got column metadata %v", col.name, meta)
+		}
+
+		// the page header is followed by the page, which ends the chunk
+		page := &thriftReader{data: data[:offset+size], pos: int(offset)}
+		header := page.readStruct()
+		dataPage := header[5].(map[int16]interface{})
+		pageSize := header[3].(int64)
+		if header[1].(int64) != parquetDataPage || header[2].(int64) != pageSize ||
+			int64(page.pos)+pageSize != offset+size {
+			t.Fatalf("%s: got page header %v of %d bytes, expected the rest of the chunk", col.name, header, page.pos)
+		}
+		if int(dataPage[1].(int64)) != rows || dataPage[2].(int64) != parquetPlain || dataPage[3].(int64) != parquetRLE {
+			t.Fatalf("%s: got data page header %v", col.name, dataPage)
+		}
+		col.values = readPage(t, col, data[page.pos:offset+size], rows)
+		offset += size
+		total += size
+	}
+	if offset != int64(footerStart) || rowGroup[2].(int64) != total {
+		t.Fatalf("chunks end at %d, footer starts at %d", offset, footerStart)
+	}
+	return
+}
+
+// readPage decodes the definition levels, if the column is optional, and the plain values of a
+// data page.
+func readPage(t *testing.T, col *parquetColumn, page []byte, rows int) (values []string) {
+	t.Helper()
+	defined := make([]bool, 0, rows)
+	if col.optional {
+		levelsLength := int(binary.LittleEndian.Uint32(page))
+		levels := &thriftReader{data: page[4 : 4+levelsLength]}
+		for levels.pos < len(levels.data) {
+			header := levels.uvarint()
+			if header&1 != 0 {
+				t.Fatalf("%s: unexpected bit-packed levels", col.name)
+			}
+			value := levels.byte()
+			for i := uint64(0); i < header>>1; i++ {
+				defined = append(defined, value == 1)
+			}
+		}
+		page = page[4+levelsLength:]
+	} else {
+		for i := 0; i < rows; i++ {
+			defined = append(defined, true)
+		}
+	}
+	if len(defined) != rows {
+		t.Fatalf("%s: got %d definition levels, expected %d", col.name, len(defined), rows)
+	}
+	for _, isDefined := range defined {
+		switch {
+		case !isDefined:
+			values = append(values, "")
+		case col.physical == parquetInt64:
+			micros := int64(binary.LittleEndian.Uint64(page))
+			values = append(values, client.MicrosTimestamp(time.UnixMicro(micros)).String())
+			page = page[8:]
+		default:
+			size := int(binary.LittleEndian.Uint32(page))
+			values = append(values, string(page[4:4+size]))
+			page = page[4+size:]
+		}
+	}
+	if len(page) != 0 {
+		t.Fatalf("%s: %d bytes left after the values", col.name, len(page))
+	}
+	return
+}
+
+func TestParquetRoundTrip(t *testing.T) {
+	// the timestamps of the second trade are null, between two defined ones
+	trades := testTrades()
+	third := *trades[0]
+	third.TradeID = "T3"
+	third.Timestamp = client.MicrosTimestamp(time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC))
+	trades = append(trades, &third)
+
+	buf := &bytes.Buffer{}
+	if err := Write(buf, Parquet, trades); err != nil {
+		t.Fatal(err)
+	}
+	columns, rows := readParquet(t, buf.Bytes())
+
+	// the values are the same as in the csv export, with nulls for its empty timestamps
+	buf.Reset()
+	if err := Write(buf, CSV, trades); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows != len(trades) || len(columns) != len(records[0]) {
+		t.Fatalf("got %d rows of %d columns, expected %d of %d", rows, len(columns), len(trades), len(records[0]))
+	}
+	for i, col := range columns {
+		if col.name != records[0][i] {
+			t.Errorf("got column %s, expected %s", col.name, records[0][i])
+		}
+		timestamp := col.name == "Timestamp" || col.name == "TransactTime"
+		if timestamp && (col.physical != parquetInt64 || !col.optional || col.converted != parquetTimestampMicros) {
+			t.Errorf("%s: got type %d optional %t converted %d, expected an optional TIMESTAMP_MICROS",
+				col.name, col.physical, col.optional, col.converted)
+		}
+		if !timestamp && (col.physical != parquetByteArray || col.optional || col.converted != parquetUTF8) {
+			t.Errorf("%s: got type %d optional %t converted %d, expected a required UTF8",
+				col.name, col.physical, col.optional, col.converted)
+		}
+		for row, value := range col.values {
+			if expected := records[row+1][i]; value != expected {
+				t.Errorf("%s row %d: got %q, expected %q", col.name, row, value, expected)
+			}
+		}
+	}
+}
@@ -8,3 +8,5 @@ require (
 	github.com/pkg/errors v0.9.1
 	github.com/shopspring/decimal v1.3.1
 )
+
+require gopkg.in/yaml.v3 v3.0.1
@@ -0,0 +1,269 @@
+package journal
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+
+	"github.com/pintu-crypto/b2b-order/client"
+)
+
+// Mapping names the ledger accounts of the postings of a currency. The names are templates in
+// which {currency} and {subaccount} are replaced by the currency and the sub-account of the
+// trade.
+type Mapping struct {
+	// Asset holds the currency at Pintu.
+	Asset string
+	// Trading balances each currency of a trade against the other, so that every currency
+	// balances on its own.
+	Trading string
+	// Fees is the expense of the fees paid in the currency.
+	Fees string
+	// Overrides replace the names for some currencies or sub-accounts. The first override
+	// matching a posting is used for the names it sets.
+	Overrides []Override
+}
+
+// Override replaces the account names for a currency, a sub-account, or both.
+type Override struct {
+	// Currency and SubAccount select the postings, any if empty.
+	Currency   string
+	SubAccount string
+	Asset      string
+	Trading    string
+	Fees       string
+}
+
+// DefaultMapping returns the default account names.
+func DefaultMapping() Mapping {
+	return Mapping{
+		Asset:   "Assets:Pintu:{currency}",
+		Trading: "Equity:Trading:{currency}",
+		Fees:    "Expenses:Fees:{currency}",
+	}
+}
+
+// Validate returns an error if an account name is missing.
+func (m Mapping) Validate() error {
+	if m.Asset == "" || m.Trading == "" || m.Fees == "" {
+		return errors.New("the asset, trading and fees accounts are required")
+	}
+	for i, override := range m.Overrides {
+		if override.Currency == "" && override.SubAccount == "" {
+			return errors.Errorf("account override %d has neither a currency nor a sub-account", i+1)
+		}
+	}
+	return nil
+}
+
+// account roles
+const (
+	asset = iota
+	trading
+	fees
+)
+
+// account returns the name of the account of the role for the currency and sub-account.
+func (m Mapping) account(role int, currency string, subAccount string) string {
+	names := [...]string{m.Asset, m.Trading, m.Fees}
+	for _, override := range m.Overrides {
+		if (override.Currency != "" && override.Currency != currency) ||
+			(override.SubAccount != "" && override.SubAccount != subAccount) {
+			continue
+		}
+		if name := [...]string{override.Asset, override.Trading, override.Fees}[role]; name != "" {
+			names[role] = name
+			break
+		}
+	}
+	return strings.NewReplacer("{currency}", currency, "{subaccount}", subAccount).Replace(names[role])
+}
+
+// Posting is an amount of a currency posted to an account: a debit if positive, a credit if
+// negative.
+type Posting struct {
+	Account  string
+	Currency string
+	Amount   decimal.Decimal
+}
+
+// Entry is the balanced postings of a trade.
+type Entry struct {
+	Date        client.MicrosTimestamp
+	TradeID     string
+	Description string
+	Postings    []Posting
+}
+
+// Balanced returns an error if the postings of a currency don't sum to zero.
+func (e Entry) Balanced() error {
+	sums := make(map[string]decimal.Decimal)
+	for _, posting := range e.Postings {
+		sums[posting.Currency] = sums[posting.Currency].Add(posting.Amount)
+	}
+	for currency, sum := range sums {
+		if !sum.IsZero() {
+			return errors.Errorf("entry of trade %s is off by %s %s", e.TradeID, sum, currency)
+		}
+	}
+	return nil
+}
+
+// Entry returns the entry of a trade. A buy debits the asset account of the base currency and
+// credits the one of the quote currency, through their trading accounts, and a sale the other
+// way around. A fee is debited to the fees account of its currency, and credited to its asset
+// account.
+func (m Mapping) Entry(trade *client.Trade) (entry Entry, err error) {
+	base, quote, quantity, amount, err := trade.Amounts()
+	if err != nil {
+		return
+	}
+	if trade.Side != client.Side.Buy && trade.Side != client.Side.Sell {
+		err = errors.Errorf("trade %s has an unknown side %s", trade.TradeID, trade.Side)
+		return
+	}
+	entry = Entry{
+		Date:        trade.TransactTime,
+		TradeID:     trade.TradeID,
+		Description: fmt.Sprintf("%s %s %s @ %s", trade.Side, quantity, trade.Symbol, trade.Price),
+	}
+	if trade.Side == client.Side.Sell {
+		quantity, amount = quantity.Neg(), amount.Neg()
+	}
+	entry.Postings = append(entry.Postings,
+		Posting{Account: m.account(asset, base, trade.SubAccount), Currency: base, Amount: quantity},
+		Posting{Account: m.account(trading, base, trade.SubAccount), Currency: base, Amount: quantity.Neg()},
+		Posting{Account: m.account(trading, quote, trade.SubAccount), Currency: quote, Amount: amount},
+		Posting{Account: m.account(asset, quote, trade.SubAccount), Currency: quote, Amount: amount.Neg()})
+	if !trade.Fee.IsZero() {
+		feeCurrency := trade.FeeCurrency
+		if feeCurrency == "" {
+			feeCurrency = quote
+		}
+		entry.Postings = append(entry.Postings,
+			Posting{Account: m.account(fees, feeCurrency, trade.SubAccount), Currency: feeCurrency, Amount: trade.Fee},
+			Posting{Account: m.account(asset, feeCurrency, trade.SubAccount), Currency: feeCurrency, Amount: trade.Fee.Neg()})
+	}
+	err = entry.Balanced()
+	return
+}
+
+// Entries returns the entries of the trades, in the order they happened, from the last update
+// of each trade and without the trades canceled since.
+func (m Mapping) Entries(trades []*client.Trade) (entries []Entry, err error) {
+	for _, trade := range client.LatestTrades(trades) {
+		var entry Entry
+		if entry, err = m.Entry(trade); err != nil {
+			return
+		}
+		entries = append(entries, entry)
+	}
+	return
+}
+
+// Format is the file format of a journal.
+type Format string
+
+// The supported journal formats.
+const (
+	// CSV has a line per posting, with the debit or credit in its own column.
+	CSV Format = "csv"
+	// Ledger is the plain text format of ledger and hledger.
+	Ledger Format = "ledger"
+)
+
+// ParseFormat parses the name of a journal format.
+func ParseFormat(value string) (format Format, err error) {
+	switch format = Format(value); format {
+	case CSV, Ledger:
+	default:
+		err = errors.Errorf("unknown journal format '%s', expected csv or ledger", value)
+	}
+	return
+}
+
+// Write writes the entries in the given format.
+func Write(w io.Writer, format Format, entries []Entry) (err error) {
+	switch format {
+	case CSV:
+		err = writeCSV(w, entries)
+	case Ledger:
+		err = writeLedger(w, entries)
+	default:
+		_, err = ParseFormat(string(format))
+	}
+	return
+}
+
+func writeCSV(w io.Writer, entries []Entry) error {
+	out := csv.NewWriter(w)
+	_ = out.Write([]string{"Date", "TradeID", "Description", "Account", "Currency", "Debit", "Credit"})
+	for _, entry := range entries {
+		for _, posting := range entry.Postings {
+			debit, credit := posting.Amount.String(), ""
+			if posting.Amount.IsNegative() {
+				debit, credit = "", posting.Amount.Neg().String()
+			}
+			_ = out.Write([]string{entry.Date.String(), entry.TradeID, entry.Description, posting.Account,
+				posting.Currency, debit, credit})
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func writeLedger(w io.Writer, entries []Entry) error {
+	out := bufio.NewWriter(w)
+	for _, account := range accounts(entries) {
+		fmt.Fprintf(out, "account %s\n", account)
+	}
+	for _, entry := range entries {
+		fmt.Fprintf(out, "\n%s * %s\n    ; trade: %s\n", time.Time(entry.Date).UTC().Format("2006-01-02"),
+			entry.Description, entry.TradeID)
+		// align the amounts after the longest account name
+		width := 0
+		for _, posting := range entry.Postings {
+			if len(posting.Account) > width {
+				width = len(posting.Account)
+			}
+		}
+		for _, posting := range entry.Postings {
+			fmt.Fprintf(out, "    %-*s  %s %s\n", width, posting.Account, posting.Amount, commodity(posting.Currency))
+		}
+	}
+	return out.Flush()
+}
+
+// commodity returns the currency as a ledger commodity, quoted unless it's only letters.
+func commodity(currency string) string {
+	for _, c := range currency {
+		if !unicode.IsLetter(c) {
+			return strconv.Quote(currency)
+		}
+	}
+	return currency
+}
+
+// accounts returns the names of the accounts the entries post to, sorted.
+func accounts(entries []Entry) (names []string) {
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		for _, posting := range entry.Postings {
+			if !seen[posting.Account] {
+				seen[posting.Account] = true
+				names = append(names, posting.Account)
+			}
+		}
+	}
+	sort.Strings(names)
+	return
+}
@@ -0,0 +1,173 @@
+package journal
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/pintu-crypto/b2b-order/client"
+)
+
+// trade returns a DOGE-USDT trade on the given day of January 2026.
+func trade(id string, day int, side client.SideEnum, quantity string, price string) *client.Trade {
+	return &client.Trade{
+		TradeID:      id,
+		Symbol:       "DOGE-USDT",
+		Side:         side,
+		TransactTime: client.MicrosTimestamp(time.Date(2026, 1, day, 12, 0, 0, 0, time.UTC)),
+		Quantity:     decimal.RequireFromString(quantity),
+		Price:        decimal.RequireFromString(price),
+		Currency:     "DOGE",
+	}
+}
+
+// postings returns the postings as "account amount currency" lines.
+func postings(entry Entry) (lines []string) {
+	for _, posting := range entry.Postings {
+		lines = append(lines, posting.Account+" "+posting.Amount.String()+" "+posting.Currency)
+	}
+	return
+}
+
+func TestEntry(t *testing.T) {
+	buy := trade("T1", 1, client.Side.Buy, "100", "0.10")
+	buy.Fee, buy.FeeCurrency = decimal.RequireFromString("0.01"), "USDT"
+	sell := trade("T2", 2, client.Side.Sell, "50", "0.20")
+	sell.Fee, sell.FeeCurrency = decimal.RequireFromString("0.001"), "BNB"
+
+	tests := []struct {
+		trade    *client.Trade
+		expected []string
+	}{
+		{buy, []string{
+			"Assets:Pintu:DOGE 100 DOGE",
+			"Equity:Trading:DOGE -100 DOGE",
+			"Equity:Trading:USDT 10 USDT",
+			"Assets:Pintu:USDT -10 USDT",
+			"Expenses:Fees:USDT 0.01 USDT",
+			"Assets:Pintu:USDT -0.01 USDT",
+		}},
+		{sell, []string{
+			"Assets:Pintu:DOGE -50 DOGE",
+			"Equity:Trading:DOGE 50 DOGE",
+			"Equity:Trading:USDT -10 USDT",
+			"Assets:Pintu:USDT 10 USDT",
+			"Expenses:Fees:BNB 0.001 BNB",
+			"Assets:Pintu:BNB -0.001 BNB",
+		}},
+	}
+	for _, test := range tests {
+		entry, err := DefaultMapping().Entry(test.trade)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(postings(entry), "\n"); got != strings.Join(test.expected, "\n") {
+			t.Errorf("%s: got postings\n%s\nexpected\n%s", test.trade.TradeID, got, strings.Join(test.expected, "\n"))
+		}
+	}
+
+	unknown := trade("T3", 3, client.Side.Buy, "1", "0.1")
+	unknown.Side = 0
+	if _, err := DefaultMapping().Entry(unknown); err == nil {
+		t.Error("got an entry for a trade without a side")
+	}
+
+	// a quantity in the quote currency without an amount or a price has no base quantity
+	unpriced := trade("T4", 4, client.Side.Buy, "20", "0")
+	unpriced.Currency = "USDT"
+	if _, err := DefaultMapping().Entry(unpriced); err == nil {
+		t.Error("got an entry for a trade without a base quantity")
+	}
+}
+
+func TestMappingOverrides(t *testing.T) {
+	mapping := DefaultMapping()
+	mapping.Overrides = []Override{
+		{SubAccount: "desk", Currency: "USDT", Asset: "Assets:Desk:Stable"},
+		{SubAccount: "desk", Asset: "Assets:Desk:{currency}", Fees: "Expenses:Desk"},
+	}
+	if err := mapping.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	buy := trade("T1", 1, client.Side.Buy, "100", "0.10")
+	buy.SubAccount = "desk"
+	buy.Fee = decimal.RequireFromString("0.01")
+	entry, err := mapping.Entry(buy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"Assets:Desk:DOGE 100 DOGE",
+		"Equity:Trading:DOGE -100 DOGE",
+		"Equity:Trading:USDT 10 USDT",
+		"Assets:Desk:Stable -10 USDT",
+		"Expenses:Desk 0.01 USDT",
+		"Assets:Desk:Stable -0.01 USDT",
+	}
+	if got := strings.Join(postings(entry), "\n"); got != strings.Join(expected, "\n") {
+		t.Errorf("got postings\n%s\nexpected\n%s", got, strings.Join(expected, "\n"))
+	}
+
+	mapping.Overrides = append(mapping.Overrides, Override{Asset: "Assets:Other"})
+	if err := mapping.Validate(); err == nil {
+		t.Error("validated an override matching every posting")
+	}
+}
+
+func TestEntries(t *testing.T) {
+	buy := trade("T1", 1, client.Side.Buy, "100", "0.10")
+	canceled := *buy
+	canceled.TradeStatus = client.TradeStatus.Canceled
+	entries, err := DefaultMapping().Entries([]*client.Trade{
+		trade("T2", 2, client.Side.Sell, "50", "0.20"), buy, &canceled, trade("T3", 3, client.Side.Buy, "1", "0.3"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].TradeID != "T2" || entries[1].TradeID != "T3" {
+		t.Errorf("got entries %+v, expected T2 and T3", entries)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	buy := trade("T1", 1, client.Side.Buy, "100", "0.10")
+	buy.Fee, buy.FeeCurrency = decimal.RequireFromString("1"), "1INCH"
+	entries, err := DefaultMapping().Entries([]*client.Trade{buy})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err = Write(buf, CSV, entries); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 7 || lines[0] != "Date,TradeID,Description,Account,Currency,Debit,Credit" {
+		t.Fatalf("got %s, expected a header and 6 postings", buf)
+	}
+	if expected := "2026-01-01T12:00:00.000000Z,T1,Buy 100 DOGE-USDT @ 0.1,Assets:Pintu:USDT,USDT,,10"; lines[4] != expected {
+		t.Errorf("got %s, expected %s", lines[4], expected)
+	}
+
+	buf.Reset()
+	if err = Write(buf, Ledger, entries); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"account Assets:Pintu:1INCH\n",
+		"\n2026-01-01 * Buy 100 DOGE-USDT @ 0.1\n    ; trade: T1\n",
+		"    Assets:Pintu:DOGE    100 DOGE\n",
+		"    Expenses:Fees:1INCH  1 \"1INCH\"\n",
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("%q not in\n%s", expected, buf)
+		}
+	}
+
+	if _, err = ParseFormat("qif"); err == nil {
+		t.Error("parsed an unknown format")
+	}
+}
@@ -0,0 +1,223 @@
+package order
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/pintu-crypto/b2b-order/client"
+)
+
+// balanceReservationTTL is how long an order is counted against the balance before its first
+// execution report, in case the order never reaches the server.
+const balanceReservationTTL = time.Minute
+
+// AccountBalance is the balance of a currency, adjusted by the orders since it was received.
+type AccountBalance struct {
+	client.Balance
+	// Available is the AvailableAmount less what the orders committed since the balance was
+	// received.
+	Available decimal.Decimal
+}
+
+// commitment is the amount of a currency an order commits to: its filled and remaining quantity
+// for a sell, and their value in the quote currency for a buy.
+type commitment struct {
+	currency string
+	amount   decimal.Decimal
+}
+
+// reservation is the commitment of an order sent but not yet reported.
+type reservation struct {
+	commitment
+	expires time.Time
+}
+
+// Balances keeps the balances of an account from the Balance stream, adjusted optimistically by
+// the open orders: the balances received already account for the orders at that time, so what
+// the orders committed since then is taken off the available amount until the next update. It
+// is safe for concurrent use, and it outlives the handlers of the individual connections.
+type Balances struct {
+	account string
+	// reject makes Reserve reject the orders exceeding the available balance
+	reject bool
+
+	mu           sync.Mutex
+	balances     map[string]*client.Balance
+	baselines    map[string]decimal.Decimal
+	committed    map[string]decimal.Decimal
+	orders       map[string]commitment
+	reservations map[string]reservation
+}
+
+// NewBalances returns the balances of the given account. If reject is set, the orders exceeding
+// the available balance are rejected before they are sent.
+func NewBalances(account string, reject bool) *Balances {
+	return &Balances{
+		account:      account,
+		reject:       reject,
+		balances:     make(map[string]*client.Balance),
+		baselines:    make(map[string]decimal.Decimal),
+		committed:    make(map[string]decimal.Decimal),
+		orders:       make(map[string]commitment),
+		reservations: make(map[string]reservation),
+	}
+}
+
+// Stream returns the Balance stream, for the handler to keep the balances up to date.
+func (b *Balances) Stream() Stream {
+	return Stream{
+		Name: "Balance",
+		New:  func() interface{} { return &client.Balance{} },
+		Handle: func(reqID int64, value interface{}) error {
+			b.SetBalance(value.(*client.Balance))
+			return nil
+		},
+	}
+}
+
+// SetBalance records a balance update from the server.
+func (b *Balances) SetBalance(balance *client.Balance) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.balances[balance.Currency] = balance
+	b.baselines[balance.Currency] = b.committed[balance.Currency]
+}
+
+// AddExecutionReport updates the commitment of the order of the report.
+func (b *Balances) AddExecutionReport(report *client.ExecutionReport) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	// the order was reserved when it was sent, and is now tracked by its ID
+	if reserved, ok := b.reservations[report.ClOrdID]; ok {
+		b.commit(reserved.commitment, true)
+		delete(b.reservations, report.ClOrdID)
+	}
+	if previous, ok := b.orders[report.OrderID]; ok {
+		b.commit(previous, true)
+	}
+	current := orderCommitment(report.Symbol, report.Currency, report.Side,
+		report.LeavesQty, report.Price, report.CumQty, report.CumAmt)
+	b.commit(current, false)
+	switch report.OrdStatus {
+	case client.OrdStatus.Filled, client.OrdStatus.Canceled,
+		client.OrdStatus.Rejected, client.OrdStatus.DoneForDay:
+		// what was filled stays committed until the next balance update
+		delete(b.orders, report.OrderID)
+	default:
+		b.orders[report.OrderID] = current
+	}
+}
+
+// Reserve counts a new order against the balance until its first execution report. If the
+// balances reject orders, it returns an error when the order exceeds the available balance of a
+// currency with a known balance.
+func (b *Balances) Reserve(order *client.NewOrderSingle) (err error) {
+	price := decimal.Zero
+	if order.Price != nil {
+		price = *order.Price
+	}
+	c := orderCommitment(order.Symbol, order.Currency, order.Side, order.OrderQty, price, decimal.Zero, decimal.Zero)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.expire(time.Now())
+	if b.reject && c.amount.IsPositive() {
+		if _, ok := b.balances[c.currency]; ok {
+			if available := b.available(c.currency); c.amount.GreaterThan(available) {
+				return fmt.Errorf("insufficient %s balance, %s available", c.currency, available)
+			}
+		}
+	}
+	b.reservations[order.ClOrdID] = reservation{commitment: c, expires: time.Now().Add(balanceReservationTTL)}
+	b.commit(c, false)
+	return
+}
+
+// Release drops the reservation of an order that was never accepted by the server, such as one
+// rejected with an error or that failed to send, as it won't get an execution report.
+func (b *Balances) Release(clOrdID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if reserved, ok := b.reservations[clOrdID]; ok {
+		b.commit(reserved.commitment, true)
+		delete(b.reservations, clOrdID)
+	}
+}
+
+// List returns the balances, by currency.
+func (b *Balances) List() (result []AccountBalance) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.expire(time.Now())
+	result = make([]AccountBalance, 0, len(b.balances))
+	for currency, balance := range b.balances {
+		result = append(result, AccountBalance{Balance: *balance, Available: b.available(currency)})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Currency < result[j].Currency
+	})
+	return
+}
+
+// ServeHTTP responds with the balances as JSON.
+func (b *Balances) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(b.List())
+}
+
+// available returns the available amount of the currency, less what was committed since the
+// balance was received.
+func (b *Balances) available(currency string) decimal.Decimal {
+	balance := b.balances[currency]
+	return balance.AvailableAmount.Sub(b.committed[currency].Sub(b.baselines[currency]))
+}
+
+// commit adds the commitment to the total of its currency, or removes it.
+func (b *Balances) commit(c commitment, remove bool) {
+	if c.currency == "" {
+		return
+	}
+	if remove {
+		b.committed[c.currency] = b.committed[c.currency].Sub(c.amount)
+	} else {
+		b.committed[c.currency] = b.committed[c.currency].Add(c.amount)
+	}
+}
+
+// expire releases the reservations of the orders that were never reported.
+func (b *Balances) expire(now time.Time) {
+	for clOrdID, reserved := range b.reservations {
+		if now.After(reserved.expires) {
+			b.commit(reserved.commitment, true)
+			delete(b.reservations, clOrdID)
+		}
+	}
+}
+
+// orderCommitment returns the commitment of an order on a BASE-QUOTE symbol. A buy of a base
+// quantity without a price, such as a market order, only commits what was filled, and a sell
+// of a quote quantity commits nothing as its base quantity isn't known.
+func orderCommitment(symbol string, currency string, side client.SideEnum,
+	leavesQty decimal.Decimal, price decimal.Decimal, cumQty decimal.Decimal, cumAmt decimal.Decimal) (c commitment) {
+	base, quote, ok := strings.Cut(symbol, "-")
+	if !ok {
+		return
+	}
+	inQuote := currency != "" && currency != base
+	switch {
+	case side == client.Side.Sell && !inQuote:
+		c = commitment{currency: base, amount: leavesQty.Add(cumQty)}
+	case side == client.Side.Buy && inQuote:
+		c = commitment{currency: quote, amount: leavesQty.Add(cumQty)}
+	case side == client.Side.Buy:
+		c = commitment{currency: quote, amount: leavesQty.Mul(price).Add(cumAmt)}
+	}
+	return
+}
@@ -0,0 +1,152 @@
+package order
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/pintu-crypto/b2b-order/client"
+)
+
+func TestBalances(t *testing.T) {
+	b := NewBalances("test", true)
+	b.SetBalance(&client.Balance{Currency: "DOGE", Amount: decimal.NewFromInt(1000), AvailableAmount: decimal.NewFromInt(1000)})
+	b.SetBalance(&client.Balance{Currency: "USDT", Amount: decimal.NewFromInt(100), AvailableAmount: decimal.NewFromInt(100)})
+	available := func(currency string) string {
+		for _, balance := range b.List() {
+			if balance.Currency == currency {
+				return balance.Available.String()
+			}
+		}
+		return ""
+	}
+	sell := func(clOrdID string, qty int64) *client.NewOrderSingle {
+		return &client.NewOrderSingle{Symbol: "DOGE-USDT", ClOrdID: clOrdID, Side: client.Side.Sell, OrderQty: decimal.NewFromInt(qty)}
+	}
+
+	// a sell reserves its quantity as soon as it's sent
+	if err := b.Reserve(sell("C1", 600)); err != nil {
+		t.Fatal(err)
+	}
+	if got := available("DOGE"); got != "400" {
+		t.Errorf("got %s DOGE available, expected 400", got)
+	}
+	if err := b.Reserve(sell("C2", 500)); err == nil || err.Error() != "insufficient DOGE balance, 400 available" {
+		t.Errorf("got %v, expected an insufficient balance", err)
+	}
+
+	// a partial fill keeps the quantity committed, and a cancel releases what's left
+	report := &client.ExecutionReport{Symbol: "DOGE-USDT", OrderID: "O1", ClOrdID: "C1", Side: client.Side.Sell,
+		OrdStatus: client.OrdStatus.PartiallyFilled, LeavesQty: decimal.NewFromInt(400), CumQty: decimal.NewFromInt(200)}
+	b.AddExecutionReport(report)
+	if got := available("DOGE"); got != "400" {
+		t.Errorf("got %s DOGE available after the fill, expected 400", got)
+	}
+	b.AddExecutionReport(&client.ExecutionReport{Symbol: "DOGE-USDT", OrderID: "O1", ClOrdID: "C3", OrigClOrdID: "C1",
+		Side: client.Side.Sell, OrdStatus: client.OrdStatus.Canceled, CumQty: decimal.NewFromInt(200)})
+	if got := available("DOGE"); got != "800" {
+		t.Errorf("got %s DOGE available after the cancel, expected 800", got)
+	}
+
+	// the next balance update accounts for the fill
+	b.SetBalance(&client.Balance{Currency: "DOGE", Amount: decimal.NewFromInt(800), AvailableAmount: decimal.NewFromInt(800)})
+	if got := available("DOGE"); got != "800" {
+		t.Errorf("got %s DOGE available after the update, expected 800", got)
+	}
+
+	// a limit buy commits its value in the quote currency, a market buy only once filled
+	price := decimal.RequireFromString("0.2")
+	if err := b.Reserve(&client.NewOrderSingle{Symbol: "DOGE-USDT", ClOrdID: "C4", Side: client.Side.Buy,
+		OrderQty: decimal.NewFromInt(600), Price: &price}); err == nil {
+		t.Error("reserved 120 USDT out of 100")
+	}
+	if err := b.Reserve(&client.NewOrderSingle{Symbol: "DOGE-USDT", ClOrdID: "C5", Side: client.Side.Buy,
+		OrderQty: decimal.NewFromInt(400), Price: &price}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Reserve(&client.NewOrderSingle{Symbol: "DOGE-USDT", ClOrdID: "C6", Side: client.Side.Buy,
+		OrderQty: decimal.NewFromInt(1000)}); err != nil {
+		t.Fatal(err)
+	}
+	if got := available("USDT"); got != "20" {
+		t.Errorf("got %s USDT available, expected 20", got)
+	}
+
+	// unknown balances aren't checked
+	if err := b.Reserve(&client.NewOrderSingle{Symbol: "BTC-USDT", ClOrdID: "C7", Side: client.Side.Sell,
+		OrderQty: decimal.NewFromInt(1)}); err != nil {
+		t.Error(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	b.ServeHTTP(recorder, httptest.NewRequest("GET", "/balances?account=test", nil))
+	var served []AccountBalance
+	if err := json.Unmarshal(recorder.Body.Bytes(), &served); err != nil {
+		t.Fatal(err)
+	}
+	if len(served) != 2 || served[0].Currency != "DOGE" || served[1].Available.String() != "20" {
+		t.Errorf("got %+v, expected DOGE and USDT", served)
+	}
+}
+
+func TestHandlerBalances(t *testing.T) {
+	balances := NewBalances("test", false)
+	h := newTestHandler(t, Config{Account: "test", Balances: balances})
+	defer h.Close()
+
+	h.incoming <- []byte(`{"reqid":1,"seq":1,"type":"Balance","data":[
+		{"Currency":"DOGE","Amount":"1000","AvailableAmount":"900"}]}`)
+	h.incoming <- []byte(`{"reqid":1,"seq":2,"type":"ExecutionReport","data":[
+		{"Timestamp":"2024-01-01T00:00:01.000000Z","Symbol":"DOGE-USDT","OrderID":"O1","ExecID":"E1",
+		"Side":"Sell","ExecType":"New","OrdStatus":"New","LeavesQty":"300","CumQty":"0"}]}`)
+	h.sync()
+	list := balances.List()
+	if len(list) != 1 || list[0].Available.String() != "600" {
+		t.Errorf("got %+v, expected 600 DOGE available", list)
+	}
+}
+
+func TestHandlerBalancesRelease(t *testing.T) {
+	balances := NewBalances("test", true)
+	h := newTestHandler(t, Config{Account: "test", Balances: balances})
+	defer h.Close()
+	available := func() string {
+		list := balances.List()
+		if len(list) != 1 {
+			return ""
+		}
+		return list[0].Available.String()
+	}
+	sell := func(clOrdID string) *client.NewOrderSingle {
+		order := testOrder(clOrdID)
+		order.Side = client.Side.Sell
+		order.OrderQty = decimal.NewFromInt(600)
+		return order
+	}
+
+	h.incoming <- []byte(`{"reqid":1,"seq":1,"type":"Balance","data":[
+		{"Currency":"DOGE","Amount":"1000","AvailableAmount":"1000"}]}`)
+	h.sync()
+
+	// an order rejected with an error never gets an execution report, so its reservation is released
+	response := h.placeOrder(t, sell("C1"))
+	h.expectSent(t, "NewOrderSingle")
+	if got := available(); got != "400" {
+		t.Errorf("got %s DOGE available, expected 400 reserved for the order", got)
+	}
+	h.incoming <- []byte(`{"reqid":2,"type":"error","error":{"code":400,"msg":"invalid order"}}`)
+	expectResponse(t, response, "rejected(invalid order)")
+	if got := available(); got != "1000" {
+		t.Errorf("got %s DOGE available after the error, expected 1000", got)
+	}
+
+	// so is the reservation of an order that failed to send
+	close(h.outgoing)
+	response = h.placeOrder(t, sell("C2"))
+	expectResponse(t, response, "rejected(panic: send on closed channel)")
+	if got := available(); got != "1000" {
+		t.Errorf("got %s DOGE available after the send failure, expected 1000", got)
+	}
+}
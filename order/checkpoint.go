@@ -0,0 +1,106 @@
+package order
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/pintu-crypto/b2b-order/client"
+)
+
+// Checkpoint records the timestamp of the last message processed on each stream, so that
+// the streams can be resumed from that point after a reconnect or restart. It is safe for
+// concurrent use, and it outlives the handlers of the individual connections.
+type Checkpoint struct {
+	mu      sync.Mutex
+	path    string
+	streams map[string]client.MicrosTimestamp
+	dirty   bool
+}
+
+// LoadCheckpoint reads the checkpoint from the given file. A missing file returns an empty
+// checkpoint, and an empty path returns a checkpoint that is only kept in memory.
+func LoadCheckpoint(path string) (result *Checkpoint, err error) {
+	result = &Checkpoint{
+		path:    path,
+		streams: make(map[string]client.MicrosTimestamp),
+	}
+	if path == "" {
+		return
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		err = nil
+		return
+	}
+	if err != nil {
+		err = errors.Wrapf(err, "unable to read checkpoint %s", path)
+		return
+	}
+	if err = json.Unmarshal(data, &result.streams); err != nil {
+		err = errors.Wrapf(err, "unable to decode checkpoint %s", path)
+		return
+	}
+	return
+}
+
+// StartDate returns the timestamp to resume the given stream from, or nil if the stream
+// hasn't been checkpointed yet.
+func (c *Checkpoint) StartDate(stream string) *client.MicrosTimestamp {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ts, ok := c.streams[stream]
+	if !ok {
+		return nil
+	}
+	return &ts
+}
+
+// Update advances the checkpoint of the given stream. Timestamps older than the current
+// checkpoint are ignored, so replayed messages never move the checkpoint back.
+func (c *Checkpoint) Update(stream string, ts client.MicrosTimestamp) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if current, ok := c.streams[stream]; ok && !time.Time(ts).After(time.Time(current)) {
+		return
+	}
+	c.streams[stream] = ts
+	c.dirty = true
+}
+
+// Flush writes the checkpoint to its file if it changed since the last flush.
+func (c *Checkpoint) Flush() (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.path == "" || !c.dirty {
+		return
+	}
+	data, err := json.Marshal(c.streams)
+	if err != nil {
+		return errors.Wrap(err, "unable to encode checkpoint")
+	}
+	// write to a temporary file first so that a crash never leaves a partial checkpoint
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*")
+	if err != nil {
+		return errors.Wrap(err, "unable to write checkpoint")
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return errors.Wrap(err, "unable to write checkpoint")
+	}
+	if err = tmp.Close(); err != nil {
+		return errors.Wrap(err, "unable to write checkpoint")
+	}
+	if err = os.Rename(tmp.Name(), c.path); err != nil {
+		return errors.Wrap(err, "unable to write checkpoint")
+	}
+	c.dirty = false
+	return
+}
@@ -0,0 +1,81 @@
+package order
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pintu-crypto/b2b-order/client"
+)
+
+func TestCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	at := func(seconds int) client.MicrosTimestamp {
+		return client.MicrosTimestamp(time.Date(2024, 1, 1, 0, 0, seconds, 0, time.UTC))
+	}
+
+	// a missing file is an empty checkpoint
+	checkpoint, err := LoadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checkpoint.StartDate("Trade") != nil {
+		t.Error("got a start date from an empty checkpoint")
+	}
+
+	// replayed messages don't move the checkpoint back
+	checkpoint.Update("Trade", at(2))
+	checkpoint.Update("Trade", at(1))
+	checkpoint.Update("ExecutionReport", at(3))
+	if startDate := checkpoint.StartDate("Trade"); startDate == nil || *startDate != at(2) {
+		t.Errorf("got %v, expected the latest trade", startDate)
+	}
+
+	// the streams are resumed from the flushed checkpoint
+	if err = checkpoint.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for stream, expected := range map[string]client.MicrosTimestamp{"Trade": at(2), "ExecutionReport": at(3)} {
+		if startDate := loaded.StartDate(stream); startDate == nil || !time.Time(*startDate).Equal(time.Time(expected)) {
+			t.Errorf("got %s start date %v after reload, expected %s", stream, startDate, expected)
+		}
+	}
+
+	// no temporary files are left behind
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("got %d files, expected only the checkpoint", len(entries))
+	}
+}
+
+func TestCheckpointCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	if err := os.WriteFile(path, []byte(`{"Trade":"2024-01-01T00:00:0`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadCheckpoint(path); err == nil {
+		t.Error("loaded a corrupt checkpoint")
+	}
+}
+
+func TestCheckpointInMemory(t *testing.T) {
+	checkpoint, err := LoadCheckpoint("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkpoint.Update("Trade", client.MicrosTimestamp(time.Now()))
+	if err = checkpoint.Flush(); err != nil {
+		t.Errorf("got error %s flushing an in-memory checkpoint", err)
+	}
+	if checkpoint.StartDate("Trade") == nil {
+		t.Error("the in-memory checkpoint lost its stream")
+	}
+}
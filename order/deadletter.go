@@ -0,0 +1,148 @@
+package order
+
+import (
+	"bufio"
+	"encoding/json"
+	"expvar"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/pintu-crypto/b2b-order/client"
+)
+
+// defaultDeadLetterCapacity is the number of dead letters kept in memory.
+const defaultDeadLetterCapacity = 1000
+
+// deadLetterMetrics counts the dead letters per account.
+var deadLetterMetrics = expvar.NewMap("dead_letters")
+
+// DeadLetter is a message, or a single element of its data, that couldn't be processed.
+type DeadLetter struct {
+	Time    client.MicrosTimestamp
+	Account string
+	Type    string
+	ReqID   int64
+	Seq     int64
+	Data    json.RawMessage
+	Error   string
+}
+
+// DeadLetters keeps the most recent dead letters of an account in memory, and appends every
+// dead letter to a JSON lines file if it has one. It is safe for concurrent use, and it
+// outlives the handlers of the individual connections.
+type DeadLetters struct {
+	mu       sync.Mutex
+	account  string
+	letters  []DeadLetter
+	capacity int
+	file     *os.File
+}
+
+// NewDeadLetters returns a dead letter store for the given account that is only kept in memory.
+func NewDeadLetters(account string) *DeadLetters {
+	return &DeadLetters{
+		account:  account,
+		capacity: defaultDeadLetterCapacity,
+	}
+}
+
+// OpenDeadLetters returns a dead letter store for the given account, appending to the given
+// file and starting with the most recent dead letters already in it. An empty path returns a
+// store that is only kept in memory.
+func OpenDeadLetters(account string, path string) (result *DeadLetters, err error) {
+	result = NewDeadLetters(account)
+	if path == "" {
+		return
+	}
+	if result.file, err = os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0600); err != nil {
+		err = errors.Wrapf(err, "unable to open dead letters %s", path)
+		return
+	}
+	scanner := bufio.NewScanner(result.file)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var letter DeadLetter
+		if json.Unmarshal(scanner.Bytes(), &letter) == nil {
+			result.keep(letter)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		err = errors.Wrapf(err, "unable to read dead letters %s", path)
+	}
+	return
+}
+
+// Add records a dead letter, dropping the oldest one from memory if the store is full.
+func (d *DeadLetters) Add(response *client.Response, data json.RawMessage, err error) {
+	letter := DeadLetter{
+		Time:    client.MicrosTimestamp(time.Now()),
+		Account: d.account,
+		Data:    data,
+		Error:   err.Error(),
+	}
+	if response != nil {
+		letter.Type = response.Type
+		letter.ReqID = response.ReqID
+		letter.Seq = response.Seq
+	}
+	if !json.Valid(letter.Data) {
+		// keep frames that aren't JSON at all as a string
+		letter.Data, _ = json.Marshal(string(data))
+	}
+	log.Printf("account %s dead letter %s seq %d: %s: %s", d.account, letter.Type, letter.Seq, letter.Error, data)
+	deadLetterMetrics.Add(d.account, 1)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.keep(letter)
+	if d.file == nil {
+		return
+	}
+	line, encodeErr := json.Marshal(letter)
+	if encodeErr == nil {
+		_, encodeErr = d.file.Write(append(line, '\n'))
+	}
+	if encodeErr != nil {
+		log.Printf("account %s unable to write dead letter: %s", d.account, encodeErr)
+	}
+}
+
+// keep adds a dead letter to the ones in memory.
+func (d *DeadLetters) keep(letter DeadLetter) {
+	if len(d.letters) >= d.capacity {
+		d.letters = d.letters[1:]
+	}
+	d.letters = append(d.letters, letter)
+}
+
+// List returns the dead letters in memory, oldest first.
+func (d *DeadLetters) List() (result []DeadLetter) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	result = make([]DeadLetter, len(d.letters))
+	copy(result, d.letters)
+	return
+}
+
+// Close closes the dead letters file, if any.
+func (d *DeadLetters) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.file == nil {
+		return nil
+	}
+	err := d.file.Close()
+	d.file = nil
+	return err
+}
+
+// ServeHTTP responds with the dead letters in memory as JSON, oldest first.
+func (d *DeadLetters) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(d.List())
+}
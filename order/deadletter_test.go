@@ -0,0 +1,45 @@
+package order
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/pintu-crypto/b2b-order/client"
+)
+
+func TestDeadLettersFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.deadletters.jsonl")
+	deadLetters, err := OpenDeadLetters("test", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadLetters.Add(&client.Response{Type: "Trade", ReqID: 1, Seq: 7}, json.RawMessage(`{"Quantity":"lots"}`),
+		errors.New("unable to decode trade"))
+	deadLetters.Add(nil, json.RawMessage(`not json`), errors.New("unable to decode response"))
+	if err = deadLetters.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the dead letters are loaded again on restart
+	deadLetters, err = OpenDeadLetters("test", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer deadLetters.Close()
+	letters := deadLetters.List()
+	if len(letters) != 2 || letters[0].Type != "Trade" || letters[0].Seq != 7 ||
+		string(letters[1].Data) != `"not json"` {
+		t.Fatalf("got dead letters %+v", letters)
+	}
+
+	recorder := httptest.NewRecorder()
+	deadLetters.ServeHTTP(recorder, httptest.NewRequest("GET", "/deadletters?account=test", nil))
+	var served []DeadLetter
+	if err = json.Unmarshal(recorder.Body.Bytes(), &served); err != nil || len(served) != 2 {
+		t.Errorf("served %s, %v", recorder.Body, err)
+	}
+}
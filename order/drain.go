@@ -0,0 +1,118 @@
+package order
+
+import (
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/pintu-crypto/b2b-order/client"
+	"github.com/pintu-crypto/b2b-order/endpoint"
+)
+
+// drainPollInterval is how often the pending orders are checked while draining.
+const drainPollInterval = 100 * time.Millisecond
+
+// DrainOptions controls how the pending orders are drained on shutdown.
+type DrainOptions struct {
+	// Timeout is how long to wait for pending orders to reach a terminal status.
+	Timeout time.Duration
+	// CancelRemaining sends a cancel for every order that is still pending after the timeout.
+	CancelRemaining bool
+	// CancelTimeout is how long to wait for the cancels to complete.
+	CancelTimeout time.Duration
+}
+
+// Drain stops taking new order requests and waits for the pending orders to reach a terminal
+// status, optionally cancelling the orders still working after the timeout. Any orders still
+// pending after that are answered with an unknown outcome, so that no caller is left waiting.
+// It then flushes the checkpoint and returns the number of orders with an unknown outcome.
+func (h *Handler) Drain(options DrainOptions) (unknown int) {
+	h.command(func() {
+		h.draining = true
+	})
+	remaining := h.waitPending(options.Timeout)
+	if remaining > 0 && options.CancelRemaining {
+		log.Printf("account %s cancelling %d pending orders", h.config.Account, remaining)
+		h.command(h.cancelPending)
+		remaining = h.waitPending(options.CancelTimeout)
+	}
+	if remaining > 0 {
+		h.command(func() {
+			unknown = h.abandonPending()
+		})
+	}
+	if err := h.checkpoint.Flush(); err != nil {
+		log.Printf("error flushing checkpoint " + err.Error())
+	}
+	return
+}
+
+// command runs the given function on the handler goroutine and waits for it to complete. If
+// the handler has stopped, nothing else touches its state, so the function is run directly.
+func (h *Handler) command(f func()) {
+	done := make(chan interface{})
+	select {
+	case h.commands <- func() {
+		f()
+		close(done)
+	}:
+		<-done
+	case <-h.doneC:
+		f()
+	}
+}
+
+// waitPending waits up to the timeout for the pending orders to complete and returns the
+// number still pending.
+func (h *Handler) waitPending(timeout time.Duration) (remaining int) {
+	deadline := time.Now().Add(timeout)
+	for {
+		stopped := false
+		h.command(func() {
+			remaining = len(h.pendingResponses)
+			select {
+			case <-h.doneC:
+				stopped = true
+			default:
+			}
+		})
+		// once the handler has stopped nothing more will complete
+		if remaining == 0 || stopped || !time.Now().Before(deadline) {
+			return
+		}
+		time.Sleep(drainPollInterval)
+	}
+}
+
+// cancelPending sends a cancel request for every pending order.
+func (h *Handler) cancelPending() {
+	for clOrdID, request := range h.pendingResponses {
+		h.requestID++
+		cancel := &client.OrderCancelRequest{
+			ClOrdID:      uuid.New().String(),
+			OrigClOrdID:  clOrdID,
+			Symbol:       request.Message().Symbol,
+			TransactTime: client.MicrosTimestamp(h.config.Clock.Now()),
+		}
+		if err := h.sendJSON(client.NewOrderCancelRequest(h.config.Clock.Now(), h.requestID, cancel)); err != nil {
+			log.Printf("error cancelling order %s: %s", clOrdID, err)
+		}
+	}
+}
+
+// abandonPending answers every pending request with an unknown outcome.
+func (h *Handler) abandonPending() (count int) {
+	for clOrdID, request := range h.pendingResponses {
+		log.Printf("account %s order %s outcome unknown at shutdown", h.config.Account, clOrdID)
+		request.Respond("unknown(shutting down before the order completed)")
+		count++
+	}
+	for _, pending := range h.pendingQuotes {
+		pending.request.Respond("rejected(shutting down before the quote was received)")
+	}
+	h.pendingResponses = make(map[string]*endpoint.Request)
+	h.pendingRequests = make(map[int64]*endpoint.Request)
+	h.pendingQuotes = make(map[string]pendingQuote)
+	return
+}
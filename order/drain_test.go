@@ -0,0 +1,150 @@
+package order
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/pintu-crypto/b2b-order/client"
+	"github.com/pintu-crypto/b2b-order/endpoint"
+)
+
+func testOrder(clOrdID string) *client.NewOrderSingle {
+	return &client.NewOrderSingle{
+		ClOrdID:     clOrdID,
+		Symbol:      "DOGE-USDT",
+		Side:        client.Side.Buy,
+		OrderQty:    decimal.NewFromInt(1000),
+		OrdType:     client.OrdType.Market,
+		TimeInForce: client.TimeInForce.FillOrKill,
+	}
+}
+
+// drainAsync drains the handler on another goroutine, once the handler stopped taking orders.
+func (h *testHandler) drainAsync(t *testing.T, options DrainOptions) <-chan int {
+	t.Helper()
+	unknown := make(chan int, 1)
+	go func() {
+		unknown <- h.Drain(options)
+	}()
+	deadline := time.Now().Add(time.Second)
+	for draining := false; !draining; {
+		if time.Now().After(deadline) {
+			t.Fatal("the handler didn't start draining")
+		}
+		h.command(func() {
+			draining = h.draining
+		})
+	}
+	return unknown
+}
+
+func expectDrained(t *testing.T, unknown <-chan int, expected int) {
+	t.Helper()
+	select {
+	case got := <-unknown:
+		if got != expected {
+			t.Errorf("got %d orders with an unknown outcome, expected %d", got, expected)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("drain didn't complete")
+	}
+}
+
+func TestDrainWaitsForPending(t *testing.T) {
+	h := newTestHandler(t, Config{Account: "test"})
+	defer h.Close()
+
+	response := h.placeOrder(t, testOrder("C1"))
+	h.expectSent(t, "NewOrderSingle")
+	unknown := h.drainAsync(t, DrainOptions{Timeout: 2 * time.Second})
+
+	// no new orders are taken while draining
+	late, _ := endpoint.NewRequest("test", testOrder("C2"))
+	select {
+	case h.requests <- late:
+		t.Fatal("took an order while draining")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// the pending order completes within the timeout
+	h.incoming <- []byte(`{"reqid":1,"seq":1,"type":"ExecutionReport","data":[
+		{"Timestamp":"2024-01-01T00:00:01.000000Z","Symbol":"DOGE-USDT","ClOrdID":"C1","OrderID":"O1",
+		"Side":"Buy","ExecType":"Trade","OrdStatus":"Filled","CumQty":"1000","AvgPx":"0.2"}]}`)
+	expectResponse(t, response, "filled(1000 @ 0.2)")
+	expectDrained(t, unknown, 0)
+}
+
+func TestDrainCancelsRemaining(t *testing.T) {
+	h := newTestHandler(t, Config{Account: "test"})
+	defer h.Close()
+
+	response := h.placeOrder(t, testOrder("C1"))
+	h.expectSent(t, "NewOrderSingle")
+	unknown := h.drainAsync(t, DrainOptions{
+		Timeout:         10 * time.Millisecond,
+		CancelRemaining: true,
+		CancelTimeout:   2 * time.Second,
+	})
+
+	// the order still pending after the timeout is canceled
+	cancel := struct {
+		Data []client.OrderCancelRequest `json:"data"`
+	}{}
+	if err := json.Unmarshal(h.expectSent(t, "OrderCancelRequest"), &cancel); err != nil {
+		t.Fatal(err)
+	}
+	if len(cancel.Data) != 1 || cancel.Data[0].OrigClOrdID != "C1" || cancel.Data[0].Symbol != "DOGE-USDT" {
+		t.Fatalf("got cancel %+v, expected a cancel of C1", cancel.Data)
+	}
+	h.incoming <- []byte(`{"reqid":1,"seq":1,"type":"ExecutionReport","data":[
+		{"Timestamp":"2024-01-01T00:00:01.000000Z","Symbol":"DOGE-USDT","ClOrdID":"` + cancel.Data[0].ClOrdID + `",
+		"OrigClOrdID":"C1","OrderID":"O1","Side":"Buy","ExecType":"Canceled","OrdStatus":"Canceled",
+		"CumQty":"0","Text":"canceled on shutdown"}]}`)
+	expectResponse(t, response, "rejected(canceled on shutdown)")
+	expectDrained(t, unknown, 0)
+}
+
+func TestDrainAbandonsPending(t *testing.T) {
+	h := newTestHandler(t, Config{Account: "test"})
+	defer h.Close()
+
+	response := h.placeOrder(t, testOrder("C1"))
+	h.expectSent(t, "NewOrderSingle")
+
+	// without cancelling, the orders still pending are answered with an unknown outcome
+	unknown := h.drainAsync(t, DrainOptions{Timeout: 10 * time.Millisecond})
+	expectResponse(t, response, "unknown(shutting down before the order completed)")
+	expectDrained(t, unknown, 1)
+	h.command(func() {
+		if len(h.pendingResponses) != 0 || len(h.pendingRequests) != 0 {
+			t.Errorf("got %d pending responses and %d pending requests after the drain",
+				len(h.pendingResponses), len(h.pendingRequests))
+		}
+	})
+}
+
+func TestDrainBeforeHello(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	checkpoint, err := LoadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkpoint.Update("Trade", client.MicrosTimestamp(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+	h := newTestHandlerWithoutHello(t, Config{Account: "test", Checkpoint: checkpoint})
+	defer h.Close()
+
+	// the drain commands run while waiting for the hello, so it doesn't wait on the connection
+	unknown := h.drainAsync(t, DrainOptions{Timeout: time.Second})
+	expectDrained(t, unknown, 0)
+	loaded, err := LoadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.StartDate("Trade") == nil {
+		t.Error("the checkpoint wasn't flushed by the drain")
+	}
+}
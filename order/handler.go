@@ -13,19 +13,97 @@ import (
 	"github.com/pintu-crypto/b2b-order/endpoint"
 )
 
+// Config contains the account specific settings of a handler.
+type Config struct {
+	// Account is the name of the account, used for logging.
+	Account string
+	// SubAccount and Group are set on every order placed by the handler, if not empty.
+	SubAccount string
+	Group      string
+	// TradeLookback is how far back to recover trades and execution reports on subscribe when
+	// there is no checkpoint. Defaults to 15 minutes.
+	TradeLookback time.Duration
+	// Checkpoint records the last processed message of each stream, and is used to resume the
+	// streams on subscribe. If nil, an in-memory checkpoint is used.
+	Checkpoint *Checkpoint
+	// CheckpointInterval is how often the checkpoint is flushed. Defaults to 5 seconds.
+	CheckpointInterval time.Duration
+	// Reconciler cross-checks the trades against the execution reports, if not nil.
+	Reconciler *Reconciler
+	// DeadLetters records the messages that couldn't be processed. If nil, an in-memory store
+	// is used.
+	DeadLetters *DeadLetters
+	// Merger merges the execution reports of the connections of a pool, so that each report is
+	// processed once. If nil, every report received is processed.
+	Merger *Merger
+	// Balances keeps the balances of the account from the Balance stream, adjusted by the orders,
+	// if not nil.
+	Balances *Balances
+	// Quotes holds the quotes of the account, to be accepted by RFQ orders. If nil, quotes
+	// aren't requested.
+	Quotes *Quotes
+	// Subscriptions tracks the streams subscribed by the connections of the account, to restore
+	// them on each new connection. If nil, the streams of the registry are subscribed to.
+	Subscriptions *Subscriptions
+	// Streams are subscribed to along with the execution reports and trades, and their
+	// elements are decoded and handled as they're received. May be nil.
+	Streams *Streams
+	// Clock estimates the skew of the local clock from the server timestamps, and stamps the
+	// requests. If nil, the local time is used.
+	Clock *client.Clock
+	// MaxFailures is how many messages may fail within the FailureWindow before the handler
+	// gives up on the connection and reports an error to reconnect. Defaults to 10 per minute.
+	MaxFailures   int
+	FailureWindow time.Duration
+}
+
+const (
+	// defaultTradeLookback is used when the config doesn't set a trade lookback.
+	defaultTradeLookback = 15 * time.Minute
+
+	// defaultCheckpointInterval is used when the config doesn't set a checkpoint interval.
+	defaultCheckpointInterval = 5 * time.Second
+
+	// helloTimeout is how long to wait for the hello message after connecting.
+	helloTimeout = 10 * time.Second
+
+	// defaultMaxFailures and defaultFailureWindow are used when the config doesn't set them.
+	defaultMaxFailures   = 10
+	defaultFailureWindow = time.Minute
+)
+
+// errClosed is returned by the handler loops when the handler is closed.
+var errClosed = errors.New("handler closed")
+
 // Handler is the main order state machine.
 type Handler struct {
 	incoming client.IncomingChannel
 	outgoing client.OutgoingChannel
 	requests endpoint.RequestsChannel
+	config   Config
 
 	requestID        int64
 	pendingResponses map[string]*endpoint.Request
 	pendingRequests  map[int64]*endpoint.Request
+	pendingQuotes    map[string]pendingQuote
+
+	sessionID     string
+	checkpoint    *Checkpoint
+	sequences     *sequenceTracker
+	streams       *Streams
+	subscriptions *Subscriptions
+	subscribed    bool
+	deadLetters   *DeadLetters
+	failures      []time.Time
+	errorC        chan error
 
-	sessionID string
+	// commands are run on the handler goroutine, draining stops accepting new requests
+	commands chan func()
+	draining bool
 
 	closeC    chan interface{}
+	closeOnce sync.Once
+	doneC     chan interface{}
 	closeWait sync.WaitGroup
 }
 
@@ -33,29 +111,120 @@ type Handler struct {
 // forwards those requests to the API, receives order and trade updates.
 func New(incoming client.IncomingChannel,
 	outgoing client.OutgoingChannel,
-	requests endpoint.RequestsChannel) (res *Handler, err error) {
+	requests endpoint.RequestsChannel,
+	config Config) (res *Handler, err error) {
 	res = &Handler{
 		incoming:         incoming,
 		outgoing:         outgoing,
 		requests:         requests,
+		config:           config,
 		pendingResponses: make(map[string]*endpoint.Request),
 		pendingRequests:  make(map[int64]*endpoint.Request),
+		pendingQuotes:    make(map[string]pendingQuote),
+		checkpoint:       config.Checkpoint,
+		sequences:        newSequenceTracker(),
+		deadLetters:      config.DeadLetters,
+		commands:         make(chan func()),
+		errorC:           make(chan error, 1),
 		closeC:           make(chan interface{}),
+		doneC:            make(chan interface{}),
 	}
+	if res.checkpoint == nil {
+		res.checkpoint, _ = LoadCheckpoint("")
+	}
+	if res.deadLetters == nil {
+		res.deadLetters = NewDeadLetters(config.Account)
+	}
+	if res.streams, err = res.registerStreams(); err != nil {
+		return
+	}
+	if res.subscriptions = config.Subscriptions; res.subscriptions == nil {
+		res.subscriptions = NewSubscriptions()
+	}
+	res.subscriptions.attach(res)
+	if res.config.MaxFailures <= 0 {
+		res.config.MaxFailures = defaultMaxFailures
+	}
+	if res.config.FailureWindow <= 0 {
+		res.config.FailureWindow = defaultFailureWindow
+	}
+	res.closeWait.Add(1)
 	go res.runLoop()
 	return
 }
 
-// Close stops the handler.
+// registerStreams returns the registry of the execution reports, the trades, the balances and
+// quotes if kept, and the streams of the config.
+func (h *Handler) registerStreams() (streams *Streams, err error) {
+	streams, err = NewStreams(
+		Stream{
+			Name: "ExecutionReport",
+			New:  func() interface{} { return &client.ExecutionReport{} },
+			Handle: func(reqID int64, value interface{}) error {
+				return h.handleExecutionReport(reqID, value.(*client.ExecutionReport))
+			},
+			Timestamp: func(value interface{}) client.MicrosTimestamp {
+				return value.(*client.ExecutionReport).Timestamp
+			},
+		},
+		Stream{
+			Name: "Trade",
+			New:  func() interface{} { return &client.Trade{} },
+			Handle: func(reqID int64, value interface{}) error {
+				return h.handleTrade(value.(*client.Trade))
+			},
+			Timestamp: func(value interface{}) client.MicrosTimestamp {
+				return value.(*client.Trade).Timestamp
+			},
+		})
+	if err == nil && h.config.Balances != nil {
+		err = streams.Register(h.config.Balances.Stream())
+	}
+	if err == nil && h.config.Quotes != nil {
+		err = streams.Register(Stream{
+			Name: "Quote",
+			New:  func() interface{} { return &client.Quote{} },
+			Handle: func(reqID int64, value interface{}) error {
+				return h.handleQuote(value.(*client.Quote))
+			},
+		})
+	}
+	if err != nil || h.config.Streams == nil {
+		return
+	}
+	for _, name := range h.config.Streams.Names() {
+		if err = streams.Register(*h.config.Streams.Get(name)); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Close stops the handler and flushes the checkpoint. It may be called more than once.
 func (h *Handler) Close() {
-	close(h.closeC)
-	h.closeWait.Wait()
+	h.closeOnce.Do(func() {
+		close(h.closeC)
+		h.closeWait.Wait()
+		h.subscriptions.detach(h)
+		if err := h.checkpoint.Flush(); err != nil {
+			log.Printf("error flushing checkpoint " + err.Error())
+		}
+	})
+}
+
+// ErrorChannel receives an error if the handler gives up on the connection, after too many
+// messages failed within the failure window. The connection should be closed and reconnected.
+func (h *Handler) ErrorChannel() <-chan error {
+	return h.errorC
 }
 
 // runLoop is run forever to handle incoming events.
 func (h *Handler) runLoop() {
-	h.closeWait.Add(1)
-	if err := h.handleInit(); err != nil {
+	defer close(h.doneC)
+	defer h.closeWait.Done()
+	if err := h.handleInit(); err == errClosed {
+		return
+	} else if err != nil {
 		log.Printf("error during init " + err.Error())
 	}
 	if err := h.handleSubscribe(); err != nil {
@@ -63,69 +232,194 @@ func (h *Handler) runLoop() {
 	}
 	if err := h.handleRunning(); err != nil {
 		log.Printf("error during run " + err.Error())
+		h.errorC <- err
 	}
-	h.closeWait.Done()
 }
 
-// handleInit waits for the hello message.
+// handleInit waits for the hello message. Commands are run meanwhile, so that a drain doesn't
+// wait on a connection that never says hello.
 func (h *Handler) handleInit() (err error) {
-	msg := <-h.incoming
+	timeout := time.NewTimer(helloTimeout)
+	defer timeout.Stop()
+	var msg []byte
+	for msg == nil {
+		select {
+		case msg = <-h.incoming:
+		case command := <-h.commands:
+			command()
+		case <-timeout.C:
+			err = errors.Errorf("no hello message after %s", helloTimeout)
+			return
+		case <-h.closeC:
+			err = errClosed
+			return
+		}
+	}
+	received := time.Now()
 	hello := client.Hello{}
 	if err = json.Unmarshal(msg, &hello); err != nil {
 		err = errors.Wrap(err, "unable to decode hello message")
 		return
 	}
+	h.config.Clock.Observe(hello.Timestamp, received)
 	// record the sessionID to use when placing orders, we want to
 	// cancel all our orders if we get disconnected
 	h.sessionID = hello.SessionID
 	return
 }
 
-// handleSubscribe subscribes to execution reports and post trades.
+// handleSubscribe subscribes to execution reports, post trades and the streams of the config,
+// as changed by the subscriptions of the account.
 func (h *Handler) handleSubscribe() (err error) {
+	// subscribe to ExecutionReport. This will return any open orders and any future order updates,
+	// and any updates since the last checkpointed execution report.
+	// subscribe to Trade, and recover any trades since the checkpoint.
+	// Without a checkpoint, both streams are recovered for the trade lookback, 15 minutes by
+	// default, so that the recovered trades can be reconciled against their execution reports.
+	h.subscribed = true
+	return h.subscribeStreams(h.subscriptions.restore(h.streams.Names()))
+}
+
+// subscribeStreams subscribes to the given streams that aren't subscribed to yet, in a single
+// subscription, starting from their checkpoints.
+func (h *Handler) subscribeStreams(streams []string) (err error) {
+	var missing []string
+	for _, stream := range streams {
+		if !h.sequences.subscribed(stream) && !contains(missing, stream) {
+			missing = append(missing, stream)
+		}
+	}
+	if len(missing) == 0 {
+		return
+	}
 	h.requestID++
-	// subscribe to ExecutionReport. This will return any open orders and any future order updates.
-	// subscribe to Trade, and recover any trades for the last 15 minutes.
-	// Usually, this would checkpoint a persist store like a database.
-	tradesStartDate := client.MicrosTimestamp(time.Now().Add(-15 * time.Minute))
-	err = h.sendJSON(client.NewSubscribeRequest(time.Now(), h.requestID,
-		client.StreamParameters{
-			Name: "ExecutionReport",
-		},
-		client.StreamParameters{
-			Name:      "Trade",
-			StartDate: &tradesStartDate,
-		}))
+	h.sequences.subscribe(h.requestID, missing...)
+	parameters := make([]client.StreamParameters, 0, len(missing))
+	for _, stream := range missing {
+		parameters = append(parameters, h.streamParameters(stream))
+	}
+	err = h.sendJSON(client.NewSubscribeRequest(h.config.Clock.Now(), h.requestID, parameters...))
 	if err != nil {
-		err = errors.Wrap(err, "failed to send ExecutionReport subscribe")
-		return
+		err = errors.Wrapf(err, "failed to send %v subscribe", missing)
 	}
 	return
 }
 
+// unsubscribeStreams ends the subscriptions to the given streams. The other streams of these
+// subscriptions are subscribed to again, from their checkpoints.
+func (h *Handler) unsubscribeStreams(streams []string) (err error) {
+	var remaining []string
+	for _, reqID := range h.sequences.subscriptionsOf(streams) {
+		for _, stream := range h.sequences.supersede(reqID) {
+			if !contains(streams, stream) {
+				remaining = append(remaining, stream)
+			}
+		}
+		if err = h.sendJSON(client.NewUnsubscribeRequest(h.config.Clock.Now(), reqID)); err != nil {
+			return errors.Wrapf(err, "failed to unsubscribe request %d", reqID)
+		}
+	}
+	return h.subscribeStreams(remaining)
+}
+
+// resubscribe replaces the subscription with the given request ID by a new one to the same
+// streams, starting from their checkpoints. The responses to the old subscription are dropped
+// from then on.
+func (h *Handler) resubscribe(reqID int64) (err error) {
+	streams := h.sequences.supersede(reqID)
+	if err = h.sendJSON(client.NewUnsubscribeRequest(h.config.Clock.Now(), reqID)); err != nil {
+		return errors.Wrapf(err, "failed to unsubscribe request %d", reqID)
+	}
+	if err = h.subscribeStreams(streams); err != nil {
+		err = errors.Wrapf(err, "failed to resubscribe to %v", streams)
+	}
+	return
+}
+
+// changeSubscriptions subscribes to and unsubscribes from the given streams on the handler
+// goroutine. Before the handler subscribed, the changes are left to its initial subscription.
+func (h *Handler) changeSubscriptions(add []string, remove []string) (err error) {
+	h.command(func() {
+		select {
+		case <-h.doneC:
+			return
+		default:
+		}
+		if !h.subscribed {
+			return
+		}
+		if err = h.unsubscribeStreams(remove); err != nil {
+			return
+		}
+		err = h.subscribeStreams(add)
+	})
+	return
+}
+
+// streamParameters returns the parameters to subscribe to the given stream from its checkpoint,
+// or from the trade lookback if there's no checkpoint yet. A stream that isn't checkpointed
+// starts from the present.
+func (h *Handler) streamParameters(stream string) client.StreamParameters {
+	if registered := h.streams.Get(stream); registered == nil || registered.Timestamp == nil {
+		return client.StreamParameters{Name: stream}
+	}
+	startDate := h.checkpoint.StartDate(stream)
+	if startDate == nil {
+		lookback := h.config.TradeLookback
+		if lookback <= 0 {
+			lookback = defaultTradeLookback
+		}
+		lookbackStartDate := client.MicrosTimestamp(time.Now().Add(-lookback))
+		startDate = &lookbackStartDate
+	}
+	return client.StreamParameters{
+		Name:      stream,
+		StartDate: startDate,
+	}
+}
+
 // handleRunning is the main handler that processes the next event,
 // either a order request or a response from the websocket server.
 func (h *Handler) handleRunning() (err error) {
+	interval := h.config.CheckpointInterval
+	if interval <= 0 {
+		interval = defaultCheckpointInterval
+	}
+	flushTicker := time.NewTicker(interval)
+	defer flushTicker.Stop()
 	for {
+		// stop taking new requests once draining
+		requests := h.requests
+		if h.draining {
+			requests = nil
+		}
 		select {
 		case data := <-h.incoming:
+			received := time.Now()
 			log.Printf("received message %s\n", string(data))
 			response := &client.Response{}
-			err = json.Unmarshal(data, response)
-			if err != nil {
-				err = errors.Wrap(err, "unable to decode response")
-				return
+			if decodeErr := json.Unmarshal(data, response); decodeErr != nil {
+				err = h.isolate(nil, data, errors.Wrap(decodeErr, "unable to decode response"))
+			} else {
+				h.config.Clock.Observe(response.Timestamp, received)
+				err = h.handleResponse(response)
 			}
-			err = h.handleResponse(response)
 			if err != nil {
 				err = errors.Wrap(err, "error handling response")
 				return
 			}
-		case request := <-h.requests:
-			err = h.handleRequest(request)
-			if err != nil {
-				err = errors.Wrap(err, "error sending request")
-				return
+		case request := <-requests:
+			if requestErr := h.safely(func() error { return h.handleRequest(request) }); requestErr != nil {
+				log.Printf("error sending request " + requestErr.Error())
+				delete(h.pendingRequests, h.requestID)
+				h.release(request)
+				request.Respond(fmt.Sprintf("rejected(%s)", requestErr))
+			}
+		case command := <-h.commands:
+			command()
+		case <-flushTicker.C:
+			if flushErr := h.checkpoint.Flush(); flushErr != nil {
+				log.Printf("error flushing checkpoint " + flushErr.Error())
 			}
 		case <-h.closeC:
 			return
@@ -133,6 +427,37 @@ func (h *Handler) handleRunning() (err error) {
 	}
 }
 
+// safely runs the given message handler, returning a panic as an error so that a single message
+// can't stop the handler.
+func (h *Handler) safely(handle func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.Errorf("panic: %v", r)
+		}
+	}()
+	return handle()
+}
+
+// isolate records a message, or an element of its data, that couldn't be processed as a dead
+// letter, so that processing moves on to the next one. It returns an error once MaxFailures
+// messages failed within the FailureWindow, as the connection is then unlikely to recover.
+func (h *Handler) isolate(response *client.Response, data json.RawMessage, cause error) (err error) {
+	h.deadLetters.Add(response, data, cause)
+	now := time.Now()
+	failures := h.failures[:0]
+	for _, failure := range h.failures {
+		if now.Sub(failure) < h.config.FailureWindow {
+			failures = append(failures, failure)
+		}
+	}
+	h.failures = append(failures, now)
+	if len(h.failures) >= h.config.MaxFailures {
+		err = errors.Errorf("%d messages failed within %s, last: %s",
+			len(h.failures), h.config.FailureWindow, cause)
+	}
+	return
+}
+
 // sendJSON sends the given request to the websocket server.
 func (h *Handler) sendJSON(request interface{}) (err error) {
 	data, err := json.Marshal(request)
@@ -147,13 +472,43 @@ func (h *Handler) sendJSON(request interface{}) (err error) {
 
 // handleRequest processes a order request.
 func (h *Handler) handleRequest(request *endpoint.Request) (err error) {
+	if request.QuoteRequest() != nil {
+		return h.handleQuoteRequest(request)
+	}
+	newOrder := request.Message()
+	rfq := newOrder.OrdType == client.OrdType.RFQ
+	if rfq {
+		if rejectErr := h.priceQuote(newOrder); rejectErr != nil {
+			log.Printf("rejected order %s locally: %s", newOrder.ClOrdID, rejectErr)
+			request.Respond(fmt.Sprintf("rejected(%s)", rejectErr))
+			return
+		}
+	}
 	h.requestID++
 
-	newOrder := request.Message()
 	// add the current sessionID to the request to ensure that it's cancelled if we're disconnected
 	newOrder.CancelSessionID = h.sessionID
+	newOrder.SubAccount = h.config.SubAccount
+	newOrder.Group = h.config.Group
+	newOrder.TransactTime = client.MicrosTimestamp(h.config.Clock.Adjust(time.Time(newOrder.TransactTime)))
+	if h.config.Balances != nil {
+		if rejectErr := h.config.Balances.Reserve(newOrder); rejectErr != nil {
+			log.Printf("rejected order %s locally: %s", newOrder.ClOrdID, rejectErr)
+			request.Respond(fmt.Sprintf("rejected(%s)", rejectErr))
+			return
+		}
+	}
+	// the quote is accepted last, so that it can still be accepted after a local reject
+	if rfq {
+		if rejectErr := h.acceptQuote(newOrder); rejectErr != nil {
+			log.Printf("rejected order %s locally: %s", newOrder.ClOrdID, rejectErr)
+			h.release(request)
+			request.Respond(fmt.Sprintf("rejected(%s)", rejectErr))
+			return
+		}
+	}
 	h.pendingRequests[h.requestID] = request
-	message := client.NewNewOrderSingleRequest(time.Now(), h.requestID, newOrder)
+	message := client.NewNewOrderSingleRequest(h.config.Clock.Now(), h.requestID, newOrder)
 	err = h.sendJSON(message)
 	if err != nil {
 		return
@@ -164,45 +519,64 @@ func (h *Handler) handleRequest(request *endpoint.Request) (err error) {
 
 // handleResponse processes a response from the websocket server.
 func (h *Handler) handleResponse(response *client.Response) (err error) {
+	// the client dropped messages, so resume every stream from the checkpoint
+	if response.Type == client.OverflowMessageType {
+		log.Printf("the client dropped incoming messages, resubscribing")
+		for _, reqID := range h.sequences.subscriptions() {
+			if err = h.resubscribe(reqID); err != nil {
+				return
+			}
+		}
+		return
+	}
+
 	// check for any errors
 	if response.Error != nil {
 		return h.handleError(response.ReqID, *response.Error)
 	}
 
-	// then decode and process the response by type
-	switch response.Type {
-	case "ExecutionReport":
-		for _, data := range response.Data {
-			executionReport := &client.ExecutionReport{}
-			err = json.Unmarshal(data, executionReport)
-			if err != nil {
-				err = errors.Wrap(err, "unable to decode execution report")
-				return
-			}
-			log.Printf("received execution report %s\n", string(data))
-			err = h.handleExecutionReport(response.ReqID, executionReport)
-			if err != nil {
-				err = errors.Wrap(err, "error handling execution report")
+	// check the sequence, skipping duplicates and resubscribing from the checkpoint on a gap
+	switch status, missing := h.sequences.check(response.ReqID, response.Seq); status {
+	case sequenceSuperseded:
+		log.Printf("skipping %s seq %d of replaced subscription %d", response.Type, response.Seq, response.ReqID)
+		return
+	case sequenceDuplicate:
+		log.Printf("skipping duplicate %s seq %d of subscription %d", response.Type, response.Seq, response.ReqID)
+		sequenceDuplicates.Add(h.config.Account, 1)
+		return
+	case sequenceGap:
+		log.Printf("missed %d messages of subscription %d before seq %d, resubscribing",
+			missing, response.ReqID, response.Seq)
+		sequenceGaps.Add(h.config.Account, 1)
+		// the replay from the checkpoint includes this message, so it's not processed now
+		return h.resubscribe(response.ReqID)
+	}
+
+	// then decode and process the response with its stream. An element that can't be decoded or
+	// processed is recorded as a dead letter, and the rest of the response is still processed.
+	stream := h.streams.Get(response.Type)
+	if stream == nil {
+		log.Printf("unhandled response %s\n", response.Data)
+		return
+	}
+	for _, data := range response.Data {
+		value := stream.New()
+		if decodeErr := json.Unmarshal(data, value); decodeErr != nil {
+			if err = h.isolate(response, data, errors.Wrapf(decodeErr, "unable to decode %s", stream.Name)); err != nil {
 				return
 			}
+			continue
 		}
-	case "Trade":
-		for _, data := range response.Data {
-			trade := &client.Trade{}
-			err = json.Unmarshal(data, trade)
-			if err != nil {
-				err = errors.Wrap(err, "unable to decode execution report")
-				return
-			}
-			log.Printf("received trade %s\n", string(data))
-			err = h.handleTrade(trade)
-			if err != nil {
-				err = errors.Wrap(err, "error handling execution report")
+		log.Printf("received %s %s\n", stream.Name, string(data))
+		if stream.Timestamp != nil {
+			h.checkpoint.Update(stream.Name, stream.Timestamp(value))
+		}
+		handleErr := h.safely(func() error { return stream.Handle(response.ReqID, value) })
+		if handleErr != nil {
+			if err = h.isolate(response, data, errors.Wrapf(handleErr, "error handling %s", stream.Name)); err != nil {
 				return
 			}
 		}
-	default:
-		log.Printf("unhandled response %s\n", response.Data)
 	}
 	return
 }
@@ -213,25 +587,53 @@ func (h *Handler) handleError(requestID int64, e client.Error) (err error) {
 	if request, ok := h.pendingRequests[requestID]; ok {
 		request.Respond(fmt.Sprintf("rejected(%s)", e.Message))
 		delete(h.pendingRequests, requestID)
-		delete(h.pendingResponses, request.Message().ClOrdID)
+		if quoteRequest := request.QuoteRequest(); quoteRequest != nil {
+			delete(h.pendingQuotes, quoteRequest.QuoteReqID)
+		} else {
+			delete(h.pendingResponses, request.Message().ClOrdID)
+			h.release(request)
+		}
 	}
 	return
 }
 
+// release drops the balance reservation of an order request the server never accepted.
+func (h *Handler) release(request *endpoint.Request) {
+	if h.config.Balances != nil && request.Message() != nil {
+		h.config.Balances.Release(request.Message().ClOrdID)
+	}
+}
+
 // handleExecutionReport handles an execution report from the websocket server.
 func (h *Handler) handleExecutionReport(requestID int64, report *client.ExecutionReport) (err error) {
-	if request, ok := h.pendingResponses[report.ClOrdID]; ok {
+	// a report already received on another connection of the pool only resolves the requests
+	// pending on this one
+	first := h.config.Merger == nil || h.config.Merger.Add(report)
+	if first && h.config.Reconciler != nil {
+		h.config.Reconciler.AddExecutionReport(report)
+	}
+	if first && h.config.Balances != nil {
+		h.config.Balances.AddExecutionReport(report)
+	}
+	// reports for a cancel carry the cancel's ClOrdID, and the order's as OrigClOrdID
+	clOrdID := report.ClOrdID
+	request, ok := h.pendingResponses[clOrdID]
+	if !ok && report.OrigClOrdID != "" {
+		clOrdID = report.OrigClOrdID
+		request, ok = h.pendingResponses[clOrdID]
+	}
+	if ok {
 		switch report.OrdStatus {
 		case client.OrdStatus.DoneForDay, client.OrdStatus.Filled:
 			request.Respond(fmt.Sprintf("filled(%s @ %s)", report.CumQty, report.AvgPx))
 			delete(h.pendingRequests, requestID)
-			delete(h.pendingResponses, report.ClOrdID)
+			delete(h.pendingResponses, clOrdID)
 		case client.OrdStatus.Rejected,
 			client.OrdStatus.Canceled:
 			if report.CumQty.IsZero() {
 				request.Respond(fmt.Sprintf("rejected(%s)", report.Text))
 				delete(h.pendingRequests, requestID)
-				delete(h.pendingResponses, report.ClOrdID)
+				delete(h.pendingResponses, clOrdID)
 			}
 			// otherwise, wait for the done for day
 		}
@@ -241,6 +643,9 @@ func (h *Handler) handleExecutionReport(requestID int64, report *client.Executio
 
 // handleExecutionReport handles a post trade from the websocket server for reporting purposes.
 func (h *Handler) handleTrade(trade *client.Trade) (err error) {
+	if h.config.Reconciler != nil {
+		h.config.Reconciler.AddTrade(trade)
+	}
 	// process the trade data (for example store it into DB)
 	return
 }
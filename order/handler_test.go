@@ -0,0 +1,177 @@
+package order
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/pintu-crypto/b2b-order/client"
+	"github.com/pintu-crypto/b2b-order/endpoint"
+)
+
+// testHandler is a handler connected to channels standing in for the websocket.
+type testHandler struct {
+	*Handler
+	incoming chan []byte
+	outgoing chan []byte
+	requests chan *endpoint.Request
+}
+
+func newTestHandler(t *testing.T, config Config) *testHandler {
+	h := newTestHandlerWithoutHello(t, config)
+	h.incoming <- []byte(`{"type":"hello","session_id":"S1"}`)
+	h.expectSent(t, "subscribe")
+	return h
+}
+
+// newTestHandlerWithoutHello returns a handler still waiting for the hello message.
+func newTestHandlerWithoutHello(t *testing.T, config Config) *testHandler {
+	incoming := make(chan []byte, 10)
+	outgoing := make(chan []byte, 10)
+	requests := make(chan *endpoint.Request)
+	handler, err := New(incoming, outgoing, requests, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testHandler{Handler: handler, incoming: incoming, outgoing: outgoing, requests: requests}
+}
+
+// placeOrder hands an order to the handler and returns the channel its outcome is sent on.
+func (h *testHandler) placeOrder(t *testing.T, order *client.NewOrderSingle) <-chan string {
+	t.Helper()
+	request, response := endpoint.NewRequest("test", order)
+	select {
+	case h.requests <- request:
+	case <-time.After(time.Second):
+		t.Fatalf("order %s not taken", order.ClOrdID)
+	}
+	return response
+}
+
+// expectResponse waits for the outcome of a request and checks it.
+func expectResponse(t *testing.T, response <-chan string, expected string) {
+	t.Helper()
+	select {
+	case got := <-response:
+		if got != expected {
+			t.Errorf("got response %s, expected %s", got, expected)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("no response, expected %s", expected)
+	}
+}
+
+// expectSent waits for the handler to send a message of the given type and returns it.
+func (h *testHandler) expectSent(t *testing.T, msgType string) []byte {
+	t.Helper()
+	select {
+	case data := <-h.outgoing:
+		header := struct {
+			Type string `json:"type"`
+		}{}
+		_ = json.Unmarshal(data, &header)
+		if header.Type != msgType {
+			t.Fatalf("got %s, expected a %s", data, msgType)
+		}
+		return data
+	case <-time.After(time.Second):
+		t.Fatalf("no %s sent", msgType)
+		return nil
+	}
+}
+
+// sync waits for the handler to process everything received so far.
+func (h *testHandler) sync() {
+	h.command(func() {})
+}
+
+func TestHandlerDeadLetters(t *testing.T) {
+	deadLetters := NewDeadLetters("test")
+	h := newTestHandler(t, Config{Account: "test", DeadLetters: deadLetters})
+	defer h.Close()
+
+	// the second report has a malformed quantity, the others are processed
+	h.incoming <- []byte(`{"reqid":1,"seq":1,"type":"ExecutionReport","data":[
+		{"Timestamp":"2024-01-01T00:00:01.000000Z","OrderID":"O1","ExecType":"New","OrdStatus":"New","Side":"Buy"},
+		{"Timestamp":"2024-01-01T00:00:02.000000Z","OrderID":"O2","CumQty":"lots","Side":"Buy"},
+		{"Timestamp":"2024-01-01T00:00:03.000000Z","OrderID":"O3","ExecType":"Triggered","OrdStatus":"New","Side":"Buy"}]}`)
+	h.sync()
+
+	letters := deadLetters.List()
+	if len(letters) != 1 {
+		t.Fatalf("got %d dead letters, expected 1", len(letters))
+	}
+	if letters[0].Type != "ExecutionReport" || letters[0].Seq != 1 || letters[0].Account != "test" {
+		t.Errorf("got dead letter %+v", letters[0])
+	}
+	// the unknown ExecType doesn't stop the report being processed
+	if startDate := h.checkpoint.StartDate("ExecutionReport"); startDate == nil ||
+		*startDate != client.MicrosTimestamp(time.Date(2024, 1, 1, 0, 0, 3, 0, time.UTC)) {
+		t.Errorf("got checkpoint %v, expected the last report", startDate)
+	}
+
+	// the handler still runs
+	h.incoming <- []byte(`{"reqid":1,"seq":2,"type":"Trade","data":[
+		{"Timestamp":"2024-01-01T00:00:04.000000Z","OrderID":"O1","TradeID":"T1","Side":"Buy"}]}`)
+	h.sync()
+	if startDate := h.checkpoint.StartDate("Trade"); startDate == nil {
+		t.Errorf("trade not processed after the dead letter")
+	}
+}
+
+func TestHandlerFailureEscalation(t *testing.T) {
+	h := newTestHandler(t, Config{Account: "test", MaxFailures: 3, FailureWindow: time.Minute})
+	defer h.Close()
+
+	// frames that aren't responses at all are isolated until there are too many
+	for i := 0; i < 2; i++ {
+		h.incoming <- []byte(`not json`)
+		h.sync()
+		select {
+		case err := <-h.ErrorChannel():
+			t.Fatalf("escalated after %d failures: %s", i+1, err)
+		default:
+		}
+	}
+	if letters := h.deadLetters.List(); len(letters) != 2 || letters[0].Error == "" {
+		t.Fatalf("got dead letters %+v, expected both frames", letters)
+	}
+
+	h.incoming <- []byte(`{"reqid":1,"seq":1,"type":"Trade","data":[{"Quantity":"lots"}]}`)
+	select {
+	case err := <-h.ErrorChannel():
+		if err == nil {
+			t.Errorf("got a nil error")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no error after the third failure")
+	}
+}
+
+func TestHandlerOverflow(t *testing.T) {
+	h := newTestHandler(t, Config{Account: "test"})
+	defer h.Close()
+
+	// the client dropped messages, so the subscription is ended and the streams are resumed from
+	// the checkpoint
+	h.incoming <- []byte(`{"type":"overflow","dropped":12}`)
+	h.expectSent(t, "unsubscribe")
+	resubscribe := struct {
+		ReqID   int64                     `json:"reqid"`
+		Streams []client.StreamParameters `json:"streams"`
+	}{}
+	if err := json.Unmarshal(h.expectSent(t, "subscribe"), &resubscribe); err != nil {
+		t.Fatal(err)
+	}
+	if resubscribe.ReqID != 2 || len(resubscribe.Streams) != 2 {
+		t.Errorf("got resubscribe %+v, expected both streams on a new request", resubscribe)
+	}
+
+	// the replaced subscription is ignored from now on
+	h.incoming <- []byte(`{"reqid":1,"seq":1,"type":"Trade","data":[
+		{"Timestamp":"2024-01-01T00:00:04.000000Z","OrderID":"O1","TradeID":"T1","Side":"Buy"}]}`)
+	h.sync()
+	if startDate := h.checkpoint.StartDate("Trade"); startDate != nil && time.Time(*startDate).Year() == 2024 {
+		t.Errorf("processed a trade of the replaced subscription")
+	}
+}
@@ -0,0 +1,101 @@
+package order
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pintu-crypto/b2b-order/client"
+)
+
+// defaultMergeCapacity is the number of execution reports kept in the merged view.
+const defaultMergeCapacity = 10000
+
+// mergeDuplicates counts the execution reports received on more than one connection, per account.
+var mergeDuplicates = expvar.NewMap("merged_duplicates")
+
+// Merger merges the execution report streams of the connections of a pool, which each receive
+// every report of the account, into a single view ordered by timestamp, in which each report
+// appears once by ExecID. It is safe for concurrent use, and it outlives the handlers of the
+// individual connections.
+type Merger struct {
+	mu       sync.Mutex
+	account  string
+	reports  []*client.ExecutionReport
+	seen     map[string]bool
+	capacity int
+}
+
+// NewMerger returns a merger for the given account.
+func NewMerger(account string) *Merger {
+	return &Merger{
+		account:  account,
+		seen:     make(map[string]bool),
+		capacity: defaultMergeCapacity,
+	}
+}
+
+// Add records an execution report, and returns whether it is the first time it is received.
+// Once the view is full, the reports older than all the ones in it are taken as already received.
+func (m *Merger) Add(report *client.ExecutionReport) (first bool) {
+	if report.ExecID == "" {
+		return true
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.seen[report.ExecID] || (len(m.reports) >= m.capacity && before(report, m.reports[0])) {
+		mergeDuplicates.Add(m.account, 1)
+		return false
+	}
+	// reports mostly arrive in order, so search from the end
+	i := len(m.reports)
+	for i > 0 && before(report, m.reports[i-1]) {
+		i--
+	}
+	m.reports = append(m.reports, nil)
+	copy(m.reports[i+1:], m.reports[i:])
+	m.reports[i] = report
+	m.seen[report.ExecID] = true
+	if len(m.reports) > m.capacity {
+		delete(m.seen, m.reports[0].ExecID)
+		m.reports = m.reports[1:]
+	}
+	return true
+}
+
+// before returns whether the first report is older than the second.
+func before(a *client.ExecutionReport, b *client.ExecutionReport) bool {
+	return time.Time(a.Timestamp).Before(time.Time(b.Timestamp))
+}
+
+// List returns the merged execution reports after the given time, oldest first.
+func (m *Merger) List(since time.Time) (result []client.ExecutionReport) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	start := sort.Search(len(m.reports), func(i int) bool {
+		return time.Time(m.reports[i].Timestamp).After(since)
+	})
+	result = make([]client.ExecutionReport, 0, len(m.reports)-start)
+	for _, report := range m.reports[start:] {
+		result = append(result, *report)
+	}
+	return
+}
+
+// ServeHTTP responds with the merged execution reports as JSON, oldest first, optionally only
+// the ones after the RFC3339 time in the since parameter.
+func (m *Merger) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var since time.Time
+	if value := r.URL.Query().Get("since"); value != "" {
+		var err error
+		if since, err = time.Parse(time.RFC3339Nano, value); err != nil {
+			http.Error(w, "invalid since, expected an RFC3339 time", http.StatusBadRequest)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(m.List(since))
+}
@@ -0,0 +1,75 @@
+package order
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pintu-crypto/b2b-order/client"
+)
+
+// report returns an execution report with the given ExecID at the given second.
+func report(execID string, second int) *client.ExecutionReport {
+	return &client.ExecutionReport{
+		ExecID:    execID,
+		Timestamp: client.MicrosTimestamp(time.Date(2024, 1, 1, 0, 0, second, 0, time.UTC)),
+	}
+}
+
+func TestMerger(t *testing.T) {
+	m := NewMerger("test")
+	// two connections deliver the same reports, interleaved and slightly out of order
+	steps := []struct {
+		report *client.ExecutionReport
+		first  bool
+	}{
+		{report("E1", 1), true},
+		{report("E1", 1), false},
+		{report("E3", 3), true},
+		{report("E2", 2), true},
+		{report("E3", 3), false},
+		{report("E2", 2), false},
+		{report("", 4), true},
+	}
+	for i, step := range steps {
+		if first := m.Add(step.report); first != step.first {
+			t.Errorf("step %d: got first %t for %s", i, first, step.report.ExecID)
+		}
+	}
+
+	reports := m.List(time.Time{})
+	if len(reports) != 3 || reports[0].ExecID != "E1" || reports[1].ExecID != "E2" || reports[2].ExecID != "E3" {
+		t.Fatalf("got %v, expected each report once in timestamp order", reports)
+	}
+	if since := m.List(time.Date(2024, 1, 1, 0, 0, 1, 0, time.UTC)); len(since) != 2 || since[0].ExecID != "E2" {
+		t.Errorf("got %v, expected the reports after the first", since)
+	}
+
+	recorder := httptest.NewRecorder()
+	m.ServeHTTP(recorder, httptest.NewRequest("GET", "/executions?account=test&since=2024-01-01T00:00:02Z", nil))
+	var served []client.ExecutionReport
+	if err := json.Unmarshal(recorder.Body.Bytes(), &served); err != nil || len(served) != 1 || served[0].ExecID != "E3" {
+		t.Errorf("served %s, %v", recorder.Body, err)
+	}
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, httptest.NewRequest("GET", "/executions?since=yesterday", nil))
+	if recorder.Code != 400 {
+		t.Errorf("got status %d for an invalid since", recorder.Code)
+	}
+}
+
+func TestMergerCapacity(t *testing.T) {
+	m := NewMerger("test")
+	m.capacity = 2
+	m.Add(report("E1", 1))
+	m.Add(report("E2", 2))
+	m.Add(report("E3", 3))
+	// E1 was evicted, but is older than the whole view so it's still taken as received
+	if m.Add(report("E1", 1)) {
+		t.Errorf("evicted report accepted again")
+	}
+	if reports := m.List(time.Time{}); len(reports) != 2 || reports[0].ExecID != "E2" {
+		t.Errorf("got %v, expected the two latest reports", reports)
+	}
+}
@@ -0,0 +1,144 @@
+package order
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/pintu-crypto/b2b-order/client"
+	"github.com/pintu-crypto/b2b-order/endpoint"
+)
+
+// pendingQuote is a quote request waiting for its quote.
+type pendingQuote struct {
+	request   *endpoint.Request
+	requestID int64
+}
+
+// Quotes holds the open quotes of an account until they expire or are accepted. It is safe for
+// concurrent use, and it outlives the handlers of the individual connections.
+type Quotes struct {
+	mu     sync.Mutex
+	quotes map[string]*client.Quote
+}
+
+// NewQuotes returns an empty set of quotes.
+func NewQuotes() *Quotes {
+	return &Quotes{quotes: make(map[string]*client.Quote)}
+}
+
+// Add records a quote update, replacing the previous one with the same RFQID. The quotes that
+// are no longer open, or that expired by the time of the update, are dropped.
+func (q *Quotes) Add(quote *client.Quote) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for rfqID, open := range q.quotes {
+		if time.Time(open.ValidUntilTime).Before(time.Time(quote.Timestamp)) {
+			delete(q.quotes, rfqID)
+		}
+	}
+	if quote.QuoteStatus == client.QuoteStatus.Open {
+		q.quotes[quote.RFQID] = quote
+	} else {
+		delete(q.quotes, quote.RFQID)
+	}
+}
+
+// Price checks that the order accepts an open quote for the same symbol, side and quantity
+// before it expires, and sets the quoted price on the order, without accepting the quote yet.
+func (q *Quotes) Price(order *client.NewOrderSingle, now time.Time) (err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.price(order, now)
+}
+
+// Accept prices the order like Price, and accepts the quote. A quote is accepted only once.
+func (q *Quotes) Accept(order *client.NewOrderSingle, now time.Time) (err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err = q.price(order, now); err == nil {
+		delete(q.quotes, order.RFQID)
+	}
+	return
+}
+
+// price sets the price of the quote accepted by the order, with the quotes locked.
+func (q *Quotes) price(order *client.NewOrderSingle, now time.Time) (err error) {
+	quote, ok := q.quotes[order.RFQID]
+	switch {
+	case !ok:
+		return fmt.Errorf("unknown quote %s", order.RFQID)
+	case !now.Before(time.Time(quote.ValidUntilTime)):
+		delete(q.quotes, order.RFQID)
+		return fmt.Errorf("quote %s expired at %s", order.RFQID,
+			time.Time(quote.ValidUntilTime).UTC().Format(time.RFC3339Nano))
+	case order.Symbol != quote.Symbol || order.Side != quote.Side || !order.OrderQty.Equal(quote.OrderQty) ||
+		(order.Currency != "" && order.Currency != quote.Currency):
+		return fmt.Errorf("quote %s is for %s %s %s", order.RFQID, quote.Side, quote.OrderQty, quote.Symbol)
+	}
+	price := quote.Price()
+	order.Price = &price
+	order.Currency = quote.Currency
+	order.TimeInForce = client.TimeInForce.FillOrKill
+	return
+}
+
+// handleQuoteRequest sends a quote request, answered once the quote is received.
+func (h *Handler) handleQuoteRequest(request *endpoint.Request) (err error) {
+	if h.config.Quotes == nil {
+		request.Respond("rejected(quotes are not enabled)")
+		return
+	}
+	h.requestID++
+	quoteRequest := request.QuoteRequest()
+	quoteRequest.SubAccount = h.config.SubAccount
+	quoteRequest.Group = h.config.Group
+	quoteRequest.TransactTime = client.MicrosTimestamp(h.config.Clock.Adjust(time.Time(quoteRequest.TransactTime)))
+	h.pendingRequests[h.requestID] = request
+	if err = h.sendJSON(client.NewQuoteRequestRequest(h.config.Clock.Now(), h.requestID, quoteRequest)); err != nil {
+		return
+	}
+	h.pendingQuotes[quoteRequest.QuoteReqID] = pendingQuote{request: request, requestID: h.requestID}
+	return
+}
+
+// handleQuote records a quote, and answers the quote request waiting for it, if any.
+func (h *Handler) handleQuote(quote *client.Quote) (err error) {
+	h.config.Quotes.Add(quote)
+	pending, ok := h.pendingQuotes[quote.QuoteReqID]
+	if !ok {
+		return
+	}
+	switch quote.QuoteStatus {
+	case client.QuoteStatus.Open:
+		pending.request.Respond(fmt.Sprintf("quoted(%s %s @ %s until %s)", quote.RFQID, quote.OrderQty,
+			quote.Price(), time.Time(quote.ValidUntilTime).UTC().Format(time.RFC3339Nano)))
+	case client.QuoteStatus.Rejected, client.QuoteStatus.Canceled:
+		pending.request.Respond(fmt.Sprintf("rejected(%s)", quote.Text))
+	default:
+		return
+	}
+	delete(h.pendingQuotes, quote.QuoteReqID)
+	delete(h.pendingRequests, pending.requestID)
+	return
+}
+
+// priceQuote checks an RFQ order against its quote and sets the quoted price, before the order
+// is counted against the balances.
+func (h *Handler) priceQuote(order *client.NewOrderSingle) (err error) {
+	if h.config.Quotes == nil {
+		return errors.New("quotes are not enabled")
+	}
+	return h.config.Quotes.Price(order, h.config.Clock.Now())
+}
+
+// acceptQuote accepts the quote of an RFQ order, once nothing else can reject it locally.
+func (h *Handler) acceptQuote(order *client.NewOrderSingle) (err error) {
+	if err = h.config.Quotes.Accept(order, h.config.Clock.Now()); err == nil {
+		log.Printf("accepting quote %s with order %s", order.RFQID, order.ClOrdID)
+	}
+	return
+}
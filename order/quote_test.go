@@ -0,0 +1,126 @@
+package order
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/pintu-crypto/b2b-order/client"
+)
+
+func TestQuotesAccept(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	quotes := NewQuotes()
+	quote := func(rfqID string, validFor time.Duration) *client.Quote {
+		return &client.Quote{
+			Timestamp:      client.MicrosTimestamp(now),
+			RFQID:          rfqID,
+			Symbol:         "DOGE-USDT",
+			Currency:       "DOGE",
+			Side:           client.Side.Buy,
+			OrderQty:       decimal.NewFromInt(1000),
+			BidPx:          decimal.RequireFromString("0.19"),
+			OfferPx:        decimal.RequireFromString("0.21"),
+			ValidUntilTime: client.MicrosTimestamp(now.Add(validFor)),
+			QuoteStatus:    client.QuoteStatus.Open,
+		}
+	}
+	quotes.Add(quote("Q1", 10*time.Second))
+	quotes.Add(quote("Q2", time.Second))
+	accept := func(rfqID string, side client.SideEnum, qty int64, at time.Duration) (*client.NewOrderSingle, error) {
+		order := &client.NewOrderSingle{Symbol: "DOGE-USDT", RFQID: rfqID, Side: side,
+			OrderQty: decimal.NewFromInt(qty), OrdType: client.OrdType.RFQ}
+		return order, quotes.Accept(order, now.Add(at))
+	}
+
+	if _, err := accept("Q3", client.Side.Buy, 1000, 0); err == nil || err.Error() != "unknown quote Q3" {
+		t.Errorf("got %v, expected an unknown quote", err)
+	}
+	if _, err := accept("Q1", client.Side.Sell, 1000, 0); err == nil || err.Error() != "quote Q1 is for Buy 1000 DOGE-USDT" {
+		t.Errorf("got %v, expected a mismatch", err)
+	}
+	if _, err := accept("Q2", client.Side.Buy, 1000, 2*time.Second); err == nil ||
+		err.Error() != "quote Q2 expired at 2026-01-01T00:00:01Z" {
+		t.Errorf("got %v, expected an expired quote", err)
+	}
+	order, err := accept("Q1", client.Side.Buy, 1000, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Price == nil || order.Price.String() != "0.21" || order.Currency != "DOGE" ||
+		order.TimeInForce != client.TimeInForce.FillOrKill {
+		t.Errorf("got %+v, expected the offer price", order)
+	}
+	if _, err = accept("Q1", client.Side.Buy, 1000, 5*time.Second); err == nil {
+		t.Error("accepted the quote twice")
+	}
+
+	// a canceled quote can't be accepted, and expired quotes are dropped on the next update
+	quotes.Add(quote("Q4", 10*time.Second))
+	canceled := quote("Q4", 10*time.Second)
+	canceled.QuoteStatus = client.QuoteStatus.Canceled
+	quotes.Add(canceled)
+	if _, err = accept("Q4", client.Side.Buy, 1000, 0); err == nil {
+		t.Error("accepted a canceled quote")
+	}
+	later := quote("Q5", 10*time.Second)
+	later.Timestamp = client.MicrosTimestamp(now.Add(time.Minute))
+	quotes.Add(quote("Q6", 10*time.Second))
+	quotes.Add(later)
+	if len(quotes.quotes) != 1 {
+		t.Errorf("got %d quotes, expected only Q5", len(quotes.quotes))
+	}
+}
+
+func TestHandlerQuotes(t *testing.T) {
+	quotes := NewQuotes()
+	h := newTestHandler(t, Config{Account: "test", Quotes: quotes})
+	defer h.Close()
+
+	h.incoming <- []byte(`{"reqid":1,"seq":1,"type":"Quote","data":[
+		{"Timestamp":"2026-01-01T00:00:00.000000Z","QuoteReqID":"R1","RFQID":"Q1","Symbol":"DOGE-USDT",
+		"Side":"Sell","OrderQty":"1000","BidPx":"0.19","OfferPx":"0.21",
+		"ValidUntilTime":"2099-01-01T00:00:00.000000Z","QuoteStatus":"Open"}]}`)
+	h.sync()
+	order := &client.NewOrderSingle{Symbol: "DOGE-USDT", RFQID: "Q1", Side: client.Side.Sell,
+		OrderQty: decimal.NewFromInt(1000), OrdType: client.OrdType.RFQ}
+	if err := quotes.Accept(order, time.Now()); err != nil || order.Price.String() != "0.19" {
+		t.Errorf("got %v, expected the quote at the bid", err)
+	}
+}
+
+func TestHandlerQuoteInsufficientBalance(t *testing.T) {
+	quotes := NewQuotes()
+	balances := NewBalances("test", true)
+	h := newTestHandler(t, Config{Account: "test", Quotes: quotes, Balances: balances})
+	defer h.Close()
+	rfqOrder := func(clOrdID string) *client.NewOrderSingle {
+		order := testOrder(clOrdID)
+		order.OrdType = client.OrdType.RFQ
+		order.RFQID = "Q1"
+		return order
+	}
+
+	h.incoming <- []byte(`{"reqid":1,"seq":1,"type":"Balance","data":[
+		{"Currency":"USDT","Amount":"100","AvailableAmount":"100"}]}`)
+	h.incoming <- []byte(`{"reqid":1,"seq":2,"type":"Quote","data":[
+		{"Timestamp":"2026-01-01T00:00:00.000000Z","QuoteReqID":"R1","RFQID":"Q1","Symbol":"DOGE-USDT",
+		"Currency":"DOGE","Side":"Buy","OrderQty":"1000","BidPx":"0.19","OfferPx":"0.21",
+		"ValidUntilTime":"2099-01-01T00:00:00.000000Z","QuoteStatus":"Open"}]}`)
+	h.sync()
+
+	// the order exceeds the balance at the quoted price, and the quote is still open
+	expectResponse(t, h.placeOrder(t, rfqOrder("C1")), "rejected(insufficient USDT balance, 100 available)")
+	if err := quotes.Price(rfqOrder("C2"), time.Now()); err != nil {
+		t.Fatalf("got %v, expected the quote to be kept after the local reject", err)
+	}
+
+	// so it can be accepted once the balance allows it
+	h.incoming <- []byte(`{"reqid":1,"seq":3,"type":"Balance","data":[
+		{"Currency":"USDT","Amount":"500","AvailableAmount":"500"}]}`)
+	h.sync()
+	h.placeOrder(t, rfqOrder("C3"))
+	h.expectSent(t, "NewOrderSingle")
+	expectResponse(t, h.placeOrder(t, rfqOrder("C4")), "rejected(unknown quote Q1)")
+}
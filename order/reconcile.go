@@ -0,0 +1,229 @@
+package order
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/pintu-crypto/b2b-order/client"
+)
+
+const (
+	// defaultReconcileGrace is how long an order must be quiet before it's reconciled, as trades
+	// and execution reports arrive on separate streams.
+	defaultReconcileGrace = 30 * time.Second
+
+	// defaultReconcileRetention is how long reconciled orders are kept.
+	defaultReconcileRetention = 24 * time.Hour
+)
+
+// reconciliationMetrics publishes the number of discrepancies per account.
+var reconciliationMetrics = expvar.NewMap("reconciliation_discrepancies")
+
+// The kinds of discrepancies between the trades and the execution reports of an order.
+const (
+	DiscrepancyMissingTrades  = "MissingTrades"
+	DiscrepancyExtraTrades    = "ExtraTrades"
+	DiscrepancyAmountMismatch = "AmountMismatch"
+	DiscrepancyFeeMismatch    = "FeeMismatch"
+	DiscrepancyUnknownOrder   = "UnknownOrder"
+)
+
+// Discrepancy is a difference between the trades of an order and its latest execution report.
+type Discrepancy struct {
+	Kind     string
+	OrderID  string
+	ClOrdID  string `json:",omitempty"`
+	Symbol   string
+	Expected decimal.Decimal
+	Actual   decimal.Decimal
+	Trades   int
+}
+
+// reconciledOrder is the latest execution report and the trades of an order.
+type reconciledOrder struct {
+	report  *client.ExecutionReport
+	trades  map[string]*client.Trade
+	updated time.Time
+}
+
+// Reconciler groups the trades by OrderID and compares their quantity, amount and fee with the
+// cumulative values of the latest execution report of the order. It is safe for concurrent use,
+// and it outlives the handlers of the individual connections.
+type Reconciler struct {
+	mu        sync.Mutex
+	orders    map[string]*reconciledOrder
+	grace     time.Duration
+	retention time.Duration
+	nextPrune time.Time
+}
+
+// NewReconciler returns a reconciler for the given account. Orders are reconciled once they
+// haven't been updated for the grace period, and forgotten after the retention period. The
+// number of discrepancies is published as the reconciliation_discrepancies expvar.
+func NewReconciler(account string, grace time.Duration, retention time.Duration) *Reconciler {
+	if grace <= 0 {
+		grace = defaultReconcileGrace
+	}
+	if retention <= 0 {
+		retention = defaultReconcileRetention
+	}
+	r := &Reconciler{
+		orders:    make(map[string]*reconciledOrder),
+		grace:     grace,
+		retention: retention,
+	}
+	reconciliationMetrics.Set(account, expvar.Func(func() interface{} {
+		return len(r.Discrepancies(time.Now()))
+	}))
+	return r
+}
+
+// order returns the order with the given ID, creating it if needed. The expired orders are
+// pruned at most once per grace period, so that they don't pile up if nothing polls.
+func (r *Reconciler) order(orderID string, now time.Time) *reconciledOrder {
+	if !now.Before(r.nextPrune) {
+		r.prune(now)
+		r.nextPrune = now.Add(r.grace)
+	}
+	o, ok := r.orders[orderID]
+	if !ok {
+		o = &reconciledOrder{
+			trades: make(map[string]*client.Trade),
+		}
+		r.orders[orderID] = o
+	}
+	o.updated = now
+	return o
+}
+
+// AddExecutionReport records an execution report, keeping the latest one of each order.
+func (r *Reconciler) AddExecutionReport(report *client.ExecutionReport) {
+	if report.OrderID == "" {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	o := r.order(report.OrderID, time.Now())
+	if o.report == nil || report.CumQty.GreaterThan(o.report.CumQty) ||
+		(report.CumQty.Equal(o.report.CumQty) && !time.Time(report.Timestamp).Before(time.Time(o.report.Timestamp))) {
+		o.report = report
+	}
+}
+
+// AddTrade records a trade, keeping the latest update of each TradeID. Trades replayed after a
+// reconnect are only counted once.
+func (r *Reconciler) AddTrade(trade *client.Trade) {
+	if trade.OrderID == "" {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	o := r.order(trade.OrderID, time.Now())
+	if previous, ok := o.trades[trade.TradeID]; ok && time.Time(trade.Timestamp).Before(time.Time(previous.Timestamp)) {
+		return
+	}
+	o.trades[trade.TradeID] = trade
+}
+
+// Discrepancies returns the discrepancies of the orders that haven't been updated for the
+// grace period, and forgets the orders older than the retention period.
+func (r *Reconciler) Discrepancies(now time.Time) (result []Discrepancy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.prune(now)
+	for orderID, o := range r.orders {
+		if now.Sub(o.updated) < r.grace {
+			continue
+		}
+		result = append(result, o.discrepancies(orderID)...)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].OrderID != result[j].OrderID {
+			return result[i].OrderID < result[j].OrderID
+		}
+		return result[i].Kind < result[j].Kind
+	})
+	return
+}
+
+// prune forgets the orders older than the retention period.
+func (r *Reconciler) prune(now time.Time) {
+	for orderID, o := range r.orders {
+		if now.Sub(o.updated) > r.retention {
+			delete(r.orders, orderID)
+		}
+	}
+}
+
+// discrepancies compares the trades of the order with its latest execution report. Canceled
+// trades are left out, as they're no longer part of the order's execution.
+func (o *reconciledOrder) discrepancies(orderID string) (result []Discrepancy) {
+	quantity, amount, fee := decimal.Zero, decimal.Zero, decimal.Zero
+	symbol := ""
+	trades := 0
+	for _, trade := range o.trades {
+		if trade.TradeStatus == client.TradeStatus.Canceled {
+			continue
+		}
+		quantity = quantity.Add(trade.Quantity)
+		amount = amount.Add(trade.Amount)
+		fee = fee.Add(trade.Fee)
+		symbol = trade.Symbol
+		trades++
+	}
+	if o.report == nil {
+		if trades == 0 {
+			return
+		}
+		// trades of an order that was never reported, for example before the subscription start
+		return []Discrepancy{{
+			Kind:    DiscrepancyUnknownOrder,
+			OrderID: orderID,
+			Symbol:  symbol,
+			Actual:  quantity,
+			Trades:  trades,
+		}}
+	}
+	discrepancy := func(kind string, expected, actual decimal.Decimal) Discrepancy {
+		return Discrepancy{
+			Kind:     kind,
+			OrderID:  orderID,
+			ClOrdID:  o.report.ClOrdID,
+			Symbol:   o.report.Symbol,
+			Expected: expected,
+			Actual:   actual,
+			Trades:   trades,
+		}
+	}
+	switch quantity.Cmp(o.report.CumQty) {
+	case -1:
+		result = append(result, discrepancy(DiscrepancyMissingTrades, o.report.CumQty, quantity))
+	case 1:
+		result = append(result, discrepancy(DiscrepancyExtraTrades, o.report.CumQty, quantity))
+	default:
+		// only compare amounts and fees when the quantities match, otherwise they're expected to differ
+		if !amount.Equal(o.report.CumAmt) {
+			result = append(result, discrepancy(DiscrepancyAmountMismatch, o.report.CumAmt, amount))
+		}
+		if !fee.Equal(o.report.CumFee) {
+			result = append(result, discrepancy(DiscrepancyFeeMismatch, o.report.CumFee, fee))
+		}
+	}
+	return
+}
+
+// ServeHTTP responds with the current discrepancies as JSON.
+func (r *Reconciler) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	discrepancies := r.Discrepancies(time.Now())
+	if discrepancies == nil {
+		discrepancies = []Discrepancy{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(discrepancies)
+}
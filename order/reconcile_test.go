@@ -0,0 +1,159 @@
+package order
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/pintu-crypto/b2b-order/client"
+)
+
+func TestReconciler(t *testing.T) {
+	at := func(seconds int) client.MicrosTimestamp {
+		return client.MicrosTimestamp(time.Date(2024, 1, 1, 0, 0, seconds, 0, time.UTC))
+	}
+	report := func(orderID string, cumQty, cumAmt, cumFee string) *client.ExecutionReport {
+		return &client.ExecutionReport{
+			Timestamp: at(10), OrderID: orderID, ClOrdID: "C-" + orderID, Symbol: "DOGE-USDT",
+			OrdStatus: client.OrdStatus.Filled, CumQty: decimal.RequireFromString(cumQty),
+			CumAmt: decimal.RequireFromString(cumAmt), CumFee: decimal.RequireFromString(cumFee),
+		}
+	}
+	trade := func(orderID, tradeID string, qty, amt, fee string) *client.Trade {
+		return &client.Trade{
+			Timestamp: at(5), OrderID: orderID, TradeID: tradeID, Symbol: "DOGE-USDT",
+			Quantity: decimal.RequireFromString(qty), Amount: decimal.RequireFromString(amt),
+			Fee: decimal.RequireFromString(fee), TradeStatus: client.TradeStatus.Confirmed,
+		}
+	}
+	canceled := func(t *client.Trade) *client.Trade {
+		t.Timestamp = at(20)
+		t.TradeStatus = client.TradeStatus.Canceled
+		return t
+	}
+
+	tests := []struct {
+		name     string
+		reports  []*client.ExecutionReport
+		trades   []*client.Trade
+		expected []Discrepancy
+	}{
+		{
+			name:    "matching",
+			reports: []*client.ExecutionReport{report("O1", "1000", "200", "0.2")},
+			trades: []*client.Trade{
+				trade("O1", "T1", "600", "120", "0.12"),
+				trade("O1", "T2", "400", "80", "0.08"),
+			},
+		},
+		{
+			name:    "missing fill",
+			reports: []*client.ExecutionReport{report("O1", "1000", "200", "0.2")},
+			trades:  []*client.Trade{trade("O1", "T1", "600", "120", "0.12")},
+			expected: []Discrepancy{{Kind: DiscrepancyMissingTrades, OrderID: "O1", ClOrdID: "C-O1",
+				Symbol: "DOGE-USDT", Expected: decimal.NewFromInt(1000), Actual: decimal.NewFromInt(600), Trades: 1}},
+		},
+		{
+			name:    "extra trade",
+			reports: []*client.ExecutionReport{report("O1", "600", "120", "0.12")},
+			trades: []*client.Trade{
+				trade("O1", "T1", "600", "120", "0.12"),
+				trade("O1", "T2", "400", "80", "0.08"),
+			},
+			expected: []Discrepancy{{Kind: DiscrepancyExtraTrades, OrderID: "O1", ClOrdID: "C-O1",
+				Symbol: "DOGE-USDT", Expected: decimal.NewFromInt(600), Actual: decimal.NewFromInt(1000), Trades: 2}},
+		},
+		{
+			name:    "fee mismatch",
+			reports: []*client.ExecutionReport{report("O1", "1000", "200", "0.2")},
+			trades:  []*client.Trade{trade("O1", "T1", "1000", "200", "0.3")},
+			expected: []Discrepancy{{Kind: DiscrepancyFeeMismatch, OrderID: "O1", ClOrdID: "C-O1",
+				Symbol: "DOGE-USDT", Expected: decimal.RequireFromString("0.2"),
+				Actual: decimal.RequireFromString("0.3"), Trades: 1}},
+		},
+		{
+			name:   "unknown order",
+			trades: []*client.Trade{trade("O2", "T1", "1000", "200", "0.2")},
+			expected: []Discrepancy{{Kind: DiscrepancyUnknownOrder, OrderID: "O2", Symbol: "DOGE-USDT",
+				Actual: decimal.NewFromInt(1000), Trades: 1}},
+		},
+		{
+			name:    "canceled trade",
+			reports: []*client.ExecutionReport{report("O1", "600", "120", "0.12")},
+			trades: []*client.Trade{
+				trade("O1", "T1", "600", "120", "0.12"),
+				trade("O1", "T2", "400", "80", "0.08"),
+				canceled(trade("O1", "T2", "400", "80", "0.08")),
+				// the replay of an older update doesn't undo the cancel
+				trade("O1", "T2", "400", "80", "0.08"),
+			},
+		},
+		{
+			name:   "canceled trade of an unknown order",
+			trades: []*client.Trade{canceled(trade("O2", "T1", "1000", "200", "0.2"))},
+		},
+		{
+			name:    "replayed trade",
+			reports: []*client.ExecutionReport{report("O1", "1000", "200", "0.2")},
+			trades: []*client.Trade{
+				trade("O1", "T1", "1000", "200", "0.2"),
+				trade("O1", "T1", "1000", "200", "0.2"),
+			},
+		},
+	}
+	for _, test := range tests {
+		r := NewReconciler("test", time.Minute, time.Hour)
+		for _, report := range test.reports {
+			r.AddExecutionReport(report)
+		}
+		for _, trade := range test.trades {
+			r.AddTrade(trade)
+		}
+		got := r.Discrepancies(time.Now().Add(2 * time.Minute))
+		if len(got) != len(test.expected) {
+			t.Errorf("%s: got %+v, expected %+v", test.name, got, test.expected)
+			continue
+		}
+		for i, expected := range test.expected {
+			d := got[i]
+			if d.Kind != expected.Kind || d.OrderID != expected.OrderID || d.ClOrdID != expected.ClOrdID ||
+				d.Symbol != expected.Symbol || !d.Expected.Equal(expected.Expected) ||
+				!d.Actual.Equal(expected.Actual) || d.Trades != expected.Trades {
+				t.Errorf("%s: got %+v, expected %+v", test.name, d, expected)
+			}
+		}
+	}
+}
+
+func TestReconcilerGraceAndRetention(t *testing.T) {
+	r := NewReconciler("test", time.Minute, time.Hour)
+	r.AddTrade(&client.Trade{OrderID: "O1", TradeID: "T1", Quantity: decimal.NewFromInt(1)})
+	now := time.Now()
+
+	// an order is only reconciled once it's quiet for the grace period
+	if got := r.Discrepancies(now); len(got) != 0 {
+		t.Errorf("got %+v within the grace period", got)
+	}
+	if got := r.Discrepancies(now.Add(2 * time.Minute)); len(got) != 1 {
+		t.Errorf("got %+v after the grace period, expected the unknown order", got)
+	}
+
+	// and forgotten after the retention period
+	if got := r.Discrepancies(now.Add(2 * time.Hour)); len(got) != 0 || len(r.orders) != 0 {
+		t.Errorf("got %+v and %d orders after the retention period", got, len(r.orders))
+	}
+
+	// expired orders are also pruned on insert, without polling
+	r.AddTrade(&client.Trade{OrderID: "O2", TradeID: "T2", Quantity: decimal.NewFromInt(1)})
+	r.mu.Lock()
+	r.orders["O2"].updated = now.Add(-2 * time.Hour)
+	r.nextPrune = time.Time{}
+	r.mu.Unlock()
+	r.AddTrade(&client.Trade{OrderID: "O3", TradeID: "T3", Quantity: decimal.NewFromInt(1)})
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.orders["O2"]; ok || len(r.orders) != 1 {
+		t.Errorf("got %d orders, expected the expired order to be pruned", len(r.orders))
+	}
+}
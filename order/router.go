@@ -0,0 +1,143 @@
+package order
+
+import (
+	"fmt"
+	"hash/fnv"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/pintu-crypto/b2b-order/endpoint"
+)
+
+const (
+	// routerReplicas is the number of points of each connection on the hash ring, to spread the
+	// symbols evenly.
+	routerReplicas = 64
+
+	// routerQueueSize is the number of requests queued for each connection. Once a connection's
+	// queue is full, its requests are rejected rather than holding up the other connections.
+	routerQueueSize = 64
+)
+
+// ringPoint is a point of a connection on the hash ring.
+type ringPoint struct {
+	hash uint32
+	slot int
+}
+
+// Router distributes the order requests of an account across the connections of a pool by
+// consistent hashing of the symbol, so that the orders of a symbol, and the cancels that follow
+// them, go through the same connection in order. The symbols of a connection that is down move
+// to the next connection on the ring until it is back. Each connection has its own queue, so a
+// connection that falls behind doesn't delay the requests of the others.
+type Router struct {
+	requests endpoint.RequestsChannel
+	slots    []chan *endpoint.Request
+	ring     []ringPoint
+
+	mu      sync.RWMutex
+	healthy []bool
+
+	closeC    chan interface{}
+	closeOnce sync.Once
+	doneC     chan interface{}
+}
+
+// NewRouter starts routing the given requests across the given number of connections.
+func NewRouter(requests endpoint.RequestsChannel, connections int) *Router {
+	r := &Router{
+		requests: requests,
+		slots:    make([]chan *endpoint.Request, connections),
+		healthy:  make([]bool, connections),
+		closeC:   make(chan interface{}),
+		doneC:    make(chan interface{}),
+	}
+	for slot := range r.slots {
+		r.slots[slot] = make(chan *endpoint.Request, routerQueueSize)
+		for replica := 0; replica < routerReplicas; replica++ {
+			r.ring = append(r.ring, ringPoint{hash: hash(strconv.Itoa(slot) + "#" + strconv.Itoa(replica)), slot: slot})
+		}
+	}
+	sort.Slice(r.ring, func(i, j int) bool {
+		return r.ring[i].hash < r.ring[j].hash
+	})
+	go r.run()
+	return r
+}
+
+// hash returns the position of the given key on the ring.
+func hash(key string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return h.Sum32()
+}
+
+// Requests returns the requests routed to the given connection.
+func (r *Router) Requests(slot int) endpoint.RequestsChannel {
+	return r.slots[slot]
+}
+
+// SetHealthy marks whether the given connection is up and taking requests.
+func (r *Router) SetHealthy(slot int, healthy bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.healthy[slot] = healthy
+}
+
+// Route returns the connection of the given symbol: the first healthy one clockwise from the
+// symbol on the ring, or the symbol's own connection if none is healthy.
+func (r *Router) Route(symbol string) int {
+	h := hash(symbol)
+	start := sort.Search(len(r.ring), func(i int) bool {
+		return r.ring[i].hash >= h
+	})
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for i := 0; i < len(r.ring); i++ {
+		point := r.ring[(start+i)%len(r.ring)]
+		if r.healthy[point.slot] {
+			return point.slot
+		}
+	}
+	return r.ring[start%len(r.ring)].slot
+}
+
+// Close stops routing. The requests still queued for a connection are rejected.
+func (r *Router) Close() {
+	r.closeOnce.Do(func() {
+		close(r.closeC)
+		<-r.doneC
+		for _, queue := range r.slots {
+			for queued := true; queued; {
+				select {
+				case request := <-queue:
+					request.Respond("rejected(shutting down)")
+				default:
+					queued = false
+				}
+			}
+		}
+	})
+}
+
+// run queues each request for the connection of its symbol, rejecting it if that connection's
+// queue is full.
+func (r *Router) run() {
+	defer close(r.doneC)
+	for {
+		select {
+		case request := <-r.requests:
+			slot := r.Route(request.Symbol())
+			select {
+			case r.slots[slot] <- request:
+			default:
+				log.Printf("connection %d has %d requests queued, rejecting %s request", slot, routerQueueSize, request.Symbol())
+				request.Respond(fmt.Sprintf("rejected(connection %d is busy)", slot))
+			}
+		case <-r.closeC:
+			return
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package order
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pintu-crypto/b2b-order/endpoint"
+)
+
+func TestRouter(t *testing.T) {
+	r := NewRouter(nil, 4)
+	defer r.Close()
+	for slot := 0; slot < 4; slot++ {
+		r.SetHealthy(slot, true)
+	}
+
+	// the symbols are spread across the connections, each always on the same one
+	slots := make(map[string]int)
+	counts := make([]int, 4)
+	for i := 0; i < 400; i++ {
+		symbol := fmt.Sprintf("SYM%d-IDR", i)
+		slots[symbol] = r.Route(symbol)
+		counts[slots[symbol]]++
+		if again := r.Route(symbol); again != slots[symbol] {
+			t.Fatalf("%s routed to %d then %d", symbol, slots[symbol], again)
+		}
+	}
+	for slot, count := range counts {
+		if count < 40 {
+			t.Errorf("only %d of 400 symbols on connection %d: %v", count, slot, counts)
+		}
+	}
+
+	// only the symbols of a connection that is down move, and they move back once it's up
+	r.SetHealthy(2, false)
+	for symbol, slot := range slots {
+		moved := r.Route(symbol)
+		if slot == 2 && moved == 2 {
+			t.Errorf("%s still routed to the connection that is down", symbol)
+		}
+		if slot != 2 && moved != slot {
+			t.Errorf("%s moved from %d to %d although its connection is up", symbol, slot, moved)
+		}
+	}
+	r.SetHealthy(2, true)
+	for symbol, slot := range slots {
+		if r.Route(symbol) != slot {
+			t.Errorf("%s didn't move back to %d", symbol, slot)
+		}
+	}
+
+	// with every connection down, the symbols wait for their own connection
+	for slot := 0; slot < 4; slot++ {
+		r.SetHealthy(slot, false)
+	}
+	for symbol, slot := range slots {
+		if r.Route(symbol) != slot {
+			t.Errorf("%s routed away from %d with every connection down", symbol, slot)
+		}
+	}
+}
+
+func TestRouterSlowConnection(t *testing.T) {
+	requests := make(chan *endpoint.Request)
+	r := NewRouter(requests, 2)
+	defer r.Close()
+	r.SetHealthy(0, true)
+	r.SetHealthy(1, true)
+
+	// find a symbol on each connection
+	symbols := make(map[int]string)
+	for i := 0; len(symbols) < 2; i++ {
+		symbol := fmt.Sprintf("SYM%d-IDR", i)
+		symbols[r.Route(symbol)] = symbol
+	}
+	send := func(symbol string) <-chan string {
+		order := testOrder("C-" + symbol)
+		order.Symbol = symbol
+		request, response := endpoint.NewRequest("test", order)
+		select {
+		case requests <- request:
+		case <-time.After(time.Second):
+			t.Fatalf("router blocked on a %s request", symbol)
+		}
+		return response
+	}
+
+	// connection 0 never reads, so its requests queue up until they're rejected
+	var queued []<-chan string
+	for i := 0; i < routerQueueSize; i++ {
+		queued = append(queued, send(symbols[0]))
+	}
+	expectResponse(t, send(symbols[0]), "rejected(connection 0 is busy)")
+
+	// the requests of connection 1 still go through
+	send(symbols[1])
+	select {
+	case request := <-r.Requests(1):
+		if request.Symbol() != symbols[1] {
+			t.Errorf("got %s request on connection 1, expected %s", request.Symbol(), symbols[1])
+		}
+	case <-time.After(time.Second):
+		t.Fatal("connection 1 held up by connection 0")
+	}
+
+	// the queued requests are rejected on close
+	r.Close()
+	for _, response := range queued {
+		expectResponse(t, response, "rejected(shutting down)")
+	}
+}
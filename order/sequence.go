@@ -0,0 +1,115 @@
+package order
+
+import (
+	"expvar"
+	"sort"
+)
+
+// Sequence metrics, published per account.
+var (
+	sequenceGaps       = expvar.NewMap("sequence_gaps")
+	sequenceDuplicates = expvar.NewMap("sequence_duplicates")
+)
+
+// sequenceStatus is the result of checking the sequence number of a response.
+type sequenceStatus uint8
+
+const (
+	sequenceOK sequenceStatus = iota
+	sequenceDuplicate
+	sequenceGap
+	// sequenceSuperseded is a response to a subscription that was ended or replaced by a
+	// resubscribe.
+	sequenceSuperseded
+)
+
+// sequenceTracker tracks the last sequence number received on each subscription of a
+// connection. Subscriptions are identified by the request ID of their subscribe request, which
+// the server sets as the ReqID of every response to them, whatever the stream.
+type sequenceTracker struct {
+	streams    map[int64][]string
+	last       map[int64]int64
+	superseded map[int64]bool
+}
+
+func newSequenceTracker() *sequenceTracker {
+	return &sequenceTracker{
+		streams:    make(map[int64][]string),
+		last:       make(map[int64]int64),
+		superseded: make(map[int64]bool),
+	}
+}
+
+// subscribe starts tracking the subscription with the given request ID and streams.
+func (t *sequenceTracker) subscribe(reqID int64, streams ...string) {
+	t.streams[reqID] = streams
+}
+
+// check returns whether the sequence number follows the last one received on the subscription,
+// and the number of missing messages on a gap. Responses without a sequence number, or that
+// aren't to a subscription, aren't tracked.
+func (t *sequenceTracker) check(reqID int64, seq int64) (status sequenceStatus, missing int64) {
+	if t.superseded[reqID] {
+		status = sequenceSuperseded
+		return
+	}
+	if _, ok := t.streams[reqID]; !ok || seq == 0 {
+		return
+	}
+	last, ok := t.last[reqID]
+	switch {
+	case !ok || seq == last+1:
+		t.last[reqID] = seq
+	case seq <= last:
+		status = sequenceDuplicate
+	default:
+		status = sequenceGap
+		missing = seq - last - 1
+	}
+	return
+}
+
+// subscriptions returns the request IDs of the tracked subscriptions, in order.
+func (t *sequenceTracker) subscriptions() (reqIDs []int64) {
+	for reqID := range t.streams {
+		reqIDs = append(reqIDs, reqID)
+	}
+	sort.Slice(reqIDs, func(i, j int) bool {
+		return reqIDs[i] < reqIDs[j]
+	})
+	return
+}
+
+// subscribed returns whether a tracked subscription includes the stream.
+func (t *sequenceTracker) subscribed(stream string) bool {
+	for _, streams := range t.streams {
+		if contains(streams, stream) {
+			return true
+		}
+	}
+	return false
+}
+
+// subscriptionsOf returns the request IDs of the tracked subscriptions to any of the streams,
+// in order.
+func (t *sequenceTracker) subscriptionsOf(streams []string) (reqIDs []int64) {
+	for _, reqID := range t.subscriptions() {
+		for _, stream := range t.streams[reqID] {
+			if contains(streams, stream) {
+				reqIDs = append(reqIDs, reqID)
+				break
+			}
+		}
+	}
+	return
+}
+
+// supersede stops tracking the subscription and returns its streams. Any further responses to
+// it, sent before the server processed the unsubscribe, are reported as superseded.
+func (t *sequenceTracker) supersede(reqID int64) (streams []string) {
+	streams = t.streams[reqID]
+	delete(t.streams, reqID)
+	delete(t.last, reqID)
+	t.superseded[reqID] = true
+	return
+}
@@ -0,0 +1,109 @@
+package order
+
+import "testing"
+
+// sequenceStep is a response received by the tracker and the expected outcome.
+type sequenceStep struct {
+	reqID   int64
+	seq     int64
+	status  sequenceStatus
+	missing int64
+}
+
+func TestSequenceTracker(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []sequenceStep
+	}{
+		{"in order", []sequenceStep{
+			{1, 1, sequenceOK, 0},
+			{1, 2, sequenceOK, 0},
+			{1, 3, sequenceOK, 0},
+		}},
+		{"first seq accepted as is", []sequenceStep{
+			{1, 41, sequenceOK, 0},
+			{1, 42, sequenceOK, 0},
+		}},
+		{"duplicate", []sequenceStep{
+			{1, 1, sequenceOK, 0},
+			{1, 2, sequenceOK, 0},
+			{1, 2, sequenceDuplicate, 0},
+			{1, 1, sequenceDuplicate, 0},
+			{1, 3, sequenceOK, 0},
+		}},
+		{"gap", []sequenceStep{
+			{1, 1, sequenceOK, 0},
+			{1, 4, sequenceGap, 2},
+			// the last good seq is kept until the subscription is replaced
+			{1, 2, sequenceOK, 0},
+		}},
+		{"untracked", []sequenceStep{
+			// responses to requests other than subscriptions, or without a seq
+			{7, 5, sequenceOK, 0},
+			{7, 9, sequenceOK, 0},
+			{1, 0, sequenceOK, 0},
+			{1, 0, sequenceOK, 0},
+		}},
+		{"interleaved subscriptions", []sequenceStep{
+			{1, 1, sequenceOK, 0},
+			{2, 1, sequenceOK, 0},
+			{1, 2, sequenceOK, 0},
+			{2, 2, sequenceOK, 0},
+			{2, 3, sequenceOK, 0},
+			{1, 3, sequenceOK, 0},
+			{2, 3, sequenceDuplicate, 0},
+			{1, 5, sequenceGap, 1},
+		}},
+	}
+	for _, test := range tests {
+		tracker := newSequenceTracker()
+		tracker.subscribe(1, "ExecutionReport", "Trade")
+		tracker.subscribe(2, "Balance")
+		for i, step := range test.steps {
+			status, missing := tracker.check(step.reqID, step.seq)
+			if status != step.status || missing != step.missing {
+				t.Errorf("%s step %d: got status %d missing %d, expected status %d missing %d",
+					test.name, i, status, missing, step.status, step.missing)
+			}
+		}
+	}
+}
+
+func TestSequenceTrackerResubscribe(t *testing.T) {
+	tracker := newSequenceTracker()
+	tracker.subscribe(1, "ExecutionReport", "Trade")
+	steps := []sequenceStep{
+		{1, 1, sequenceOK, 0},
+		{1, 2, sequenceOK, 0},
+		{1, 5, sequenceGap, 2},
+	}
+	for i, step := range steps {
+		if status, missing := tracker.check(step.reqID, step.seq); status != step.status || missing != step.missing {
+			t.Fatalf("step %d: got status %d missing %d", i, status, missing)
+		}
+	}
+
+	streams := tracker.supersede(1)
+	if len(streams) != 2 || streams[0] != "ExecutionReport" || streams[1] != "Trade" {
+		t.Fatalf("got streams %v, expected both streams of the subscription", streams)
+	}
+	tracker.subscribe(2, streams...)
+
+	// the old subscription keeps delivering, interleaved with the replay on the new one
+	steps = []sequenceStep{
+		{1, 6, sequenceSuperseded, 0},
+		{2, 1, sequenceOK, 0},
+		{1, 7, sequenceSuperseded, 0},
+		{2, 2, sequenceOK, 0},
+		{2, 3, sequenceOK, 0},
+		{2, 3, sequenceDuplicate, 0},
+		{2, 4, sequenceOK, 0},
+	}
+	for i, step := range steps {
+		status, missing := tracker.check(step.reqID, step.seq)
+		if status != step.status || missing != step.missing {
+			t.Errorf("replay step %d: got status %d missing %d, expected status %d missing %d",
+				i, status, missing, step.status, step.missing)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package order
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/pintu-crypto/b2b-order/client"
+)
+
+// Stream describes a stream the handler subscribes to: the Go type its elements are decoded
+// into, and the function that processes them.
+type Stream struct {
+	// Name is the name of the stream, as subscribed to and as the type of its responses.
+	Name string
+	// New returns a pointer to a new value to decode an element of the stream into.
+	New func() interface{}
+	// Handle processes a decoded element received on the subscription with the given request
+	// ID. It runs on the handler goroutine, so it must not block. An error records the element
+	// as a dead letter.
+	Handle func(reqID int64, value interface{}) error
+	// Timestamp returns the time of a decoded element. If set, the stream is checkpointed with
+	// it and resumed from the checkpoint on subscribe, otherwise it starts from the present.
+	Timestamp func(value interface{}) client.MicrosTimestamp
+}
+
+// Streams is a registry of streams by name. The handler subscribes to every stream in its
+// registry, in the order they were registered.
+type Streams struct {
+	streams map[string]*Stream
+	names   []string
+}
+
+// NewStreams returns a registry of the given streams.
+func NewStreams(streams ...Stream) (result *Streams, err error) {
+	result = &Streams{streams: make(map[string]*Stream)}
+	for _, stream := range streams {
+		if err = result.Register(stream); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Register adds a stream to the registry.
+func (s *Streams) Register(stream Stream) (err error) {
+	switch {
+	case stream.Name == "":
+		return errors.New("stream name is required")
+	case stream.New == nil || stream.Handle == nil:
+		return errors.Errorf("stream %s requires a New and a Handle function", stream.Name)
+	case s.streams[stream.Name] != nil:
+		return errors.Errorf("stream %s is already registered", stream.Name)
+	}
+	s.streams[stream.Name] = &stream
+	s.names = append(s.names, stream.Name)
+	return
+}
+
+// Get returns the stream with the given name, or nil if it isn't registered.
+func (s *Streams) Get(name string) *Stream {
+	return s.streams[name]
+}
+
+// Names returns the names of the registered streams, in the order they were registered.
+func (s *Streams) Names() []string {
+	return append([]string(nil), s.names...)
+}
@@ -0,0 +1,95 @@
+package order
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/pintu-crypto/b2b-order/endpoint"
+)
+
+// security is a stream element type that the handler doesn't know about.
+type security struct {
+	Symbol      string
+	MinimumSize string
+}
+
+func TestStreamsRegister(t *testing.T) {
+	handle := func(int64, interface{}) error { return nil }
+	streams, err := NewStreams(Stream{Name: "Security", New: func() interface{} { return &security{} }, Handle: handle})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = streams.Register(Stream{Name: "Security", New: func() interface{} { return &security{} }, Handle: handle}); err == nil {
+		t.Error("registered the same stream twice")
+	}
+	if err = streams.Register(Stream{Name: "Balance"}); err == nil {
+		t.Error("registered a stream without a type and a handler")
+	}
+	if streams.Get("Security") == nil || streams.Get("Balance") != nil {
+		t.Errorf("got streams %v, expected only Security", streams.Names())
+	}
+
+	// the streams of the config can't replace the ones of the handler
+	duplicate, _ := NewStreams(Stream{Name: "Trade", New: func() interface{} { return &security{} }, Handle: handle})
+	if _, err = New(make(chan []byte), make(chan []byte), make(endpoint.RequestsChannel), Config{Streams: duplicate}); err == nil {
+		t.Error("replaced the Trade stream")
+	}
+}
+
+func TestHandlerStreams(t *testing.T) {
+	var received []*security
+	streams, err := NewStreams(Stream{
+		Name: "Security",
+		New:  func() interface{} { return &security{} },
+		Handle: func(reqID int64, value interface{}) error {
+			received = append(received, value.(*security))
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	incoming := make(chan []byte, 10)
+	outgoing := make(chan []byte, 10)
+	deadLetters := NewDeadLetters("test")
+	handler, err := New(incoming, outgoing, make(endpoint.RequestsChannel),
+		Config{Account: "test", Streams: streams, DeadLetters: deadLetters})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &testHandler{Handler: handler, incoming: incoming, outgoing: outgoing}
+	defer h.Close()
+	incoming <- []byte(`{"type":"hello","session_id":"S1"}`)
+
+	// the stream is subscribed along with the execution reports and trades, from the present
+	subscribe := struct {
+		Streams []struct {
+			Name      string
+			StartDate *string
+		}
+	}{}
+	if err = json.Unmarshal(h.expectSent(t, "subscribe"), &subscribe); err != nil {
+		t.Fatal(err)
+	}
+	if len(subscribe.Streams) != 3 || subscribe.Streams[2].Name != "Security" || subscribe.Streams[2].StartDate != nil {
+		t.Fatalf("got streams %+v, expected Security after ExecutionReport and Trade", subscribe.Streams)
+	}
+
+	// the elements are decoded into their type, and one that can't be is a dead letter
+	h.incoming <- []byte(`{"reqid":1,"seq":1,"type":"Security","data":[
+		{"Symbol":"DOGE-USDT","MinimumSize":"200"},
+		{"Symbol":7},
+		{"Symbol":"BTC-USDT","MinimumSize":"0.0001"}]}`)
+	h.sync()
+	if len(received) != 2 || received[0].Symbol != "DOGE-USDT" || received[1].MinimumSize != "0.0001" {
+		t.Errorf("got %+v, expected DOGE-USDT and BTC-USDT", received)
+	}
+	if letters := deadLetters.List(); len(letters) != 1 {
+		t.Errorf("got %d dead letters, expected 1", len(letters))
+	}
+	if startDate := h.checkpoint.StartDate("Security"); startDate != nil {
+		t.Errorf("got checkpoint %v, expected none", time.Time(*startDate))
+	}
+}
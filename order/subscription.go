@@ -0,0 +1,164 @@
+package order
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Subscriptions tracks the streams subscribed by the connections of an account, so that the
+// handler of each new connection restores them after a reconnect, and changes them at runtime
+// on the connected handlers. It is safe for concurrent use, and it outlives the handlers of the
+// individual connections.
+type Subscriptions struct {
+	mu      sync.Mutex
+	streams []string
+	// removed are the streams unsubscribed before the first connection, which aren't known yet
+	removed  []string
+	restored bool
+	handlers map[*Handler]bool
+}
+
+// NewSubscriptions returns the subscriptions of an account. Until changed, the handlers
+// subscribe to all the streams of their registry.
+func NewSubscriptions() *Subscriptions {
+	return &Subscriptions{handlers: make(map[*Handler]bool)}
+}
+
+// Active returns the subscribed streams.
+func (s *Subscriptions) Active() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.streams...)
+}
+
+// Subscribe adds the given streams, and subscribes to them on the connected handlers, from
+// their checkpoints. Streams that aren't in the registry of the handlers are subscribed to, but
+// their responses are logged as unhandled.
+func (s *Subscriptions) Subscribe(streams ...string) (err error) {
+	s.mu.Lock()
+	for _, stream := range streams {
+		if !contains(s.streams, stream) {
+			s.streams = append(s.streams, stream)
+		}
+	}
+	handlers := s.connected()
+	s.mu.Unlock()
+	for _, h := range handlers {
+		if subscribeErr := h.changeSubscriptions(streams, nil); subscribeErr != nil {
+			err = subscribeErr
+		}
+	}
+	return
+}
+
+// Unsubscribe removes the given streams, and ends their subscriptions on the connected
+// handlers. The execution reports can't be unsubscribed, as the orders depend on them.
+func (s *Subscriptions) Unsubscribe(streams ...string) (err error) {
+	if contains(streams, "ExecutionReport") {
+		return errors.New("the ExecutionReport stream is required")
+	}
+	s.mu.Lock()
+	remaining := s.streams[:0]
+	for _, stream := range s.streams {
+		if !contains(streams, stream) {
+			remaining = append(remaining, stream)
+		}
+	}
+	s.streams = remaining
+	if !s.restored {
+		s.removed = append(s.removed, streams...)
+	}
+	handlers := s.connected()
+	s.mu.Unlock()
+	for _, h := range handlers {
+		if unsubscribeErr := h.changeSubscriptions(nil, streams); unsubscribeErr != nil {
+			err = unsubscribeErr
+		}
+	}
+	return
+}
+
+// ServeHTTP responds with the subscribed streams as JSON. A POST subscribes to the stream in
+// the stream parameter, and a DELETE unsubscribes from it.
+func (s *Subscriptions) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	stream := r.URL.Query().Get("stream")
+	var err error
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost, http.MethodDelete:
+		if stream == "" {
+			http.Error(w, "missing required parameter 'stream'", http.StatusBadRequest)
+			return
+		}
+		if r.Method == http.MethodPost {
+			err = s.Subscribe(stream)
+		} else {
+			err = s.Unsubscribe(stream)
+		}
+	default:
+		http.Error(w, "expected GET, POST or DELETE", http.StatusMethodNotAllowed)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.Active())
+}
+
+// restore returns the streams for a new connection to subscribe to. The first time, these are
+// the given ones, changed by the calls made until then.
+func (s *Subscriptions) restore(initial []string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.restored {
+		var streams []string
+		for _, stream := range initial {
+			if !contains(s.removed, stream) {
+				streams = append(streams, stream)
+			}
+		}
+		for _, stream := range s.streams {
+			if !contains(streams, stream) {
+				streams = append(streams, stream)
+			}
+		}
+		s.streams, s.removed, s.restored = streams, nil, true
+	}
+	return append([]string(nil), s.streams...)
+}
+
+// attach and detach add and remove a connected handler.
+func (s *Subscriptions) attach(h *Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[h] = true
+}
+
+func (s *Subscriptions) detach(h *Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.handlers, h)
+}
+
+// connected returns the connected handlers.
+func (s *Subscriptions) connected() (handlers []*Handler) {
+	for h := range s.handlers {
+		handlers = append(handlers, h)
+	}
+	return
+}
+
+// contains returns whether the stream is in the list.
+func contains(streams []string, stream string) bool {
+	for _, s := range streams {
+		if s == stream {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+package order
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/pintu-crypto/b2b-order/endpoint"
+)
+
+// subscribeMessage is the part of a subscribe request checked by the tests.
+type subscribeMessage struct {
+	ReqID   int64 `json:"reqid"`
+	Streams []struct {
+		Name string `json:"name"`
+	} `json:"streams"`
+}
+
+func (m subscribeMessage) names() (names []string) {
+	for _, stream := range m.Streams {
+		names = append(names, stream.Name)
+	}
+	return
+}
+
+func TestSubscriptionsRestore(t *testing.T) {
+	s := NewSubscriptions()
+	// changes before the first connection apply to the streams of the registry
+	if err := s.Subscribe("Balance"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Unsubscribe("Trade"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Unsubscribe("ExecutionReport"); err == nil {
+		t.Error("unsubscribed from the execution reports")
+	}
+	expected := []string{"ExecutionReport", "Balance"}
+	if streams := s.restore([]string{"ExecutionReport", "Trade"}); !reflect.DeepEqual(streams, expected) {
+		t.Errorf("got %v, expected %v", streams, expected)
+	}
+	// later connections get the same streams, whatever their registry
+	if streams := s.restore([]string{"ExecutionReport", "Trade", "Quote"}); !reflect.DeepEqual(streams, expected) {
+		t.Errorf("got %v after a reconnect, expected %v", streams, expected)
+	}
+}
+
+func TestSubscriptionsHandler(t *testing.T) {
+	subscriptions := NewSubscriptions()
+	h := newTestHandler(t, Config{Account: "test", Subscriptions: subscriptions})
+
+	// a new stream gets its own subscription
+	if err := subscriptions.Subscribe("Balance"); err != nil {
+		t.Fatal(err)
+	}
+	subscribe := subscribeMessage{}
+	if err := json.Unmarshal(h.expectSent(t, "subscribe"), &subscribe); err != nil {
+		t.Fatal(err)
+	}
+	if subscribe.ReqID != 2 || !reflect.DeepEqual(subscribe.names(), []string{"Balance"}) {
+		t.Errorf("got subscribe %+v, expected Balance on request 2", subscribe)
+	}
+	// subscribing again changes nothing
+	if err := subscriptions.Subscribe("Balance"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the subscription of an unsubscribed stream is ended, and its other streams resumed
+	if err := subscriptions.Unsubscribe("Trade"); err != nil {
+		t.Fatal(err)
+	}
+	unsubscribe := struct {
+		ReqID int64 `json:"reqid"`
+	}{}
+	if err := json.Unmarshal(h.expectSent(t, "unsubscribe"), &unsubscribe); err != nil {
+		t.Fatal(err)
+	}
+	if unsubscribe.ReqID != 1 {
+		t.Errorf("unsubscribed request %d, expected 1", unsubscribe.ReqID)
+	}
+	if err := json.Unmarshal(h.expectSent(t, "subscribe"), &subscribe); err != nil {
+		t.Fatal(err)
+	}
+	if subscribe.ReqID != 3 || !reflect.DeepEqual(subscribe.names(), []string{"ExecutionReport"}) {
+		t.Errorf("got subscribe %+v, expected ExecutionReport on request 3", subscribe)
+	}
+	h.Close()
+
+	// the next connection restores the changed streams
+	h = newTestHandlerSubscribe(t, Config{Account: "test", Subscriptions: subscriptions}, &subscribe)
+	defer h.Close()
+	if expected := []string{"ExecutionReport", "Balance"}; !reflect.DeepEqual(subscribe.names(), expected) {
+		t.Errorf("got %v after a reconnect, expected %v", subscribe.names(), expected)
+	}
+	if active := subscriptions.Active(); !reflect.DeepEqual(active, []string{"ExecutionReport", "Balance"}) {
+		t.Errorf("got active streams %v", active)
+	}
+}
+
+func TestSubscriptionsServeHTTP(t *testing.T) {
+	subscriptions := NewSubscriptions()
+	subscriptions.restore([]string{"ExecutionReport", "Trade"})
+	tests := []struct {
+		method   string
+		target   string
+		code     int
+		expected []string
+	}{
+		{http.MethodGet, "/subscriptions", http.StatusOK, []string{"ExecutionReport", "Trade"}},
+		{http.MethodPost, "/subscriptions?stream=Quote", http.StatusOK, []string{"ExecutionReport", "Trade", "Quote"}},
+		{http.MethodDelete, "/subscriptions?stream=Trade", http.StatusOK, []string{"ExecutionReport", "Quote"}},
+		{http.MethodDelete, "/subscriptions?stream=ExecutionReport", http.StatusBadRequest, nil},
+		{http.MethodPost, "/subscriptions", http.StatusBadRequest, nil},
+		{http.MethodPut, "/subscriptions?stream=Quote", http.StatusMethodNotAllowed, nil},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		subscriptions.ServeHTTP(w, httptest.NewRequest(test.method, test.target, nil))
+		if w.Code != test.code {
+			t.Errorf("%s %s: got status %d, expected %d", test.method, test.target, w.Code, test.code)
+			continue
+		}
+		if test.expected == nil {
+			continue
+		}
+		var streams []string
+		if err := json.Unmarshal(w.Body.Bytes(), &streams); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(streams, test.expected) {
+			t.Errorf("%s %s: got %v, expected %v", test.method, test.target, streams, test.expected)
+		}
+	}
+}
+
+// newTestHandlerSubscribe returns a connected test handler, and decodes its initial subscribe.
+func newTestHandlerSubscribe(t *testing.T, config Config, subscribe *subscribeMessage) *testHandler {
+	incoming := make(chan []byte, 10)
+	outgoing := make(chan []byte, 10)
+	handler, err := New(incoming, outgoing, make(endpoint.RequestsChannel), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	incoming <- []byte(`{"type":"hello","session_id":"S1"}`)
+	h := &testHandler{Handler: handler, incoming: incoming, outgoing: outgoing}
+	if err = json.Unmarshal(h.expectSent(t, "subscribe"), subscribe); err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
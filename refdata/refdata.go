@@ -0,0 +1,130 @@
+package refdata
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+
+	"github.com/pintu-crypto/b2b-order/client"
+	"github.com/pintu-crypto/b2b-order/order"
+)
+
+// StreamName is the name of the stream of the securities.
+const StreamName = "Security"
+
+// Cache holds the reference data of the symbols, from the Security stream or from a file, and
+// validates orders against it. It is safe for concurrent use.
+type Cache struct {
+	mu         sync.RWMutex
+	securities map[string]*client.Security
+}
+
+// NewCache returns an empty cache.
+func NewCache() *Cache {
+	return &Cache{securities: make(map[string]*client.Security)}
+}
+
+// LoadFile returns a cache of the securities in the given file, one JSON object per line as
+// printed by 'pintuctl tail Security'.
+func LoadFile(path string) (result *Cache, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		err = errors.Wrapf(err, "unable to read reference data %s", path)
+		return
+	}
+	result = NewCache()
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		security := &client.Security{}
+		if err = json.Unmarshal(scanner.Bytes(), security); err != nil {
+			err = errors.Wrapf(err, "unable to decode reference data %s line %d", path, line)
+			return
+		}
+		result.Set(security)
+	}
+	return
+}
+
+// Set adds or replaces the reference data of a symbol.
+func (c *Cache) Set(security *client.Security) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.securities[security.Symbol] = security
+}
+
+// Stream returns the Security stream, for an order handler to keep the cache up to date.
+func (c *Cache) Stream() order.Stream {
+	return order.Stream{
+		Name: StreamName,
+		New:  func() interface{} { return &client.Security{} },
+		Handle: func(reqID int64, value interface{}) error {
+			c.Set(value.(*client.Security))
+			return nil
+		},
+	}
+}
+
+// Get returns the reference data of the given symbol, or nil if it isn't known.
+func (c *Cache) Get(symbol string) *client.Security {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.securities[symbol]
+}
+
+// Validate rounds the quantity of an order down to the size increment of its symbol, and the
+// price of a buy down and of a sell up to the price increment, then checks the quantity against
+// the minimum and maximum size. A quantity in the quote currency is left to the server. Orders
+// for symbols that aren't known yet are not checked.
+func (c *Cache) Validate(order *client.NewOrderSingle) (err error) {
+	security := c.Get(order.Symbol)
+	if security == nil {
+		return
+	}
+	if order.Price != nil {
+		if !order.Price.IsPositive() {
+			return fmt.Errorf("price %s is not positive", order.Price)
+		}
+		price := roundDown(*order.Price, security.MinPriceIncrement)
+		if order.Side == client.Side.Sell && !price.Equal(*order.Price) {
+			price = price.Add(security.MinPriceIncrement)
+		}
+		if !price.Equal(*order.Price) {
+			log.Printf("rounded %s price %s to %s", order.Symbol, order.Price, price)
+			order.Price = &price
+		}
+	}
+	if order.Currency != "" && order.Currency != security.BaseCurrency {
+		return
+	}
+	quantity := roundDown(order.OrderQty, security.MinSizeIncrement)
+	if !quantity.Equal(order.OrderQty) {
+		log.Printf("rounded %s quantity %s to %s", order.Symbol, order.OrderQty, quantity)
+		order.OrderQty = quantity
+	}
+	if !quantity.IsPositive() || quantity.LessThan(security.MinimumSize) {
+		return fmt.Errorf("below min size %s %s", security.MinimumSize, security.BaseCurrency)
+	}
+	if security.MaximumSize.IsPositive() && quantity.GreaterThan(security.MaximumSize) {
+		return fmt.Errorf("above max size %s %s", security.MaximumSize, security.BaseCurrency)
+	}
+	return
+}
+
+// roundDown rounds the value down to a multiple of the increment, if it is positive.
+func roundDown(value decimal.Decimal, increment decimal.Decimal) decimal.Decimal {
+	if !increment.IsPositive() {
+		return value
+	}
+	return value.Div(increment).Floor().Mul(increment)
+}
@@ -0,0 +1,89 @@
+package refdata
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/pintu-crypto/b2b-order/client"
+)
+
+const securities = `{"Symbol":"DOGE-USDT","BaseCurrency":"DOGE","QuoteCurrency":"USDT","MinimumSize":"200","MaximumSize":"1000000","MinSizeIncrement":"1","MinPriceIncrement":"0.0001"}
+
+{"Symbol":"BTC-USDT","BaseCurrency":"BTC","QuoteCurrency":"USDT","MinimumSize":"0.0001","MinSizeIncrement":"0.00001","MinPriceIncrement":"0.01"}
+`
+
+func TestValidate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "securities.jsonl")
+	if err := os.WriteFile(path, []byte(securities), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cache, err := LoadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	price := func(value string) *decimal.Decimal {
+		result := decimal.RequireFromString(value)
+		return &result
+	}
+	tests := []struct {
+		name     string
+		order    client.NewOrderSingle
+		err      string
+		quantity string
+		price    string
+	}{
+		{"valid", client.NewOrderSingle{Symbol: "DOGE-USDT", Side: client.Side.Buy,
+			OrderQty: decimal.RequireFromString("210")}, "", "210", ""},
+		{"rounded quantity", client.NewOrderSingle{Symbol: "DOGE-USDT", Side: client.Side.Buy,
+			OrderQty: decimal.RequireFromString("210.7")}, "", "210", ""},
+		{"below min size", client.NewOrderSingle{Symbol: "DOGE-USDT", Side: client.Side.Buy,
+			OrderQty: decimal.RequireFromString("150")}, "below min size 200 DOGE", "150", ""},
+		{"rounded below min size", client.NewOrderSingle{Symbol: "DOGE-USDT", Side: client.Side.Buy,
+			OrderQty: decimal.RequireFromString("199.9")}, "below min size 200 DOGE", "199", ""},
+		{"above max size", client.NewOrderSingle{Symbol: "DOGE-USDT", Side: client.Side.Sell,
+			OrderQty: decimal.RequireFromString("2000000")}, "above max size 1000000 DOGE", "2000000", ""},
+		{"no max size", client.NewOrderSingle{Symbol: "BTC-USDT", Side: client.Side.Sell,
+			OrderQty: decimal.RequireFromString("2000.123456")}, "", "2000.12345", ""},
+		{"buy price rounded down", client.NewOrderSingle{Symbol: "DOGE-USDT", Side: client.Side.Buy,
+			OrderQty: decimal.RequireFromString("210"), Price: price("0.12345")}, "", "210", "0.1234"},
+		{"sell price rounded up", client.NewOrderSingle{Symbol: "DOGE-USDT", Side: client.Side.Sell,
+			OrderQty: decimal.RequireFromString("210"), Price: price("0.12341")}, "", "210", "0.1235"},
+		{"price not positive", client.NewOrderSingle{Symbol: "DOGE-USDT", Side: client.Side.Buy,
+			OrderQty: decimal.RequireFromString("210"), Price: price("0")}, "price 0 is not positive", "210", "0"},
+		{"quote currency", client.NewOrderSingle{Symbol: "DOGE-USDT", Currency: "USDT", Side: client.Side.Buy,
+			OrderQty: decimal.RequireFromString("5.5")}, "", "5.5", ""},
+		{"unknown symbol", client.NewOrderSingle{Symbol: "ETH-USDT", Side: client.Side.Buy,
+			OrderQty: decimal.RequireFromString("0.000001")}, "", "0.000001", ""},
+	}
+	for _, test := range tests {
+		order := test.order
+		err := cache.Validate(&order)
+		if (err == nil && test.err != "") || (err != nil && err.Error() != test.err) {
+			t.Errorf("%s: got error %v, expected %q", test.name, err, test.err)
+		}
+		if order.OrderQty.String() != test.quantity {
+			t.Errorf("%s: got quantity %s, expected %s", test.name, order.OrderQty, test.quantity)
+		}
+		if test.price != "" && order.Price.String() != test.price {
+			t.Errorf("%s: got price %s, expected %s", test.name, order.Price, test.price)
+		}
+	}
+}
+
+func TestStream(t *testing.T) {
+	cache := NewCache()
+	stream := cache.Stream()
+	security := stream.New().(*client.Security)
+	security.Symbol = "DOGE-USDT"
+	security.MinimumSize = decimal.NewFromInt(200)
+	if err := stream.Handle(1, security); err != nil {
+		t.Fatal(err)
+	}
+	if got := cache.Get("DOGE-USDT"); got == nil || !got.MinimumSize.Equal(decimal.NewFromInt(200)) {
+		t.Errorf("got %+v, expected the DOGE-USDT security", got)
+	}
+}